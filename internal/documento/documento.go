@@ -0,0 +1,62 @@
+// Validação de CPF e CNPJ: remove a pontuação e confere os dígitos verificadores.
+package documento
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrCPFInvalido  = errors.New("CPF inválido")
+	ErrCNPJInvalido = errors.New("CNPJ inválido")
+)
+
+// Mantém só os dígitos, aceitando "123.456.789-09" ou "12.345.678/0001-95"
+func Normalizar(doc string) string {
+	var b strings.Builder
+	for _, r := range doc {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Normaliza e confere um CPF, retornando os 11 dígitos
+func CPF(doc string) (string, error) {
+	d := Normalizar(doc)
+	if !valido(d, 11, []int{10, 9, 8, 7, 6, 5, 4, 3, 2}, []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2}) {
+		return "", ErrCPFInvalido
+	}
+	return d, nil
+}
+
+// Normaliza e confere um CNPJ, retornando os 14 dígitos
+func CNPJ(doc string) (string, error) {
+	d := Normalizar(doc)
+	if !valido(d, 14, []int{5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}, []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}) {
+		return "", ErrCNPJInvalido
+	}
+	return d, nil
+}
+
+// Confere o tamanho, rejeita dígitos todos iguais e calcula os dois
+// verificadores pelo módulo 11 com os pesos de cada documento
+func valido(d string, tamanho int, pesos1, pesos2 []int) bool {
+	if len(d) != tamanho || strings.Count(d, d[:1]) == tamanho {
+		return false
+	}
+	return d[tamanho-2] == verificador(d, pesos1) && d[tamanho-1] == verificador(d, pesos2)
+}
+
+func verificador(d string, pesos []int) byte {
+	soma := 0
+	for i, p := range pesos {
+		soma += int(d[i]-'0') * p
+	}
+	resto := soma % 11
+	if resto < 2 {
+		return '0'
+	}
+	return byte('0' + 11 - resto)
+}
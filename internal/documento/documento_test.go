@@ -0,0 +1,48 @@
+package documento
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCPF(t *testing.T) {
+	cases := []struct {
+		doc      string
+		esperado string
+		err      error
+	}{
+		{"529.982.247-25", "52998224725", nil},
+		{"52998224725", "52998224725", nil},
+		{"111.444.777-35", "11144477735", nil},
+		{"529.982.247-24", "", ErrCPFInvalido},
+		{"111.111.111-11", "", ErrCPFInvalido},
+		{"5299822472", "", ErrCPFInvalido},
+		{"", "", ErrCPFInvalido},
+	}
+	for _, c := range cases {
+		got, err := CPF(c.doc)
+		if got != c.esperado || !errors.Is(err, c.err) {
+			t.Fatalf("%q: expected %q, %v, got %q, %v", c.doc, c.esperado, c.err, got, err)
+		}
+	}
+}
+
+func TestCNPJ(t *testing.T) {
+	cases := []struct {
+		doc      string
+		esperado string
+		err      error
+	}{
+		{"11.222.333/0001-81", "11222333000181", nil},
+		{"11222333000181", "11222333000181", nil},
+		{"11.222.333/0001-80", "", ErrCNPJInvalido},
+		{"00.000.000/0000-00", "", ErrCNPJInvalido},
+		{"52998224725", "", ErrCNPJInvalido},
+	}
+	for _, c := range cases {
+		got, err := CNPJ(c.doc)
+		if got != c.esperado || !errors.Is(err, c.err) {
+			t.Fatalf("%q: expected %q, %v, got %q, %v", c.doc, c.esperado, c.err, got, err)
+		}
+	}
+}
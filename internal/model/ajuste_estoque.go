@@ -0,0 +1,55 @@
+package model
+
+import (
+	"edna/internal/validacao"
+	"time"
+)
+
+const (
+	MotivoQuebra     = "quebra"
+	MotivoVencimento = "vencimento"
+	MotivoFurto      = "furto"
+	MotivoDegustacao = "degustacao"
+	MotivoInventario = "inventario"
+)
+
+var MotivosAjuste = []string{MotivoQuebra, MotivoVencimento, MotivoFurto, MotivoDegustacao, MotivoInventario}
+
+// Ajuste no estoque de um lote. Quantidade são as unidades retiradas do estoque,
+// só correções de inventário podem ser negativas (unidades encontradas).
+type AjusteEstoque struct {
+	IDAjuste      int64     `json:"id_ajuste"`
+	IDLote        int64     `json:"id_lote"`
+	IDFuncionario *int64    `json:"id_funcionario"`
+	Quantidade    int64     `json:"quantidade"`
+	Motivo        string    `json:"motivo"`
+	Observacao    *string   `json:"observacao"`
+	DataHora      time.Time `json:"data_hora"`
+}
+
+type AjusteEstoqueCreate struct {
+	IDFuncionario int64   `json:"id_funcionario"`
+	Quantidade    int64   `json:"quantidade"`
+	Motivo        string  `json:"motivo"`
+	Observacao    *string `json:"observacao"`
+}
+
+func (ac *AjusteEstoqueCreate) ToAjusteEstoque(idLote int64) AjusteEstoque {
+	return AjusteEstoque{
+		IDLote:        idLote,
+		IDFuncionario: &ac.IDFuncionario,
+		Quantidade:    ac.Quantidade,
+		Motivo:        ac.Motivo,
+		Observacao:    ac.Observacao,
+	}
+}
+
+func (ac *AjusteEstoqueCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_funcionario", ac.IDFuncionario)
+	v.Enum("motivo", ac.Motivo, MotivosAjuste...)
+	if ac.Quantidade == 0 || (ac.Quantidade < 0 && ac.Motivo != MotivoInventario) {
+		v.Add("quantidade", "deve ser maior que zero, só inventário aceita valores negativos")
+	}
+	return v.Err()
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Lote com estoque que vence nos próximos dias
+type LoteVencendo struct {
+	IDLote         int64     `json:"id_lote"`
+	IDProduto      int64     `json:"id_produto"`
+	NomeProduto    string    `json:"nome_produto"`
+	IDFornecedor   int64     `json:"id_fornecedor"`
+	NomeFornecedor string    `json:"nome_fornecedor"`
+	Validade       time.Time `json:"validade"`
+	DiasRestantes  int       `json:"dias_restantes"`
+	Disponivel     int64     `json:"disponivel"`
+}
+
+// Alerta de validade registrado pela rotina diária
+type AlertaValidade struct {
+	LoteVencendo
+	IDAlerta     int64      `json:"id_alerta"`
+	CriadoEm     time.Time  `json:"criado_em"`
+	AtualizadoEm time.Time  `json:"atualizado_em"`
+	VistoEm      *time.Time `json:"visto_em"`
+}
@@ -1,10 +1,13 @@
 package model
 
+import "edna/internal/validacao"
+
 type AplicaOferta struct {
-	IDAplicaOferta int64 `json:"id_aplica_oferta"`
-	IDOferta       int64 `json:"id_oferta"`
-	IDVenda        int64 `json:"id_venda"`
-	IDItemVenda    int64 `json:"id_item_venda"`
+	IDAplicaOferta int64   `json:"id_aplica_oferta"`
+	IDOferta       int64   `json:"id_oferta"`
+	IDVenda        int64   `json:"id_venda"`
+	IDItemVenda    int64   `json:"id_item_venda"`
+	Desconto       float64 `json:"desconto"`
 }
 
 type AplicaOfertaResponse struct {
@@ -20,3 +23,11 @@ func (aor AplicaOfertaResponse) ToAplicaOferta() AplicaOferta {
 		IDItemVenda: aor.IDItemVenda,
 	}
 }
+
+func (aor *AplicaOfertaResponse) Validar() error {
+	var v validacao.Erros
+	v.ID("id_oferta", aor.IDOferta)
+	v.ID("id_venda", aor.IDVenda)
+	v.ID("id_item_venda", aor.IDItemVenda)
+	return v.Err()
+}
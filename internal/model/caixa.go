@@ -0,0 +1,94 @@
+package model
+
+import (
+	"edna/internal/validacao"
+	"time"
+)
+
+const (
+	MovimentoSangria    = "sangria"
+	MovimentoSuprimento = "suprimento"
+)
+
+// Sessão de caixa de um funcionário, aberta com um fundo de troco
+type CaixaSessao struct {
+	IDSessao           int64      `json:"id_sessao"`
+	IDFuncionario      int64      `json:"id_funcionario"`
+	Expediente         string     `json:"expediente"`
+	ValorAbertura      float64    `json:"valor_abertura"`
+	DataHoraAbertura   time.Time  `json:"data_hora_abertura"`
+	DataHoraFechamento *time.Time `json:"data_hora_fechamento"`
+	ValorContado       *float64   `json:"valor_contado"`
+}
+
+type CaixaSessaoCreate struct {
+	IDFuncionario int64   `json:"id_funcionario"`
+	Expediente    string  `json:"expediente"` // Opcional, padrão é o expediente do funcionário
+	ValorAbertura float64 `json:"valor_abertura"`
+}
+
+func (cc *CaixaSessaoCreate) ToCaixaSessao() CaixaSessao {
+	return CaixaSessao{
+		IDFuncionario: cc.IDFuncionario,
+		Expediente:    cc.Expediente,
+		ValorAbertura: cc.ValorAbertura,
+	}
+}
+
+func (cc *CaixaSessaoCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_funcionario", cc.IDFuncionario)
+	if cc.Expediente != "" {
+		v.Enum("expediente", cc.Expediente, Expedientes...)
+	}
+	v.NaoNegativo("valor_abertura", cc.ValorAbertura)
+	return v.Err()
+}
+
+type CaixaFechamento struct {
+	ValorContado float64 `json:"valor_contado"`
+}
+
+// Sangria (retirada) ou suprimento (entrada) de dinheiro no caixa
+type CaixaMovimento struct {
+	IDMovimento int64     `json:"id_movimento"`
+	IDSessao    int64     `json:"id_sessao"`
+	Tipo        string    `json:"tipo"`
+	Valor       float64   `json:"valor"`
+	Motivo      *string   `json:"motivo"`
+	DataHora    time.Time `json:"data_hora"`
+}
+
+type CaixaMovimentoCreate struct {
+	Tipo   string  `json:"tipo"`
+	Valor  float64 `json:"valor"`
+	Motivo *string `json:"motivo"`
+}
+
+func (mc *CaixaMovimentoCreate) ToCaixaMovimento(idSessao int64) CaixaMovimento {
+	return CaixaMovimento{
+		IDSessao: idSessao,
+		Tipo:     mc.Tipo,
+		Valor:    mc.Valor,
+		Motivo:   mc.Motivo,
+	}
+}
+
+func (mc *CaixaMovimentoCreate) Validar() error {
+	var v validacao.Erros
+	v.Enum("tipo", mc.Tipo, MovimentoSangria, MovimentoSuprimento)
+	v.Positivo("valor", mc.Valor)
+	return v.Err()
+}
+
+// Conferência do caixa: dinheiro esperado contra o contado no fechamento.
+// Esperado = abertura + suprimentos - sangrias + pagamentos em dinheiro na sessão.
+type CaixaConciliacao struct {
+	CaixaSessao
+	Suprimentos    float64          `json:"suprimentos"`
+	Sangrias       float64          `json:"sangrias"`
+	VendasDinheiro float64          `json:"vendas_dinheiro"`
+	Esperado       float64          `json:"esperado"`
+	Diferenca      *float64         `json:"diferenca"` // contado - esperado, nulo com o caixa aberto
+	Movimentos     []CaixaMovimento `json:"movimentos"`
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"edna/internal/validacao"
 	"time"
 )
 
@@ -9,6 +10,7 @@ type Cliente struct {
 	Nome           string     `json:"nome"`
 	CPF            *string    `json:"cpf"`
 	DataNascimento *time.Time `json:"data_nascimento"`
+	LimiteCredito  *float64   `json:"limite_credito"` // Nulo é sem limite de fiado
 }
 
 type ClienteWithSaldo struct {
@@ -27,6 +29,7 @@ type ClienteCreate struct {
 	Nome           string     `json:"nome"`
 	CPF            *string    `json:"cpf"`
 	DataNascimento *time.Time `json:"data_nascimento"` // Espera-se "YYYY-MM-DD" ou formato RFC3339
+	LimiteCredito  *float64   `json:"limite_credito"`
 }
 
 func (cc ClienteCreate) ToCliente() Cliente {
@@ -34,5 +37,20 @@ func (cc ClienteCreate) ToCliente() Cliente {
 		Nome:           cc.Nome,
 		CPF:            cc.CPF,
 		DataNascimento: cc.DataNascimento,
+		LimiteCredito:  cc.LimiteCredito,
 	}
 }
+
+// O CPF é conferido à parte, junto com a normalização
+func (cc *ClienteCreate) Validar() error {
+	var v validacao.Erros
+	v.Obrigatorio("nome", cc.Nome)
+	v.Tamanho("nome", cc.Nome, TamanhoNome)
+	if cc.DataNascimento != nil && cc.DataNascimento.After(time.Now()) {
+		v.Add("data_nascimento", "não pode ser no futuro")
+	}
+	if cc.LimiteCredito != nil {
+		v.NaoNegativo("limite_credito", *cc.LimiteCredito)
+	}
+	return v.Err()
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"edna/internal/validacao"
+	"fmt"
+	"time"
+)
+
+const (
+	PedidoRascunho             = "rascunho"
+	PedidoEnviado              = "enviado"
+	PedidoParcialmenteRecebido = "parcialmente_recebido"
+	PedidoRecebido             = "recebido"
+	PedidoCancelado            = "cancelado"
+)
+
+// Ponto de reposição de um produto
+type ReposicaoProduto struct {
+	IDProduto           int64 `json:"id_produto"`
+	EstoqueMinimo       int64 `json:"estoque_minimo"`
+	QuantidadeReposicao int64 `json:"quantidade_reposicao"`
+}
+
+type ReposicaoProdutoCreate struct {
+	EstoqueMinimo       int64 `json:"estoque_minimo"`
+	QuantidadeReposicao int64 `json:"quantidade_reposicao"`
+}
+
+func (rc *ReposicaoProdutoCreate) ToReposicaoProduto(idProduto int64) ReposicaoProduto {
+	return ReposicaoProduto{
+		IDProduto:           idProduto,
+		EstoqueMinimo:       rc.EstoqueMinimo,
+		QuantidadeReposicao: rc.QuantidadeReposicao,
+	}
+}
+
+func (rc *ReposicaoProdutoCreate) Validar() error {
+	var v validacao.Erros
+	v.NaoNegativo("estoque_minimo", float64(rc.EstoqueMinimo))
+	v.PositivoInt("quantidade_reposicao", rc.QuantidadeReposicao)
+	return v.Err()
+}
+
+// Produto abaixo do estoque mínimo com a quantidade sugerida para compra
+// e o último fornecedor que entregou o produto.
+type SugestaoCompra struct {
+	IDProduto           int64    `json:"id_produto"`
+	Nome                string   `json:"nome"`
+	Disponivel          int64    `json:"disponivel"`
+	EstoqueMinimo       int64    `json:"estoque_minimo"`
+	QuantidadeReposicao int64    `json:"quantidade_reposicao"`
+	VendidosPeriodo     int64    `json:"vendidos_periodo"`
+	MediaDiaria         float64  `json:"media_diaria"`
+	QuantidadeSugerida  int64    `json:"quantidade_sugerida"`
+	IDFornecedor        *int64   `json:"id_fornecedor"`
+	NomeFornecedor      *string  `json:"nome_fornecedor"`
+	UltimoPrecoUnitario *float64 `json:"ultimo_preco_unitario"`
+	CustoEstimado       *float64 `json:"custo_estimado"`
+}
+
+// Pedido de compra a um fornecedor.
+// Ciclo: rascunho -> enviado -> parcialmente_recebido -> recebido, ou cancelado
+type PedidoCompra struct {
+	IDPedido     int64              `json:"id_pedido"`
+	IDFornecedor int64              `json:"id_fornecedor"`
+	Status       string             `json:"status"`
+	Observacao   *string            `json:"observacao"`
+	DataPrevisao *time.Time         `json:"data_previsao"`
+	DataCriacao  time.Time          `json:"data_criacao"`
+	DataEnvio    *time.Time         `json:"data_envio"`
+	Itens        []ItemPedidoCompra `json:"itens"`
+}
+
+type PedidoCompraCreate struct {
+	IDFornecedor int64                    `json:"id_fornecedor"`
+	Observacao   *string                  `json:"observacao"`
+	DataPrevisao *time.Time               `json:"data_previsao"`
+	Itens        []ItemPedidoCompraCreate `json:"itens"`
+}
+
+func (pc *PedidoCompraCreate) ToPedidoCompra() PedidoCompra {
+	itens := make([]ItemPedidoCompra, 0, len(pc.Itens))
+	for _, ic := range pc.Itens {
+		itens = append(itens, ic.ToItemPedidoCompra())
+	}
+	return PedidoCompra{
+		IDFornecedor: pc.IDFornecedor,
+		Status:       PedidoRascunho,
+		Observacao:   pc.Observacao,
+		DataPrevisao: pc.DataPrevisao,
+		Itens:        itens,
+	}
+}
+
+func (pc *PedidoCompraCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_fornecedor", pc.IDFornecedor)
+	if len(pc.Itens) == 0 {
+		v.Add("itens", "precisa de ao menos um item")
+	}
+	for i, ic := range pc.Itens {
+		v.Incluir(fmt.Sprintf("itens[%d]", i), ic.Validar())
+	}
+	return v.Err()
+}
+
+type ItemPedidoCompra struct {
+	IDItemPedido       int64   `json:"id_item_pedido"`
+	IDPedido           int64   `json:"id_pedido"`
+	IDProduto          int64   `json:"id_produto"`
+	Quantidade         int64   `json:"quantidade"`
+	PrecoUnitario      float64 `json:"preco_unitario"`
+	QuantidadeRecebida int64   `json:"quantidade_recebida"` // Soma dos lotes gerados pela linha
+}
+
+type ItemPedidoCompraCreate struct {
+	IDProduto     int64   `json:"id_produto"`
+	Quantidade    int64   `json:"quantidade"`
+	PrecoUnitario float64 `json:"preco_unitario"`
+}
+
+func (ic ItemPedidoCompraCreate) ToItemPedidoCompra() ItemPedidoCompra {
+	return ItemPedidoCompra{
+		IDProduto:     ic.IDProduto,
+		Quantidade:    ic.Quantidade,
+		PrecoUnitario: ic.PrecoUnitario,
+	}
+}
+
+func (ic *ItemPedidoCompraCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_produto", ic.IDProduto)
+	v.PositivoInt("quantidade", ic.Quantidade)
+	v.Positivo("preco_unitario", ic.PrecoUnitario)
+	return v.Err()
+}
+
+// Recebimento de uma linha do pedido, vira um Lote do fornecedor do pedido
+type RecebimentoCreate struct {
+	Quantidade       int64      `json:"quantidade"`
+	DataFornecimento *time.Time `json:"data_fornecimento"` // Opcional, padrão é hoje
+	Validade         *time.Time `json:"validade"`
+	PrecoUnitario    *float64   `json:"preco_unitario"` // Opcional, padrão é o preço combinado
+}
+
+func (rc *RecebimentoCreate) Validar() error {
+	var v validacao.Erros
+	v.PositivoInt("quantidade", rc.Quantidade)
+	if rc.PrecoUnitario != nil {
+		v.Positivo("preco_unitario", *rc.PrecoUnitario)
+	}
+	v.Ordem("data_fornecimento", rc.DataFornecimento, "validade", rc.Validade)
+	return v.Err()
+}
+
+// Diferença entre o pedido e o recebido em uma linha do pedido
+type DivergenciaItem struct {
+	IDItemPedido       int64    `json:"id_item_pedido"`
+	IDProduto          int64    `json:"id_produto"`
+	NomeProduto        string   `json:"nome_produto"`
+	QuantidadePedida   int64    `json:"quantidade_pedida"`
+	QuantidadeRecebida int64    `json:"quantidade_recebida"`
+	Diferenca          int64    `json:"diferenca"` // recebida - pedida
+	PrecoCombinado     float64  `json:"preco_combinado"`
+	PrecoMedioRecebido *float64 `json:"preco_medio_recebido"`
+}
+
+type DivergenciasPedido struct {
+	IDPedido     int64             `json:"id_pedido"`
+	IDFornecedor int64             `json:"id_fornecedor"`
+	Status       string            `json:"status"`
+	Itens        []DivergenciaItem `json:"itens"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"edna/internal/validacao"
+	"fmt"
+	"time"
+)
+
+// Quitação de várias vendas em aberto (fiado) de um cliente de uma vez
+type QuitacaoCreate struct {
+	TipoPagamento string     `json:"tipo_pagamento"`
+	Valor         *float64   `json:"valor"`     // Opcional, padrão é o total das vendas escolhidas
+	Vendas        []int64    `json:"vendas"`    // Opcional, padrão são todas as vendas em aberto do cliente
+	DataHora      *time.Time `json:"data_hora"` // Opcional, padrão é o horário atual
+}
+
+func (qc *QuitacaoCreate) Validar() error {
+	var v validacao.Erros
+	v.Enum("tipo_pagamento", qc.TipoPagamento, TiposPagamento...)
+	if qc.Valor != nil {
+		v.Positivo("valor", *qc.Valor)
+	}
+	for i, id := range qc.Vendas {
+		v.ID(fmt.Sprintf("vendas[%d]", i), id)
+	}
+	return v.Err()
+}
+
+// Pagamentos gerados pela quitação, das vendas mais antigas para as mais novas
+type Quitacao struct {
+	IDCliente      int64       `json:"id_cliente"`
+	ValorPago      float64     `json:"valor_pago"`
+	Pagamentos     []Pagamento `json:"pagamentos"`
+	VendasQuitadas []int64     `json:"vendas_quitadas"`
+	SaldoDevedor   float64     `json:"saldo_devedor"` // Saldo depois da quitação
+}
+
+// Saldo devedor separado pela idade das vendas em aberto, em dias
+type FaixasAging struct {
+	Ate30   float64 `json:"ate_30"`
+	De31a60 float64 `json:"de_31_a_60"`
+	De61a90 float64 `json:"de_61_a_90"`
+	Acima90 float64 `json:"acima_90"`
+	Total   float64 `json:"total"`
+}
+
+type AgingCliente struct {
+	IDCliente       int64     `json:"id_cliente"`
+	Nome            string    `json:"nome"`
+	LimiteCredito   *float64  `json:"limite_credito"`
+	VendasEmAberto  int64     `json:"vendas_em_aberto"`
+	VendaMaisAntiga time.Time `json:"venda_mais_antiga"`
+	FaixasAging
+}
+
+type RelatorioAging struct {
+	DataReferencia string         `json:"data_referencia"`
+	Clientes       []AgingCliente `json:"clientes"`
+	Total          FaixasAging    `json:"total"`
+}
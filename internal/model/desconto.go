@@ -0,0 +1,34 @@
+package model
+
+// Oferta que pode ser aplicada a uma venda e quantas vezes
+type OfertaAplicavel struct {
+	IDOferta   int64   `json:"id_oferta"`
+	Nome       string  `json:"nome"`
+	Aplicacoes int64   `json:"aplicacoes"`
+	Desconto   float64 `json:"desconto"`
+}
+
+// Detalhamento do desconto em um item da venda
+type ItemDesconto struct {
+	IDItemVenda   int64   `json:"id_item_venda"`
+	IDProduto     int64   `json:"id_produto"`
+	Quantidade    int64   `json:"quantidade"`
+	ValorUnitario float64 `json:"valor_unitario"`
+	ValorBruto    float64 `json:"valor_bruto"`
+	Desconto      float64 `json:"desconto"`
+	ValorLiquido  float64 `json:"valor_liquido"`
+	Ofertas       []int64 `json:"ofertas"`
+}
+
+// Resultado da avaliação de ofertas de uma venda.
+// Aplicaveis lista cada oferta isolada, MelhorCombinacao a escolha final.
+type AvaliacaoOfertas struct {
+	IDVenda          int64             `json:"id_venda"`
+	TotalBruto       float64           `json:"total_bruto"`
+	TotalDesconto    float64           `json:"total_desconto"`
+	TotalLiquido     float64           `json:"total_liquido"`
+	Aplicaveis       []OfertaAplicavel `json:"ofertas_aplicaveis"`
+	MelhorCombinacao []OfertaAplicavel `json:"melhor_combinacao"`
+	Itens            []ItemDesconto    `json:"itens"`
+	Aplicacoes       []AplicaOferta    `json:"-"`
+}
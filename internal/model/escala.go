@@ -0,0 +1,109 @@
+package model
+
+import (
+	"edna/internal/validacao"
+	"time"
+)
+
+// Turno planejado de um funcionário. A madrugada de uma data vai das 00:00
+// às 06:00 do dia seguinte, continuando a noite.
+type Escala struct {
+	IDEscala      int64   `json:"id_escala"`
+	IDFuncionario int64   `json:"id_funcionario"`
+	Data          string  `json:"data"` // YYYY-MM-DD
+	Expediente    string  `json:"expediente"`
+	Observacao    *string `json:"observacao"`
+}
+
+type EscalaCreate struct {
+	IDFuncionario int64   `json:"id_funcionario"`
+	Data          string  `json:"data"` // YYYY-MM-DD
+	Expediente    string  `json:"expediente"`
+	Observacao    *string `json:"observacao"`
+}
+
+func (ec *EscalaCreate) ToEscala() Escala {
+	return Escala{
+		IDFuncionario: ec.IDFuncionario,
+		Data:          ec.Data,
+		Expediente:    ec.Expediente,
+		Observacao:    ec.Observacao,
+	}
+}
+
+func (ec *EscalaCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_funcionario", ec.IDFuncionario)
+	if _, err := time.Parse(time.DateOnly, ec.Data); err != nil {
+		v.Add("data", "use o formato YYYY-MM-DD")
+	}
+	v.Enum("expediente", ec.Expediente, Expedientes...)
+	return v.Err()
+}
+
+// Mínimo de funcionários de um tipo em um turno de um dia da semana
+type CoberturaMinima struct {
+	DiaSemana  int    `json:"dia_semana"` // 0 = domingo ... 6 = sábado
+	Expediente string `json:"expediente"`
+	Tipo       string `json:"tipo"`
+	Quantidade int64  `json:"quantidade"` // Zero remove o mínimo
+}
+
+func (cm *CoberturaMinima) Validar() error {
+	var v validacao.Erros
+	if cm.DiaSemana < 0 || cm.DiaSemana > 6 {
+		v.Add("dia_semana", "deve estar entre 0 (domingo) e 6 (sábado)")
+	}
+	v.Enum("expediente", cm.Expediente, Expedientes...)
+	v.Enum("tipo", cm.Tipo, TiposFuncionario...)
+	v.NaoNegativo("quantidade", float64(cm.Quantidade))
+	return v.Err()
+}
+
+type FuncionarioEscalado struct {
+	IDEscala      int64   `json:"id_escala"`
+	IDFuncionario int64   `json:"id_funcionario"`
+	Nome          string  `json:"nome"`
+	Tipo          string  `json:"tipo"`
+	Observacao    *string `json:"observacao"`
+}
+
+type TurnoEscala struct {
+	Expediente   string                `json:"expediente"`
+	Funcionarios []FuncionarioEscalado `json:"funcionarios"`
+}
+
+type DiaEscala struct {
+	Data      string        `json:"data"`
+	DiaSemana int           `json:"dia_semana"`
+	Turnos    []TurnoEscala `json:"turnos"`
+}
+
+// Turno com menos funcionários de um tipo que o mínimo
+type LacunaCobertura struct {
+	Data       string `json:"data"`
+	Expediente string `json:"expediente"`
+	Tipo       string `json:"tipo"`
+	Minimo     int64  `json:"minimo"`
+	Escalados  int64  `json:"escalados"`
+}
+
+// Venda registrada por um funcionário que não estava escalado no turno da venda
+type VendaForaDeEscala struct {
+	IDVenda       int64     `json:"id_venda"`
+	IDFuncionario int64     `json:"id_funcionario"`
+	Nome          string    `json:"nome"`
+	DataHoraVenda time.Time `json:"data_hora_venda"`
+	Data          string    `json:"data"` // Data da escala do turno, a madrugada conta no dia anterior
+	Expediente    string    `json:"expediente"`
+}
+
+// Escala semanal (semana ISO, de segunda a domingo)
+type SemanaEscala struct {
+	Semana             string              `json:"semana"` // YYYY-Www
+	Inicio             string              `json:"inicio"`
+	Fim                string              `json:"fim"`
+	Dias               []DiaEscala         `json:"dias"`
+	Lacunas            []LacunaCobertura   `json:"lacunas"`
+	VendasForaDeEscala []VendaForaDeEscala `json:"vendas_fora_de_escala"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+const (
+	MovimentoEntrada = "entrada"
+	MovimentoVenda   = "venda"
+	MovimentoPerda   = "perda"
+	MovimentoAjuste  = "ajuste"
+	MovimentoConsumo = "consumo" // Ingrediente de receita vendida
+)
+
+// Estoque de um lote, calculado pela view estoque_lote
+type EstoqueLote struct {
+	IDLote            int64      `json:"id_lote"`
+	IDProduto         int64      `json:"id_produto"`
+	IDFornecedor      int64      `json:"id_fornecedor"`
+	DataFornecimento  time.Time  `json:"data_fornecimento"`
+	Validade          *time.Time `json:"validade"`
+	QuantidadeInicial int64      `json:"quantidade_inicial"`
+	Estragados        int64      `json:"estragados"`
+	Ajustes           int64      `json:"ajustes"` // Correções de inventário, negativas quando sobram unidades
+	Vendidos          int64      `json:"vendidos"`
+	Disponivel        int64      `json:"disponivel"`
+	Vencido           bool       `json:"vencido"`
+}
+
+// Estoque de um produto somando seus lotes, calculado pela view estoque_produto
+type EstoqueProduto struct {
+	IDProduto  int64   `json:"id_produto"`
+	Nome       string  `json:"nome"`
+	Categoria  *string `json:"categoria"`
+	Marca      *string `json:"marca"`
+	Disponivel int64   `json:"disponivel"`
+	Vencido    int64   `json:"vencido"` // Unidades paradas em lotes vencidos
+	Lotes      int64   `json:"lotes"`   // Lotes válidos com unidades disponíveis
+}
+
+// Movimento de estoque: entradas positivas, vendas e perdas negativas.
+// Ajustes de inventário podem ter os dois sinais.
+type MovimentoEstoque struct {
+	IDLote     int64     `json:"id_lote"`
+	IDProduto  int64     `json:"id_produto"`
+	Tipo       string    `json:"tipo"`
+	DataHora   time.Time `json:"data_hora"`
+	Quantidade int64     `json:"quantidade"`
+	IDVenda    *int64    `json:"id_venda"`
+	Motivo     *string   `json:"motivo"`
+}
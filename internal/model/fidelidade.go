@@ -0,0 +1,112 @@
+package model
+
+import (
+	"edna/internal/validacao"
+	"time"
+)
+
+const (
+	PontosAcumulo   = "acumulo"
+	PontosResgate   = "resgate"
+	PontosAjuste    = "ajuste"
+	PontosEstorno   = "estorno"   // Acúmulo de uma venda que voltou a ficar em aberto
+	PontosExpiracao = "expiracao" // Calculada a partir das validades, não fica gravada
+)
+
+type ConfigFidelidade struct {
+	DiasValidade int     `json:"dias_validade"` // Dias até os pontos acumulados expirarem
+	ValorPonto   float64 `json:"valor_ponto"`   // Desconto em reais de cada ponto resgatado
+}
+
+// Pontos por real líquido vendido, por produto, categoria ou padrão (sem os dois)
+type RegraFidelidade struct {
+	IDRegra       int64   `json:"id_regra"`
+	IDProduto     *int64  `json:"id_produto"`
+	Categoria     *string `json:"categoria"`
+	PontosPorReal float64 `json:"pontos_por_real"`
+}
+
+type RegraFidelidadeCreate struct {
+	IDProduto     *int64  `json:"id_produto"`
+	Categoria     *string `json:"categoria"`
+	PontosPorReal float64 `json:"pontos_por_real"`
+}
+
+func (rc *RegraFidelidadeCreate) ToRegraFidelidade() RegraFidelidade {
+	return RegraFidelidade{
+		IDProduto:     rc.IDProduto,
+		Categoria:     rc.Categoria,
+		PontosPorReal: rc.PontosPorReal,
+	}
+}
+
+func (rc *RegraFidelidadeCreate) Validar() error {
+	var v validacao.Erros
+	v.NaoNegativo("pontos_por_real", rc.PontosPorReal)
+	if rc.IDProduto != nil {
+		v.ID("id_produto", *rc.IDProduto)
+	}
+	if rc.IDProduto != nil && rc.Categoria != nil {
+		v.Add("categoria", "informe id_produto ou categoria, não os dois")
+	}
+	return v.Err()
+}
+
+// Linha do extrato de pontos. Créditos são positivos, débitos negativos.
+type MovimentoPontos struct {
+	IDMovimento   *int64     `json:"id_movimento"` // Nulo nas expirações
+	IDCliente     int64      `json:"id_cliente"`
+	IDVenda       *int64     `json:"id_venda"`
+	Tipo          string     `json:"tipo"`
+	Pontos        int64      `json:"pontos"`
+	DataHora      time.Time  `json:"data_hora"`
+	Validade      *time.Time `json:"validade"`
+	Motivo        *string    `json:"motivo"`
+	IDFuncionario *int64     `json:"id_funcionario"`
+}
+
+type PontosCliente struct {
+	IDCliente        int64             `json:"id_cliente"`
+	Saldo            int64             `json:"saldo"`       // Negativo quando um estorno leva pontos já usados
+	ValorSaldo       float64           `json:"valor_saldo"` // Desconto que o saldo vale hoje
+	ProximaExpiracao *time.Time        `json:"proxima_expiracao"`
+	PontosAExpirar   int64             `json:"pontos_a_expirar"` // Pontos que expiram na próxima expiração
+	Historico        []MovimentoPontos `json:"historico"`
+}
+
+// Ajuste manual de pontos, positivo ou negativo
+type AjustePontosCreate struct {
+	Pontos        int64  `json:"pontos"`
+	Motivo        string `json:"motivo"`
+	IDFuncionario int64  `json:"id_funcionario"` // Gerente que faz o ajuste
+}
+
+func (ac *AjustePontosCreate) Validar() error {
+	var v validacao.Erros
+	if ac.Pontos == 0 {
+		v.Add("pontos", "não pode ser zero")
+	}
+	v.Obrigatorio("motivo", ac.Motivo)
+	v.ID("id_funcionario", ac.IDFuncionario)
+	return v.Err()
+}
+
+type ResgatePontosCreate struct {
+	Pontos int64 `json:"pontos"`
+}
+
+func (rc *ResgatePontosCreate) Validar() error {
+	var v validacao.Erros
+	v.PositivoInt("pontos", rc.Pontos)
+	return v.Err()
+}
+
+// Pontos resgatados como desconto numa venda em aberto
+type AplicaPontos struct {
+	IDAplicaPontos int64   `json:"id_aplica_pontos"`
+	IDVenda        int64   `json:"id_venda"`
+	IDMovimento    int64   `json:"id_movimento"`
+	Pontos         int64   `json:"pontos"`
+	Desconto       float64 `json:"desconto"`
+	VendaTotais
+}
@@ -1,5 +1,9 @@
 package model
 
+import "edna/internal/validacao"
+
+// Tamanho das colunas nome, varchar(50) em todas as tabelas
+const TamanhoNome = 50
 
 type Fornecedor struct {
 	Id int64 `json:"id"`
@@ -18,3 +22,11 @@ func (fc FornecedorCreate) ToFornecedor() Fornecedor {
 		CNPJ: fc.CNPJ,
 	}
 }
+
+// O CNPJ é conferido à parte, junto com a normalização
+func (fc *FornecedorCreate) Validar() error {
+	var v validacao.Erros
+	v.Obrigatorio("nome", fc.Nome)
+	v.Tamanho("nome", fc.Nome, TamanhoNome)
+	return v.Err()
+}
@@ -1,5 +1,22 @@
 package model
 
+import (
+	"edna/internal/validacao"
+	"time"
+)
+
+const (
+	ExpedienteManha     = "manha"
+	ExpedienteTarde     = "tarde"
+	ExpedienteNoite     = "noite"
+	ExpedienteMadrugada = "madrugada"
+)
+
+var (
+	TiposFuncionario = []string{"garcom", "seguranca", "caixa", "faxineiro", "balconista", FuncionarioGerente}
+	Expedientes      = []string{ExpedienteManha, ExpedienteTarde, ExpedienteNoite, ExpedienteMadrugada}
+)
+
 type Funcionario struct {
 	Id              int64   `json:"id"`
 	Nome            string  `json:"nome"`
@@ -29,3 +46,17 @@ func (fc FuncionarioCreate) ToFuncionario() Funcionario {
 		DataContratacao: fc.DataContratacao,
 	}
 }
+
+// O CPF é conferido à parte, junto com a normalização
+func (fc *FuncionarioCreate) Validar() error {
+	var v validacao.Erros
+	v.Obrigatorio("nome", fc.Nome)
+	v.Tamanho("nome", fc.Nome, TamanhoNome)
+	v.Enum("tipo", fc.Tipo, TiposFuncionario...)
+	v.Enum("expediente", fc.Expediente, Expedientes...)
+	v.Positivo("salario", fc.Salario)
+	if _, err := time.Parse(time.DateOnly, fc.DataContratacao); err != nil {
+		v.Add("data_contratacao", "use o formato YYYY-MM-DD")
+	}
+	return v.Err()
+}
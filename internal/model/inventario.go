@@ -0,0 +1,112 @@
+package model
+
+import (
+	"edna/internal/validacao"
+	"time"
+)
+
+const (
+	InventarioAberto    = "aberto"
+	InventarioFechado   = "fechado"
+	InventarioAprovado  = "aprovado"
+	InventarioCancelado = "cancelado"
+
+	FuncionarioGerente = "gerente"
+)
+
+// Sessão de contagem física do estoque
+type Inventario struct {
+	IDInventario   int64                `json:"id_inventario"`
+	IDFuncionario  int64                `json:"id_funcionario"` // Quem abriu a contagem
+	Status         string               `json:"status"`
+	Observacao     *string              `json:"observacao"`
+	DataAbertura   time.Time            `json:"data_abertura"`
+	DataFechamento *time.Time           `json:"data_fechamento"`
+	IDAprovador    *int64               `json:"id_aprovador"`
+	DataAprovacao  *time.Time           `json:"data_aprovacao"`
+	Contagens      []ContagemInventario `json:"contagens"`
+}
+
+type InventarioCreate struct {
+	IDFuncionario int64   `json:"id_funcionario"`
+	Observacao    *string `json:"observacao"`
+}
+
+func (ic *InventarioCreate) ToInventario() Inventario {
+	return Inventario{
+		IDFuncionario: ic.IDFuncionario,
+		Observacao:    ic.Observacao,
+	}
+}
+
+func (ic *InventarioCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_funcionario", ic.IDFuncionario)
+	return v.Err()
+}
+
+// Quantidade contada de um produto inteiro ou, com id_lote, de um lote específico
+type ContagemInventario struct {
+	IDContagem    int64     `json:"id_contagem"`
+	IDInventario  int64     `json:"id_inventario"`
+	IDProduto     int64     `json:"id_produto"`
+	IDLote        *int64    `json:"id_lote"`
+	Quantidade    int64     `json:"quantidade"`
+	IDFuncionario *int64    `json:"id_funcionario"`
+	DataHora      time.Time `json:"data_hora"`
+}
+
+type ContagemInventarioCreate struct {
+	IDProduto     int64  `json:"id_produto"`
+	IDLote        *int64 `json:"id_lote"` // Opcional, sem lote a contagem vale para o produto todo
+	Quantidade    int64  `json:"quantidade"`
+	IDFuncionario *int64 `json:"id_funcionario"`
+}
+
+func (cc *ContagemInventarioCreate) ToContagemInventario(idInventario int64) ContagemInventario {
+	return ContagemInventario{
+		IDInventario:  idInventario,
+		IDProduto:     cc.IDProduto,
+		IDLote:        cc.IDLote,
+		Quantidade:    cc.Quantidade,
+		IDFuncionario: cc.IDFuncionario,
+	}
+}
+
+func (cc *ContagemInventarioCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_produto", cc.IDProduto)
+	if cc.IDLote != nil {
+		v.ID("id_lote", *cc.IDLote)
+	}
+	v.NaoNegativo("quantidade", float64(cc.Quantidade))
+	return v.Err()
+}
+
+type AprovacaoInventario struct {
+	IDFuncionario int64 `json:"id_funcionario"` // Gerente que aprova os ajustes
+}
+
+// Diferença entre o contado e o estoque calculado de um lote, valorizada pelo custo do lote.
+// Diferença negativa são unidades faltando.
+type DivergenciaInventario struct {
+	IDLote        int64   `json:"id_lote"`
+	IDProduto     int64   `json:"id_produto"`
+	Nome          string  `json:"nome"`
+	Esperado      int64   `json:"esperado"`
+	Contado       int64   `json:"contado"`
+	Diferenca     int64   `json:"diferenca"`
+	CustoUnitario float64 `json:"custo_unitario"`
+	Valor         float64 `json:"valor"`
+}
+
+type RelatorioInventario struct {
+	IDInventario     int64                   `json:"id_inventario"`
+	Status           string                  `json:"status"`
+	Itens            []DivergenciaInventario `json:"itens"`
+	UnidadesFaltando int64                   `json:"unidades_faltando"`
+	UnidadesSobrando int64                   `json:"unidades_sobrando"`
+	ValorFaltando    float64                 `json:"valor_faltando"`
+	ValorSobrando    float64                 `json:"valor_sobrando"`
+	ValorLiquido     float64                 `json:"valor_liquido"` // Sobras menos faltas
+}
@@ -1,5 +1,7 @@
 package model
 
+import "edna/internal/validacao"
+
 type ItemOferta struct {
 	Quantidade int64 `json:"quantidade"`
 	IDProduto  int64 `json:"id_produto"`
@@ -19,3 +21,10 @@ func (ioc ItemOfertaCreate) ToItemOferta() ItemOferta {
 		IDOferta:   ioc.IDOferta,
 	}
 }
+
+// Os ids podem vir do caminho na alteração, só a quantidade é conferida
+func (ioc *ItemOfertaCreate) Validar() error {
+	var v validacao.Erros
+	v.PositivoInt("quantidade", ioc.Quantidade)
+	return v.Err()
+}
@@ -1,25 +1,79 @@
 package model
 
+import (
+	"edna/internal/validacao"
+	"time"
+)
+
+// Itens de produtos com receita não têm lote, os ingredientes saem em Consumos.
 type ItemVenda struct {
-	IDItemVenda   int64   `json:"id_item_venda"`
-	IDVenda       int64   `json:"id_venda"`
-	IDLote        int64   `json:"id_lote"`
-	Quantidade    int64   `json:"quantidade"`
-	ValorUnitario float64 `json:"valor_unitario"`
+	IDItemVenda   int64            `json:"id_item_venda"`
+	IDVenda       int64            `json:"id_venda"`
+	IDLote        *int64           `json:"id_lote"`
+	IDProduto     int64            `json:"id_produto"`
+	Quantidade    int64            `json:"quantidade"`
+	ValorUnitario float64          `json:"valor_unitario"`
+	PrecoOverride *PrecoOverride   `json:"preco_override,omitempty"`
+	Consumos      []ConsumoReceita `json:"consumos,omitempty"`
+}
+
+// Preço diferente do catálogo informado pelo usuário, com o motivo.
+type PrecoOverride struct {
+	IDPrecoOverride int64     `json:"id_preco_override"`
+	IDItemVenda     int64     `json:"id_item_venda"`
+	PrecoCatalogo   float64   `json:"preco_catalogo"`
+	ValorUnitario   float64   `json:"valor_unitario"`
+	Motivo          string    `json:"motivo"`
+	DataHora        time.Time `json:"data_hora"`
 }
 
+// ValorUnitario é opcional: quando omitido usa o preço de venda do produto.
+// Um valor diferente do catálogo só é aceito com MotivoPreco.
 type ItemVendaCreate struct {
-	IDVenda       int64   `json:"id_venda"`
-	IDLote        int64   `json:"id_lote"`
-	Quantidade    int64   `json:"quantidade"`
-	ValorUnitario float64 `json:"valor_unitario"`
+	IDVenda       int64    `json:"id_venda"`
+	IDLote        int64    `json:"id_lote"`
+	Quantidade    int64    `json:"quantidade"`
+	ValorUnitario *float64 `json:"valor_unitario"`
+	MotivoPreco   *string  `json:"motivo_preco"`
 }
 
-func (ivc ItemVendaCreate) ToItemVenda() ItemVenda {
+func (ivc ItemVendaCreate) ToItemVenda(valorUnitario float64) ItemVenda {
+	idLote := ivc.IDLote
 	return ItemVenda{
 		IDVenda:       ivc.IDVenda,
-		IDLote:        ivc.IDLote,
+		IDLote:        &idLote,
 		Quantidade:    ivc.Quantidade,
-		ValorUnitario: ivc.ValorUnitario,
+		ValorUnitario: valorUnitario,
+	}
+}
+
+func (ivc *ItemVendaCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_venda", ivc.IDVenda)
+	v.ID("id_lote", ivc.IDLote)
+	v.PositivoInt("quantidade", ivc.Quantidade)
+	if ivc.ValorUnitario != nil {
+		v.Positivo("valor_unitario", *ivc.ValorUnitario)
+	}
+	return v.Err()
+}
+
+// Item de venda identificado pelo produto, o lote é escolhido pelo servidor.
+type ItemVendaProdutoCreate struct {
+	IDVenda       int64    `json:"id_venda"`
+	IDProduto     int64    `json:"id_produto"`
+	Quantidade    int64    `json:"quantidade"`
+	ValorUnitario *float64 `json:"valor_unitario"`
+	MotivoPreco   *string  `json:"motivo_preco"`
+}
+
+func (ivc *ItemVendaProdutoCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_venda", ivc.IDVenda)
+	v.ID("id_produto", ivc.IDProduto)
+	v.PositivoInt("quantidade", ivc.Quantidade)
+	if ivc.ValorUnitario != nil {
+		v.Positivo("valor_unitario", *ivc.ValorUnitario)
 	}
+	return v.Err()
 }
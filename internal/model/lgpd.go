@@ -0,0 +1,63 @@
+package model
+
+import (
+	"edna/internal/validacao"
+	"time"
+)
+
+const (
+	TitularCliente     = "cliente"
+	TitularFuncionario = "funcionario"
+
+	AcaoExportacao   = "exportacao"
+	AcaoAnonimizacao = "anonimizacao"
+)
+
+// Registro de uma exportação ou anonimização de dados pessoais
+type SolicitacaoLGPD struct {
+	IDSolicitacao int64     `json:"id_solicitacao"`
+	Titular       string    `json:"titular"`
+	IDTitular     int64     `json:"id_titular"`
+	Acao          string    `json:"acao"`
+	IDFuncionario *int64    `json:"id_funcionario"` // Quem atendeu a solicitação
+	Motivo        *string   `json:"motivo"`
+	DataHora      time.Time `json:"data_hora"`
+}
+
+type AnonimizacaoCreate struct {
+	IDFuncionario int64  `json:"id_funcionario"` // Gerente que autoriza a anonimização
+	Motivo        string `json:"motivo"`
+}
+
+func (ac *AnonimizacaoCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_funcionario", ac.IDFuncionario)
+	v.Obrigatorio("motivo", ac.Motivo)
+	return v.Err()
+}
+
+// Venda do titular com itens, totais e pagamentos
+type VendaTitular struct {
+	Venda
+	Itens      []ItemVenda `json:"itens"`
+	Pagamentos []Pagamento `json:"pagamentos"`
+	VendaTotais
+}
+
+// Tudo o que é guardado sobre um cliente
+type DadosPessoaisCliente struct {
+	Cliente
+	AnonimizadoEm *time.Time        `json:"anonimizado_em"`
+	Vendas        []VendaTitular    `json:"vendas"`
+	Pontos        []MovimentoPontos `json:"pontos"`
+	Solicitacoes  []SolicitacaoLGPD `json:"solicitacoes"`
+}
+
+// Tudo o que é guardado sobre um funcionário
+type DadosPessoaisFuncionario struct {
+	Funcionario
+	AnonimizadoEm   *time.Time        `json:"anonimizado_em"`
+	VendasAtendidas []int64           `json:"vendas_atendidas"` // IDs das vendas registradas por ele
+	SessoesCaixa    []CaixaSessao     `json:"sessoes_caixa"`
+	Solicitacoes    []SolicitacaoLGPD `json:"solicitacoes"`
+}
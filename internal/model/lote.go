@@ -1,6 +1,7 @@
 package model
 
 import (
+	"edna/internal/validacao"
 	"time"
 )
 
@@ -11,7 +12,7 @@ type Lote struct {
 	DataFornecimento  time.Time  `json:"data_fornecimento"`
 	Validade          *time.Time `json:"validade"`
 	PrecoUnitario     float64    `json:"preco_unitario"`
-	Estragados        *int       `json:"estragados"`
+	Estragados        *int       `json:"estragados"` // Derivado dos ajustes de estoque do lote
 	QuantidadeInicial *int       `json:"quantidade_inicial"`
 }
 
@@ -21,7 +22,6 @@ type LoteCreate struct {
 	DataFornecimento  time.Time  `json:"data_fornecimento"`
 	Validade          *time.Time `json:"validade"`
 	PrecoUnitario     float64    `json:"preco_unitario"`
-	Estragados        *int       `json:"estragados"`
 	QuantidadeInicial *int       `json:"quantidade_inicial"`
 }
 
@@ -32,7 +32,18 @@ func (lc LoteCreate) ToLote() Lote {
 		DataFornecimento:  lc.DataFornecimento,
 		Validade:          lc.Validade,
 		PrecoUnitario:     lc.PrecoUnitario,
-		Estragados:        lc.Estragados,
 		QuantidadeInicial: lc.QuantidadeInicial,
 	}
 }
+
+func (lc *LoteCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_fornecedor", lc.IdFornecedor)
+	v.ID("id_produto", lc.IdProduto)
+	v.Positivo("preco_unitario", lc.PrecoUnitario)
+	if lc.QuantidadeInicial != nil {
+		v.PositivoInt("quantidade_inicial", int64(*lc.QuantidadeInicial))
+	}
+	v.Ordem("data_fornecimento", &lc.DataFornecimento, "validade", lc.Validade)
+	return v.Err()
+}
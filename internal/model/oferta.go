@@ -1,6 +1,7 @@
 package model
 
 import (
+	"edna/internal/validacao"
 	"time"
 )
 
@@ -31,3 +32,17 @@ func (oc OfertaCreate) ToOferta() Oferta {
 		PercentualDesconto: oc.PercentualDesconto,
 	}
 }
+
+func (oc *OfertaCreate) Validar() error {
+	var v validacao.Erros
+	v.Obrigatorio("nome", oc.Nome)
+	v.Tamanho("nome", oc.Nome, TamanhoNome)
+	v.Ordem("data_inicio", oc.DataInicio, "data_fim", oc.DataFim)
+	if oc.ValorFixo != nil {
+		v.Positivo("valor_fixo", *oc.ValorFixo)
+	}
+	if oc.PercentualDesconto != nil && (*oc.PercentualDesconto < 1 || *oc.PercentualDesconto > 100) {
+		v.Add("percentual_desconto", "deve estar entre 1 e 100")
+	}
+	return v.Err()
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"edna/internal/validacao"
+	"time"
+)
+
+var (
+	// Formas aceitas em um pagamento. Fiado não é pagamento, é a venda em aberto.
+	TiposPagamento = []string{"credito", "debito", "pix", "dinheiro", "VA/VR"}
+	// Na venda também vale fiado, a venda fica em aberto
+	TiposPagamentoVenda = []string{"credito", "debito", "pix", "dinheiro", "VA/VR", "fiado"}
+)
+
+// Pagamento (parcial ou total) de uma venda
+type Pagamento struct {
+	IDPagamento   int64     `json:"id_pagamento"`
+	IDVenda       int64     `json:"id_venda"`
+	TipoPagamento string    `json:"tipo_pagamento"`
+	Valor         float64   `json:"valor"`
+	DataHora      time.Time `json:"data_hora"`
+}
+
+type PagamentoCreate struct {
+	TipoPagamento string     `json:"tipo_pagamento"`
+	Valor         float64    `json:"valor"`
+	DataHora      *time.Time `json:"data_hora"` // Opcional, padrão é o horário atual
+}
+
+func (pc *PagamentoCreate) ToPagamento(idVenda int64) Pagamento {
+	p := Pagamento{
+		IDVenda:       idVenda,
+		TipoPagamento: pc.TipoPagamento,
+		Valor:         pc.Valor,
+	}
+	if pc.DataHora != nil {
+		p.DataHora = *pc.DataHora
+	}
+	return p
+}
+
+func (pc *PagamentoCreate) Validar() error {
+	var v validacao.Erros
+	v.Enum("tipo_pagamento", pc.TipoPagamento, TiposPagamento...)
+	v.Positivo("valor", pc.Valor)
+	return v.Err()
+}
+
+// Pagamentos de uma venda junto com os totais atualizados
+type PagamentosVenda struct {
+	IDVenda    int64       `json:"id_venda"`
+	Pagamentos []Pagamento `json:"pagamentos"`
+	VendaTotais
+}
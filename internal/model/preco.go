@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Preço pago em uma entrega (lote) de um fornecedor
+type PontoPreco struct {
+	IDLote           int64     `json:"id_lote"`
+	DataFornecimento time.Time `json:"data_fornecimento"`
+	PrecoUnitario    float64   `json:"preco_unitario"`
+}
+
+// Histórico de preços de um fornecedor para um produto
+type PrecosFornecedorProduto struct {
+	IDFornecedor   int64        `json:"id_fornecedor"`
+	NomeFornecedor string       `json:"nome_fornecedor"`
+	IDProduto      int64        `json:"id_produto"`
+	NomeProduto    string       `json:"nome_produto"`
+	Entregas       int          `json:"entregas"`
+	Ultimo         float64      `json:"ultimo"`
+	Media          float64      `json:"media"`
+	Minimo         float64      `json:"minimo"`
+	Maximo         float64      `json:"maximo"`
+	Variacao       *float64     `json:"variacao"` // % do último preço sobre a entrega anterior
+	Alta           bool         `json:"alta"`     // Variação acima do limite
+	Serie          []PontoPreco `json:"serie"`
+}
+
+type PrecosFornecedor struct {
+	IDFornecedor int64                     `json:"id_fornecedor"`
+	Nome         string                    `json:"nome"`
+	LimiteAlta   float64                   `json:"limite_alta"`
+	Produtos     []PrecosFornecedorProduto `json:"produtos"`
+}
+
+type PrecosProduto struct {
+	IDProduto    int64                     `json:"id_produto"`
+	Nome         string                    `json:"nome"`
+	LimiteAlta   float64                   `json:"limite_alta"`
+	Fornecedores []PrecosFornecedorProduto `json:"fornecedores"`
+}
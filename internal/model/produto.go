@@ -1,5 +1,7 @@
 package model
 
+import "edna/internal/validacao"
+
 type Produto struct {
 	Id int64 `json:"id"`
 	Nome string `json:"nome"`
@@ -45,6 +47,24 @@ func (cc ComercialCreate) ToComercial() Comercial {
 	}
 }
 
+func (pc *ProdutoCreate) Validar() error {
+	var v validacao.Erros
+	v.Obrigatorio("nome", pc.Nome)
+	v.Tamanho("nome", pc.Nome, TamanhoNome)
+	return v.Err()
+}
+
+// Preço de catálogo é decimal(6, 2)
+func (cc *ComercialCreate) Validar() error {
+	var v validacao.Erros
+	v.Incluir("", cc.ProdutoCreate.Validar())
+	v.Positivo("preco_venda", float64(cc.PrecoVenda))
+	if cc.PrecoVenda >= 10000 {
+		v.Add("preco_venda", "deve ser menor que 10000")
+	}
+	return v.Err()
+}
+
 type ProdutoWithQnt struct {
 	Produto
 	Qnt uint64 `json:"quantidade_disponível"`
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"edna/internal/validacao"
+	"fmt"
+)
+
+// Ingrediente (produto estrutural) usado por unidade do produto comercial
+type ItemReceita struct {
+	IDIngrediente int64  `json:"id_ingrediente"`
+	Nome          string `json:"nome"`
+	Quantidade    int64  `json:"quantidade"`
+}
+
+// Ficha técnica de um produto comercial
+type Receita struct {
+	IDProduto    int64         `json:"id_produto"`
+	Nome         string        `json:"nome"`
+	Ingredientes []ItemReceita `json:"ingredientes"`
+}
+
+type ItemReceitaCreate struct {
+	IDIngrediente int64 `json:"id_ingrediente"`
+	Quantidade    int64 `json:"quantidade"`
+}
+
+type ReceitaCreate struct {
+	Ingredientes []ItemReceitaCreate `json:"ingredientes"`
+}
+
+func (rc *ReceitaCreate) ToReceita(idProduto int64) Receita {
+	ingredientes := make([]ItemReceita, 0, len(rc.Ingredientes))
+	for _, ic := range rc.Ingredientes {
+		ingredientes = append(ingredientes, ItemReceita{IDIngrediente: ic.IDIngrediente, Quantidade: ic.Quantidade})
+	}
+	return Receita{IDProduto: idProduto, Ingredientes: ingredientes}
+}
+
+func (rc *ReceitaCreate) Validar() error {
+	var v validacao.Erros
+	if len(rc.Ingredientes) == 0 {
+		v.Add("ingredientes", "precisa de ao menos um ingrediente")
+	}
+	for i, ic := range rc.Ingredientes {
+		campo := fmt.Sprintf("ingredientes[%d]", i)
+		v.ID(campo+".id_ingrediente", ic.IDIngrediente)
+		v.PositivoInt(campo+".quantidade", ic.Quantidade)
+	}
+	return v.Err()
+}
+
+// Quantidade de um ingrediente retirada de um lote por um item de venda com receita
+type ConsumoReceita struct {
+	IDConsumo     int64 `json:"id_consumo"`
+	IDItemVenda   int64 `json:"id_item_venda"`
+	IDLote        int64 `json:"id_lote"`
+	IDIngrediente int64 `json:"id_ingrediente"`
+	Quantidade    int64 `json:"quantidade"`
+}
@@ -50,3 +50,103 @@ type RelatorioFolhaPagamento struct {
     TotalGeralFolha   float64                `json:"total_geral_folha"`
     FolhasPorMes      []FolhaPagamentoMensal `json:"folhas_por_mes"`
 }
+
+type PerdaMotivo struct {
+    Motivo   string  `json:"motivo"`
+    Unidades int64   `json:"unidades"`
+    Custo    float64 `json:"custo"`
+}
+
+type PerdaProduto struct {
+    IdProduto int64         `json:"id_produto"`
+    Nome      string        `json:"nome"`
+    Unidades  int64         `json:"unidades"`
+    Custo     float64       `json:"custo"`
+    PorMotivo []PerdaMotivo `json:"por_motivo"`
+}
+
+// Perdas de estoque (ajustes) no período. Custo é calculado pelo preço unitário do lote.
+type RelatorioPerdas struct {
+    PeriodStart   string         `json:"period_start"`
+    PeriodEnd     string         `json:"period_end"`
+    TotalUnidades int64          `json:"total_unidades"`
+    TotalCusto    float64        `json:"total_custo"`
+    PorMotivo     []PerdaMotivo  `json:"por_motivo"`
+    PorProduto    []PerdaProduto `json:"por_produto"`
+}
+
+// Desempenho de um fornecedor nos lotes entregues no período.
+// Rank 1 é o melhor: maior gasto, mais unidades, menor perda e maior validade.
+type DesempenhoFornecedor struct {
+    IdFornecedor      int64    `json:"id_fornecedor"`
+    Nome              string   `json:"nome"`
+    Lotes             int64    `json:"lotes"`
+    TotalGasto        float64  `json:"total_gasto"`
+    Unidades          int64    `json:"unidades"`
+    Estragados        int64    `json:"estragados"`
+    PercentualPerda   float64  `json:"percentual_perda"`
+    ValidadeMediaDias *float64 `json:"validade_media_dias"` // Dias entre entrega e validade, só lotes com validade
+    RankGasto         int      `json:"rank_gasto"`
+    RankUnidades      int      `json:"rank_unidades"`
+    RankPerda         int      `json:"rank_perda"`
+    RankValidade      *int     `json:"rank_validade"`
+}
+
+type RelatorioFornecedores struct {
+    PeriodStart  string                 `json:"period_start"`
+    PeriodEnd    string                 `json:"period_end"`
+    Fornecedores []DesempenhoFornecedor `json:"fornecedores"`
+}
+
+// Receita, custo das mercadorias vendidas (CMV) e margem bruta de um grupo
+type Margem struct {
+    Unidades         int64   `json:"unidades"`
+    Receita          float64 `json:"receita"`
+    Custo            float64 `json:"custo"`
+    MargemBruta      float64 `json:"margem_bruta"`
+    MargemPercentual float64 `json:"margem_percentual"` // Margem bruta sobre a receita
+}
+
+type MargemProduto struct {
+    IdProduto int64  `json:"id_produto"`
+    Nome      string `json:"nome"`
+    Categoria string `json:"categoria"`
+    Marca     string `json:"marca"`
+    Margem
+}
+
+type MargemGrupo struct {
+    Nome string `json:"nome"`
+    Margem
+}
+
+// Margem bruta das vendas no período. Receita é o valor dos itens menos os descontos
+// de ofertas; o custo vem do lote vendido ou do custo médio ponderado do produto.
+type RelatorioMargem struct {
+    PeriodStart  string          `json:"period_start"`
+    PeriodEnd    string          `json:"period_end"`
+    MetodoCusto  string          `json:"metodo_custo"`
+    Total        Margem          `json:"total"`
+    PorProduto   []MargemProduto `json:"por_produto"`
+    PorCategoria []MargemGrupo   `json:"por_categoria"`
+    PorMarca     []MargemGrupo   `json:"por_marca"`
+}
+
+const (
+    DocumentoInvalido  = "invalido"  // Dígitos verificadores errados ou fora do formato só com dígitos
+    DocumentoDuplicado = "duplicado" // Mesmo documento em mais de um cadastro da mesma tabela
+)
+
+// Documento gravado que não passa na validação de CPF/CNPJ
+type ProblemaDocumento struct {
+    Cadastro  string `json:"cadastro"` // cliente, funcionario ou fornecedor
+    Id        int64  `json:"id"`
+    Nome      string `json:"nome"`
+    Documento string `json:"documento"`
+    Motivo    string `json:"motivo"`
+}
+
+type RelatorioDocumentos struct {
+    Conferidos int                 `json:"conferidos"`
+    Problemas  []ProblemaDocumento `json:"problemas"`
+}
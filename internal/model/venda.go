@@ -1,6 +1,9 @@
 package model
 
 import (
+	"edna/internal/validacao"
+	"fmt"
+	"slices"
 	"time"
 )
 
@@ -26,7 +29,111 @@ func (vc *VendaCreate) ToVenda() Venda {
 		IdCliente:         vc.IdCliente,
 		IdFuncionario:     vc.IdFuncionario,
 		DataHoraVenda:     vc.DataHoraVenda,
+		TipoPagamento:     vc.TipoPagamento,
+	}
+}
+
+// Sem tipo de pagamento a venda fica sem forma definida (NULL). A data de
+// pagamento não é aceita, a venda só é quitada pelos pagamentos.
+func (vc *VendaCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_cliente", vc.IdCliente)
+	v.ID("id_funcionario", vc.IdFuncionario)
+	if vc.TipoPagamento != "" {
+		v.Enum("tipo_pagamento", vc.TipoPagamento, TiposPagamentoVenda...)
+	}
+	if vc.DataHoraPagamento != nil {
+		v.Add("data_hora_pagamento", "a venda é quitada pelos pagamentos, use POST /vendas/{id}/pagamentos")
+	}
+	return v.Err()
+}
+
+// Item de uma venda completa, identificado pelo produto e não pelo lote.
+type VendaCompletaItem struct {
+	IdProduto     int64    `json:"id_produto"`
+	Quantidade    int64    `json:"quantidade"`
+	ValorUnitario *float64 `json:"valor_unitario"`
+	MotivoPreco   *string  `json:"motivo_preco"`
+	IdOferta      *int64   `json:"id_oferta"`
+
+	// Preenchido pelo servidor após a resolução de preço
+	PrecoOverride *PrecoOverride `json:"-"`
+}
+
+func (it *VendaCompletaItem) Validar() error {
+	var v validacao.Erros
+	v.ID("id_produto", it.IdProduto)
+	v.PositivoInt("quantidade", it.Quantidade)
+	if it.ValorUnitario != nil {
+		v.Positivo("valor_unitario", *it.ValorUnitario)
+	}
+	if it.IdOferta != nil {
+		v.ID("id_oferta", *it.IdOferta)
+	}
+	return v.Err()
+}
+
+// Payload para abrir, preencher e fechar uma venda em uma única transação.
+type VendaCompletaCreate struct {
+	IdCliente         int64               `json:"id_cliente"`
+	IdFuncionario     int64               `json:"id_funcionario"`
+	DataHoraPagamento *time.Time          `json:"data_hora_pagamento"`
+	TipoPagamento     string              `json:"tipo_pagamento"`
+	Itens             []VendaCompletaItem `json:"itens"`
+	Pagamentos        []PagamentoCreate   `json:"pagamentos"`
+}
+
+func (vc *VendaCompletaCreate) Validar() error {
+	var v validacao.Erros
+	v.ID("id_cliente", vc.IdCliente)
+	v.ID("id_funcionario", vc.IdFuncionario)
+	if vc.TipoPagamento != "" {
+		v.Enum("tipo_pagamento", vc.TipoPagamento, TiposPagamentoVenda...)
+	}
+	// Sem pagamentos, a data de pagamento quita a venda com um pagamento do tipo informado
+	if vc.DataHoraPagamento != nil && len(vc.Pagamentos) == 0 && !slices.Contains(TiposPagamento, vc.TipoPagamento) {
+		v.Add("tipo_pagamento", "para quitar a venda informe um tipo de pagamento que não seja fiado")
+	}
+	if len(vc.Itens) == 0 {
+		v.Add("itens", "a venda precisa de pelo menos um item")
+	}
+	for i, it := range vc.Itens {
+		v.Incluir(fmt.Sprintf("itens[%d]", i), it.Validar())
+	}
+	for i, p := range vc.Pagamentos {
+		v.Incluir(fmt.Sprintf("pagamentos[%d]", i), p.Validar())
+	}
+	return v.Err()
+}
+
+type VendaCompleta struct {
+	Venda
+	Itens      []ItemVenda    `json:"itens"`
+	Ofertas    []AplicaOferta `json:"ofertas"`
+	Pagamentos []Pagamento    `json:"pagamentos"`
+}
+
+func (vc *VendaCompletaCreate) ToVenda() Venda {
+	return Venda{
+		IdCliente:         vc.IdCliente,
+		IdFuncionario:     vc.IdFuncionario,
 		DataHoraPagamento: vc.DataHoraPagamento,
 		TipoPagamento:     vc.TipoPagamento,
 	}
 }
+
+const (
+	StatusPagamentoPago     = "pago"
+	StatusPagamentoParcial  = "parcial"
+	StatusPagamentoPendente = "pendente"
+)
+
+// Totais de uma venda, calculados pela view venda_totais
+type VendaTotais struct {
+	TotalBruto      float64 `json:"total_bruto"`
+	TotalDesconto   float64 `json:"total_desconto"`
+	TotalLiquido    float64 `json:"total_liquido"`
+	TotalPago       float64 `json:"total_pago"`
+	Restante        float64 `json:"restante"`
+	StatusPagamento string  `json:"status_pagamento"`
+}
@@ -1,15 +1,26 @@
 package server
 
 import (
+	"edna/internal/services/alerta"
 	"edna/internal/services/aplica_oferta"
+	"edna/internal/services/caixa"
 	"edna/internal/services/cliente"
+	"edna/internal/services/compras"
+	"edna/internal/services/desconto"
+	"edna/internal/services/escala"
+	"edna/internal/services/estoque"
+	"edna/internal/services/fidelidade"
 	"edna/internal/services/fornecedor"
 	"edna/internal/services/funcionario"
+	"edna/internal/services/inventario"
 	"edna/internal/services/item_oferta"
 	"edna/internal/services/item_venda"
+	"edna/internal/services/lgpd"
 	"edna/internal/services/lote"
 	"edna/internal/services/oferta"
+	"edna/internal/services/pagamento"
 	"edna/internal/services/produto"
+	"edna/internal/services/receita"
 	"edna/internal/services/relatorio"
 	"edna/internal/services/venda"
 	"encoding/json"
@@ -26,17 +37,28 @@ func (s *Server) RegisterRoutes() http.Handler {
 	v1 := http.NewServeMux()
 	mux := http.NewServeMux()
 
-	itemVendaHandler := item_venda.NewHandler(s.itemVendaStore)
+	itemVendaHandler := item_venda.NewHandler(s.itemVendaStore, s.produtoStore)
 	fornecedorHandler := fornecedor.NewHandler(s.fornecedorStore)
-	produtoHandler := produto.NewHandler(s.produtoStore)
+	produtoHandler := produto.NewHandler(s.produtoStore, s.fornecedorStore)
 	clienteHandler := cliente.NewHandler(s.clienteStore)
 	loteHandler := lote.NewHandler(s.loteStore)
 	ofertaHandler := oferta.NewHandler(s.ofertaStore)
-	vendaHandler := venda.NewHandler(s.vendaStore)
+	vendaHandler := venda.NewHandler(s.vendaStore, s.produtoStore, s.itemVendaStore, s.aplicaOfertaStore)
 	relatorioHandler := relatorio.NewHandler(s.relatorioStore)
 	funcionarioHandler := funcionario.NewHandler(s.funcionarioStore)
 	itemOfertaHandler := item_oferta.NewHandler(s.itemOfertaStore)
 	aplicaOfertaHandler := aplica_oferta.NewHandler(s.aplicaOfertaStore)
+	descontoHandler := desconto.NewHandler(s.descontoStore)
+	pagamentoHandler := pagamento.NewHandler(s.pagamentoStore)
+	caixaHandler := caixa.NewHandler(s.caixaStore)
+	estoqueHandler := estoque.NewHandler(s.estoqueStore)
+	alertaHandler := alerta.NewHandler(s.alertaStore)
+	comprasHandler := compras.NewHandler(s.comprasStore)
+	receitaHandler := receita.NewHandler(s.receitaStore)
+	inventarioHandler := inventario.NewHandler(s.inventarioStore)
+	fidelidadeHandler := fidelidade.NewHandler(s.fidelidadeStore)
+	lgpdHandler := lgpd.NewHandler(s.lgpdStore)
+	escalaHandler := escala.NewHandler(s.escalaStore)
 
 	mux.HandleFunc("/health", s.healthHandler)
 	fornecedorHandler.RegisterRoutes(mux)
@@ -50,6 +72,17 @@ func (s *Server) RegisterRoutes() http.Handler {
 	itemVendaHandler.RegisterRoutes(mux)
 	itemOfertaHandler.RegisterRoutes(mux)
 	aplicaOfertaHandler.RegisterRoutes(mux)
+	descontoHandler.RegisterRoutes(mux)
+	pagamentoHandler.RegisterRoutes(mux)
+	caixaHandler.RegisterRoutes(mux)
+	estoqueHandler.RegisterRoutes(mux)
+	alertaHandler.RegisterRoutes(mux)
+	comprasHandler.RegisterRoutes(mux)
+	receitaHandler.RegisterRoutes(mux)
+	inventarioHandler.RegisterRoutes(mux)
+	fidelidadeHandler.RegisterRoutes(mux)
+	lgpdHandler.RegisterRoutes(mux)
+	escalaHandler.RegisterRoutes(mux)
 
 	// Register routes
 	v1.HandleFunc("/", s.trailingSlashHandler)
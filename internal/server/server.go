@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -10,15 +11,26 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 
 	"edna/internal/database"
+	"edna/internal/services/alerta"
 	"edna/internal/services/aplica_oferta"
+	"edna/internal/services/caixa"
 	"edna/internal/services/cliente"
+	"edna/internal/services/compras"
+	"edna/internal/services/desconto"
+	"edna/internal/services/escala"
+	"edna/internal/services/estoque"
+	"edna/internal/services/fidelidade"
 	"edna/internal/services/fornecedor"
 	"edna/internal/services/funcionario"
+	"edna/internal/services/inventario"
 	"edna/internal/services/item_oferta"
 	"edna/internal/services/item_venda"
+	"edna/internal/services/lgpd"
 	"edna/internal/services/lote"
 	"edna/internal/services/oferta"
+	"edna/internal/services/pagamento"
 	"edna/internal/services/produto"
+	"edna/internal/services/receita"
 	"edna/internal/services/relatorio"
 	"edna/internal/services/venda"
 )
@@ -38,6 +50,17 @@ type Server struct {
 	itemOfertaStore   *item_oferta.Store
 	itemVendaStore    *item_venda.Store
 	aplicaOfertaStore *aplica_oferta.Store
+	descontoStore     *desconto.Store
+	pagamentoStore    *pagamento.Store
+	caixaStore        *caixa.Store
+	estoqueStore      *estoque.Store
+	alertaStore       *alerta.Store
+	comprasStore      *compras.Store
+	receitaStore      *receita.Store
+	inventarioStore   *inventario.Store
+	fidelidadeStore   *fidelidade.Store
+	lgpdStore         *lgpd.Store
+	escalaStore       *escala.Store
 }
 
 func NewServer() *http.Server {
@@ -60,6 +83,17 @@ func NewServer() *http.Server {
 		itemVendaStore:    item_venda.NewStore(db.Conn()),
 		itemOfertaStore:   item_oferta.NewStore(db.Conn()),
 		aplicaOfertaStore: aplica_oferta.NewStore(db.Conn()),
+		descontoStore:     desconto.NewStore(db.Conn()),
+		pagamentoStore:    pagamento.NewStore(db.Conn()),
+		caixaStore:        caixa.NewStore(db.Conn()),
+		estoqueStore:      estoque.NewStore(db.Conn()),
+		alertaStore:       alerta.NewStore(db.Conn()),
+		comprasStore:      compras.NewStore(db.Conn()),
+		receitaStore:      receita.NewStore(db.Conn()),
+		inventarioStore:   inventario.NewStore(db.Conn()),
+		fidelidadeStore:   fidelidade.NewStore(db.Conn()),
+		lgpdStore:         lgpd.NewStore(db.Conn()),
+		escalaStore:       escala.NewStore(db.Conn()),
 		funcionarioStore:  funcionario.NewStore(db.Conn()),
 		relatorioStore:    relatorio.NewStore(db.Conn()),
 	}
@@ -73,5 +107,14 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// Verificação diária de validade dos lotes, encerrada junto com o servidor
+	diasAlerta, err := strconv.Atoi(os.Getenv("ALERTA_VALIDADE_DIAS"))
+	if err != nil || diasAlerta < 0 {
+		diasAlerta = lote.DiasVencendoPadrao
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go alerta.NewRotina(NewServer.loteStore, NewServer.alertaStore, diasAlerta).Run(ctx)
+	server.RegisterOnShutdown(cancel)
+
 	return server
 }
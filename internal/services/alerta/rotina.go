@@ -0,0 +1,57 @@
+package alerta
+
+import (
+	"context"
+	"edna/internal/model"
+	"log"
+	"time"
+)
+
+// Fonte dos lotes vencendo, implementada pelo store de lote.
+type VencendoStore interface {
+	GetVencendo(ctx context.Context, dias int) ([]model.LoteVencendo, error)
+}
+
+// Rotina que verifica a validade dos lotes e registra alertas.
+type Rotina struct {
+	lotes     VencendoStore
+	store     *Store
+	dias      int
+	intervalo time.Duration
+}
+
+func NewRotina(lotes VencendoStore, store *Store, dias int) *Rotina {
+	return &Rotina{lotes, store, dias, 24 * time.Hour}
+}
+
+// Executa a verificação ao iniciar e depois uma vez por intervalo, até o contexto ser cancelado.
+func (r *Rotina) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.intervalo)
+	defer ticker.Stop()
+
+	for {
+		if err := r.verificar(ctx); err != nil {
+			log.Printf("alerta de validade: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Rotina) verificar(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	lotes, err := r.lotes.GetVencendo(ctx, r.dias)
+	if err != nil {
+		return err
+	}
+	if err := r.store.Registrar(ctx, lotes); err != nil {
+		return err
+	}
+	log.Printf("alerta de validade: %d lotes vencendo nos próximos %d dias", len(lotes), r.dias)
+	return nil
+}
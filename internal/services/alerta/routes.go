@@ -0,0 +1,81 @@
+package alerta
+
+import (
+	"context"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"net/http"
+)
+
+type Handler struct {
+	store AlertaStore
+}
+
+type AlertaStore interface {
+	GetAll(ctx context.Context, todos bool) ([]model.AlertaValidade, error)
+	MarcarVisto(ctx context.Context, id int64) error
+}
+
+func NewHandler(store AlertaStore) *Handler {
+	return &Handler{store}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /alertas/validade", h.getAll)
+	mux.HandleFunc("POST /alertas/validade/{id}/visto", h.marcarVisto)
+}
+
+// @Summary List expiry alerts
+// @Description Alerts recorded by the daily expiry check. Only unseen alerts unless todos=true.
+// @Tags Alerta
+// @Produce json
+// @Param todos query bool false "Include alerts already seen"
+// @Success 200 {array} model.AlertaValidade
+// @Failure 500 {object} types.ErrorResponse
+// @Router /alertas/validade [get]
+func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	todos := r.URL.Query().Get("todos") == "true"
+	alertas, err := h.store.GetAll(ctx, todos)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, alertas); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Mark an expiry alert as seen
+// @Tags Alerta
+// @Param id path int true "Alerta ID"
+// @Success 204
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /alertas/validade/{id}/visto [post]
+func (h *Handler) marcarVisto(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.MarcarVisto(ctx, id); err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Alerta not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
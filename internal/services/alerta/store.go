@@ -0,0 +1,95 @@
+package alerta
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db}
+}
+
+// Registra os lotes vencendo como alertas. Lotes já alertados têm a quantidade
+// atualizada, e alertas não vistos de lotes que saíram da lista são removidos.
+func (s *Store) Registrar(ctx context.Context, lotes []model.LoteVencendo) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO alerta_validade (id_lote, validade, quantidade)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id_lote) DO UPDATE SET
+			validade = EXCLUDED.validade,
+			quantidade = EXCLUDED.quantidade,
+			atualizado_em = now();`
+	ids := make([]int64, 0, len(lotes))
+	for _, l := range lotes {
+		if _, err := tx.ExecContext(ctx, query, l.IDLote, l.Validade, l.Disponivel); err != nil {
+			return err
+		}
+		ids = append(ids, l.IDLote)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM alerta_validade WHERE visto_em IS NULL AND NOT (id_lote = ANY($1))", ids)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Alertas com os dados atuais do lote. Sem todos, só os ainda não vistos.
+func (s *Store) GetAll(ctx context.Context, todos bool) ([]model.AlertaValidade, error) {
+	query := `
+		SELECT a.id_alerta, a.id_lote, l.id_produto, p.nome, l.id_fornecedor, f.nome,
+			a.validade, (a.validade - CURRENT_DATE) AS dias_restantes, COALESCE(el.disponivel, a.quantidade),
+			a.criado_em, a.atualizado_em, a.visto_em
+		FROM alerta_validade a
+		JOIN Lote l ON l.id_lote = a.id_lote
+		JOIN Produto p ON p.id_produto = l.id_produto
+		JOIN Fornecedor f ON f.id_fornecedor = l.id_fornecedor
+		LEFT JOIN estoque_lote el ON el.id_lote = a.id_lote
+		WHERE $1 OR a.visto_em IS NULL
+		ORDER BY a.validade, a.id_lote;`
+	rows, err := s.db.QueryContext(ctx, query, todos)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alertas := make([]model.AlertaValidade, 0)
+	for rows.Next() {
+		var a model.AlertaValidade
+		err := rows.Scan(&a.IDAlerta, &a.IDLote, &a.IDProduto, &a.NomeProduto, &a.IDFornecedor, &a.NomeFornecedor,
+			&a.Validade, &a.DiasRestantes, &a.Disponivel,
+			&a.CriadoEm, &a.AtualizadoEm, &a.VistoEm)
+		if err != nil {
+			return nil, err
+		}
+		alertas = append(alertas, a)
+	}
+	return alertas, rows.Err()
+}
+
+func (s *Store) MarcarVisto(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE alerta_validade SET visto_em = COALESCE(visto_em, now()) WHERE id_alerta = $1", id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return types.ErrNotFound
+	}
+	return nil
+}
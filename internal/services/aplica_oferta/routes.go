@@ -3,9 +3,11 @@ package aplica_oferta
 import (
 	"context"
 	"edna/internal/model"
+	"edna/internal/services/desconto"
 	"edna/internal/types"
 	"edna/internal/util"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -39,19 +41,19 @@ func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
 
 	filters, err := NewAplicaOfertaFilter(r.URL.Query())
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	aplicaOfertas, err := h.store.GetAll(ctx, filters)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	err = util.WriteJSON(w, http.StatusOK, aplicaOfertas)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
@@ -71,10 +73,15 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToAplicaOferta()
 	err = h.store.Create(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		writeAplicaOfertaError(w, err, "Venda not found.")
 		return
 	}
 
@@ -97,7 +104,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "Oferta not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -124,15 +131,16 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToAplicaOferta()
 	model.IDAplicaOferta = id
 	err = h.store.Update(ctx, &model)
 	if err != nil {
-		if err == types.ErrNotFound {
-			util.ErrorJSON(w, "Oferta not found.", http.StatusNotFound)
-			return
-		}
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		writeAplicaOfertaError(w, err, "Oferta not found.")
 		return
 	}
 
@@ -155,9 +163,23 @@ func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "Oferta not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
 	util.WriteJSON(w, http.StatusOK, model)
 }
+
+// Erros do cálculo do desconto ao aplicar a oferta
+func writeAplicaOfertaError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		util.ErrorJSON(w, notFound, http.StatusNotFound)
+	case errors.Is(err, desconto.ErrVendaFechada):
+		util.ErrorJSON(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, desconto.ErrOfertaNaoAplicavel):
+		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"edna/internal/model"
+	"edna/internal/services/desconto"
 	"edna/internal/types"
 	"edna/internal/util"
+	"slices"
 )
 
 type Store struct {
@@ -28,7 +30,7 @@ type AplicaOfertaDetail struct {
 func (s *Store) GetByVendaID(ctx context.Context, idVenda int64) ([]AplicaOfertaDetail, error) {
 	query := `
 		SELECT
-			ao.id_aplica_oferta, ao.id_oferta, ao.id_venda, ao.id_item_venda,
+			ao.id_aplica_oferta, ao.id_oferta, ao.id_venda, ao.id_item_venda, ao.desconto,
 			o.nome, o.valor_fixo, o.percentual_desconto
 		FROM
 			aplica_oferta ao
@@ -47,7 +49,7 @@ func (s *Store) GetByVendaID(ctx context.Context, idVenda int64) ([]AplicaOferta
 	for rows.Next() {
 		var o AplicaOfertaDetail
 		err := rows.Scan(
-			&o.IDAplicaOferta, &o.IDOferta, &o.IDVenda, &o.IDItemVenda,
+			&o.IDAplicaOferta, &o.IDOferta, &o.IDVenda, &o.IDItemVenda, &o.Desconto,
 			&o.NomeOferta, &o.ValorFixo, &o.PercentualDesconto,
 		)
 		if err != nil {
@@ -69,7 +71,7 @@ func (s *Store) DeleteByItemVendaID(ctx context.Context, idItemVenda int64) erro
 func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.AplicaOferta, error) {
 
 	query := `
-		SELECT id_aplica_oferta, id_oferta, id_venda, id_item_venda
+		SELECT id_aplica_oferta, id_oferta, id_venda, id_item_venda, desconto
 		FROM aplica_oferta
 	`
 
@@ -83,7 +85,7 @@ func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.AplicaO
 	for rows.Next() {
 		var c model.AplicaOferta
 
-		err := rows.Scan(&c.IDAplicaOferta, &c.IDOferta, &c.IDVenda, &c.IDItemVenda)
+		err := rows.Scan(&c.IDAplicaOferta, &c.IDOferta, &c.IDVenda, &c.IDItemVenda, &c.Desconto)
 
 		if err != nil {
 			return nil, err
@@ -97,7 +99,7 @@ func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.AplicaO
 
 func (s *Store) GetByID(ctx context.Context, id int64) (*model.AplicaOferta, error) {
 	query := `
-		SELECT id_aplica_oferta, id_oferta, id_venda, id_item_venda
+		SELECT id_aplica_oferta, id_oferta, id_venda, id_item_venda, desconto
 		FROM aplica_oferta
 		WHERE id_aplica_oferta = $1
 	`
@@ -106,7 +108,7 @@ func (s *Store) GetByID(ctx context.Context, id int64) (*model.AplicaOferta, err
 
 	var c model.AplicaOferta
 
-	err := row.Scan(&c.IDAplicaOferta, &c.IDOferta, &c.IDVenda, &c.IDItemVenda)
+	err := row.Scan(&c.IDAplicaOferta, &c.IDOferta, &c.IDVenda, &c.IDItemVenda, &c.Desconto)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -118,49 +120,91 @@ func (s *Store) GetByID(ctx context.Context, id int64) (*model.AplicaOferta, err
 	return &c, nil
 }
 
+// O desconto vem do motor de ofertas, com a oferta avaliada sozinha na venda.
+// Em um combo cada item recebe a sua parte do desconto.
 func (s *Store) Create(ctx context.Context, c *model.AplicaOferta) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := desconto.TravarVendaAberta(ctx, tx, c.IDVenda); err != nil {
+		return err
+	}
+	if c.Desconto, err = descontoItem(ctx, tx, c); err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO aplica_oferta (id_oferta, id_venda, id_item_venda)
-		VALUES ($1, $2, $3)
+		INSERT INTO aplica_oferta (id_oferta, id_venda, id_item_venda, desconto)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id_aplica_oferta
 	`
-
-	res := s.db.QueryRowContext(ctx, query, c.IDOferta, c.IDVenda, c.IDItemVenda)
-	return res.Scan(&c.IDAplicaOferta)
+	if err := tx.QueryRowContext(ctx, query, c.IDOferta, c.IDVenda, c.IDItemVenda, c.Desconto).Scan(&c.IDAplicaOferta); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *Store) Update(ctx context.Context, c *model.AplicaOferta) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var idVendaAtual int64
+	err = tx.QueryRowContext(ctx, "SELECT id_venda FROM aplica_oferta WHERE id_aplica_oferta = $1", c.IDAplicaOferta).Scan(&idVendaAtual)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.ErrNotFound
+		}
+		return err
+	}
+	// As duas vendas mudam de total, na ordem do id para não travar em ciclo
+	for _, id := range slices.Compact([]int64{min(idVendaAtual, c.IDVenda), max(idVendaAtual, c.IDVenda)}) {
+		if _, err := desconto.TravarVendaAberta(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	if c.Desconto, err = descontoItem(ctx, tx, c); err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE aplica_oferta
-		SET id_oferta = $2, id_venda = $3, id_item_venda = $4
+		SET id_oferta = $2, id_venda = $3, id_item_venda = $4, desconto = $5
 		WHERE id_aplica_oferta = $1
 	`
-
-	res, err := s.db.ExecContext(ctx, query, c.IDAplicaOferta, c.IDOferta, c.IDVenda, c.IDItemVenda)
-
-	if err != nil {
+	if _, err := tx.ExecContext(ctx, query, c.IDAplicaOferta, c.IDOferta, c.IDVenda, c.IDItemVenda, c.Desconto); err != nil {
 		return err
 	}
-	rowsAffected, err := res.RowsAffected()
+	return tx.Commit()
+}
+
+func descontoItem(ctx context.Context, tx *sql.Tx, c *model.AplicaOferta) (float64, error) {
+	descontos, err := desconto.DescontosOferta(ctx, tx, c.IDVenda, c.IDOferta)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	if rowsAffected == 0 {
-		return types.ErrNotFound
+	d, ok := descontos[c.IDItemVenda]
+	if !ok {
+		return 0, desconto.ErrOfertaNaoAplicavel
 	}
-	return nil
+	return d, nil
 }
 
 func (s *Store) Delete(ctx context.Context, id int64) (*model.AplicaOferta, error) {
 	query := `
 		DELETE FROM aplica_oferta
 		WHERE id_aplica_oferta = $1
-		RETURNING id_aplica_oferta, id_oferta, id_venda, id_item_venda
+		RETURNING id_aplica_oferta, id_oferta, id_venda, id_item_venda, desconto
 	`
 
 	var a model.AplicaOferta
 	row := s.db.QueryRowContext(ctx, query, id)
-	err := row.Scan(&a.IDAplicaOferta, &a.IDOferta, &a.IDVenda, &a.IDItemVenda)
+	err := row.Scan(&a.IDAplicaOferta, &a.IDOferta, &a.IDVenda, &a.IDItemVenda, &a.Desconto)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
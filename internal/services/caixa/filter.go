@@ -0,0 +1,38 @@
+package caixa
+
+import (
+	"edna/internal/util"
+	"net/url"
+)
+
+func NewCaixaSessaoFilter(params url.Values) (util.Filter, error) {
+	var filter util.Filter
+	if err := filter.GetOffset(params); err != nil {
+		return filter, err
+	}
+
+	if err := filter.GetLimit(params); err != nil {
+		return filter, err
+	}
+
+	attrs := []string{"data_hora_abertura", "data_hora_fechamento", "id_funcionario", "expediente"}
+
+	if err := filter.GetSorts(params, attrs); err != nil {
+		return filter, err
+	}
+
+	if err := filter.GetFilterStr(params, "expediente"); err != nil {
+		return filter, err
+	}
+
+	if err := filter.GetFilterInt(params, "id_funcionario"); err != nil {
+		return filter, err
+	}
+
+	for _, attr := range []string{"data_hora_abertura", "data_hora_fechamento"} {
+		if err := filter.GetFilterTime(params, attr); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
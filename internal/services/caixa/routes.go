@@ -0,0 +1,264 @@
+package caixa
+
+import (
+	"context"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type Handler struct {
+	store CaixaStore
+}
+
+type CaixaStore interface {
+	GetAll(ctx context.Context, filter util.Filter) ([]model.CaixaSessao, error)
+	GetByID(ctx context.Context, id int64) (*model.CaixaSessao, error)
+	Abrir(ctx context.Context, c *model.CaixaSessao) error
+	AddMovimento(ctx context.Context, m *model.CaixaMovimento) error
+	Fechar(ctx context.Context, id int64, valorContado float64) (*model.CaixaConciliacao, error)
+	GetConciliacao(ctx context.Context, id int64) (*model.CaixaConciliacao, error)
+}
+
+func NewHandler(store CaixaStore) *Handler {
+	return &Handler{store}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /caixa/sessoes", h.getAll)
+	mux.HandleFunc("POST /caixa/sessoes", h.abrir)
+	mux.HandleFunc("GET /caixa/sessoes/{id}", h.fetch)
+	mux.HandleFunc("POST /caixa/sessoes/{id}/movimentos", h.addMovimento)
+	mux.HandleFunc("POST /caixa/sessoes/{id}/fechar", h.fechar)
+	mux.HandleFunc("GET /caixa/sessoes/{id}/conciliacao", h.fetchConciliacao)
+}
+
+// @Summary List Caixa sessions
+// @Tags Caixa
+// @Produce json
+// @Param filter-id_funcionario query int false "Filter by id_funcionario using operators: eq, ne, gt, lt"
+// @Param filter-expediente query string false "Filter by expediente using operators: eq, ne"
+// @Param filter-data_hora_abertura query string false "Filter by data_hora_abertura using operators: eq, ne, gt, lt"
+// @Param sort query string false "Sort fields: data_hora_abertura, data_hora_fechamento, id_funcionario, expediente. Prefix with '-' for desc."
+// @Param offset query int false "Pagination offset (default 0)"
+// @Param limit query int false "Pagination limit (default 10)"
+// @Success 200 {array} model.CaixaSessao
+// @Failure 500 {object} types.ErrorResponse
+// @Router /caixa/sessoes [get]
+func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	filters, err := NewCaixaSessaoFilter(r.URL.Query())
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	sessoes, err := h.store.GetAll(ctx, filters)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := util.WriteJSON(w, http.StatusOK, sessoes); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Open a Caixa session
+// @Description Opens the till with an opening float. Only employees of type caixa may open it and only one session can be open at a time.
+// @Tags Caixa
+// @Accept json
+// @Produce json
+// @Param sessao body model.CaixaSessaoCreate true "Abertura payload"
+// @Success 201 {object} model.CaixaSessao
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /caixa/sessoes [post]
+func (h *Handler) abrir(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	var payload model.CaixaSessaoCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	sessao := payload.ToCaixaSessao()
+	if err := h.store.Abrir(ctx, &sessao); err != nil {
+		writeCaixaError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, sessao)
+}
+
+// @Summary Get Caixa session by ID
+// @Tags Caixa
+// @Produce json
+// @Param id path int true "Sessão ID"
+// @Success 200 {object} model.CaixaSessao
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /caixa/sessoes/{id} [get]
+func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessao, err := h.store.GetByID(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Sessão de caixa not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, sessao); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Register a sangria or suprimento
+// @Tags Caixa
+// @Accept json
+// @Produce json
+// @Param id path int true "Sessão ID"
+// @Param movimento body model.CaixaMovimentoCreate true "Movimento payload (tipo: sangria, suprimento)"
+// @Success 201 {object} model.CaixaMovimento
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /caixa/sessoes/{id}/movimentos [post]
+func (h *Handler) addMovimento(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.CaixaMovimentoCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	movimento := payload.ToCaixaMovimento(id)
+	if err := h.store.AddMovimento(ctx, &movimento); err != nil {
+		writeCaixaError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, movimento)
+}
+
+// @Summary Close a Caixa session
+// @Description Closes the session with the counted cash and returns the reconciliation.
+// @Tags Caixa
+// @Accept json
+// @Produce json
+// @Param id path int true "Sessão ID"
+// @Param fechamento body model.CaixaFechamento true "Fechamento payload"
+// @Success 200 {object} model.CaixaConciliacao
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /caixa/sessoes/{id}/fechar [post]
+func (h *Handler) fechar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.CaixaFechamento
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conc, err := h.store.Fechar(ctx, id, payload.ValorContado)
+	if err != nil {
+		writeCaixaError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, conc)
+}
+
+// @Summary Caixa session reconciliation
+// @Description Expected cash (opening float + suprimentos - sangrias + cash payments during the session) against the counted cash.
+// @Tags Caixa
+// @Produce json
+// @Param id path int true "Sessão ID"
+// @Success 200 {object} model.CaixaConciliacao
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /caixa/sessoes/{id}/conciliacao [get]
+func (h *Handler) fetchConciliacao(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conc, err := h.store.GetConciliacao(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Sessão de caixa not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, conc); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeCaixaError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		util.ErrorJSON(w, "Sessão de caixa not found.", http.StatusNotFound)
+	case errors.Is(err, ErrCaixaAberto), errors.Is(err, ErrSessaoFechada):
+		util.ErrorJSON(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrMovimentoInvalido), errors.Is(err, ErrValorInvalido):
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+	default:
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+	}
+}
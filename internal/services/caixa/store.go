@@ -0,0 +1,288 @@
+package caixa
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrCaixaAberto         = errors.New("Já existe uma sessão de caixa aberta")
+	ErrSessaoFechada       = errors.New("Sessão de caixa já fechada")
+	ErrFuncionarioNaoCaixa = errors.New("Funcionário não é do tipo caixa")
+	ErrMovimentoInvalido   = errors.New("Tipo de movimento deve ser sangria ou suprimento")
+	ErrValorInvalido       = errors.New("Valor deve ser positivo")
+	ErrSaldoInsuficiente   = errors.New("Sangria maior que o dinheiro esperado no caixa")
+)
+
+// *sql.DB e *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db}
+}
+
+const selectSessao = `
+	SELECT id_sessao, id_funcionario, expediente::text, valor_abertura, data_hora_abertura, data_hora_fechamento, valor_contado
+	FROM caixa_sessao`
+
+func scanSessao(row interface{ Scan(...any) error }) (*model.CaixaSessao, error) {
+	var c model.CaixaSessao
+	err := row.Scan(&c.IDSessao, &c.IDFuncionario, &c.Expediente, &c.ValorAbertura, &c.DataHoraAbertura, &c.DataHoraFechamento, &c.ValorContado)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.CaixaSessao, error) {
+	query := selectSessao + " AS c"
+	rows, err := util.QueryRowsWithFilter(s.db, ctx, query, &filter, "c")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessoes := make([]model.CaixaSessao, 0)
+	for rows.Next() {
+		c, err := scanSessao(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessoes = append(sessoes, *c)
+	}
+	return sessoes, rows.Err()
+}
+
+func (s *Store) GetByID(ctx context.Context, id int64) (*model.CaixaSessao, error) {
+	return scanSessao(s.db.QueryRowContext(ctx, selectSessao+" WHERE id_sessao = $1", id))
+}
+
+// Abre uma sessão para um funcionário do tipo caixa.
+// Sem expediente informado usa o expediente do funcionário.
+func (s *Store) Abrir(ctx context.Context, c *model.CaixaSessao) error {
+	if c.ValorAbertura < 0 {
+		return ErrValorInvalido
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var tipo, expediente string
+	err = tx.QueryRowContext(ctx, "SELECT tipo::text, expediente::text FROM Funcionario WHERE id_funcionario = $1", c.IDFuncionario).
+		Scan(&tipo, &expediente)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("funcionário %d não encontrado", c.IDFuncionario)
+		}
+		return err
+	}
+	if tipo != "caixa" {
+		return ErrFuncionarioNaoCaixa
+	}
+	if c.Expediente == "" {
+		c.Expediente = expediente
+	}
+
+	var aberta bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM caixa_sessao WHERE data_hora_fechamento IS NULL)").Scan(&aberta)
+	if err != nil {
+		return err
+	}
+	if aberta {
+		return ErrCaixaAberto
+	}
+
+	query := `
+		INSERT INTO caixa_sessao (id_funcionario, expediente, valor_abertura)
+		VALUES ($1, $2::tipo_de_expediente, $3)
+		RETURNING id_sessao, data_hora_abertura;`
+	err = tx.QueryRowContext(ctx, query, c.IDFuncionario, c.Expediente, c.ValorAbertura).Scan(&c.IDSessao, &c.DataHoraAbertura)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Bloqueia a sessão e garante que ela ainda está aberta.
+func travarSessaoAberta(ctx context.Context, tx *sql.Tx, id int64) error {
+	var fechada bool
+	err := tx.QueryRowContext(ctx, "SELECT data_hora_fechamento IS NOT NULL FROM caixa_sessao WHERE id_sessao = $1 FOR UPDATE", id).Scan(&fechada)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.ErrNotFound
+		}
+		return err
+	}
+	if fechada {
+		return ErrSessaoFechada
+	}
+	return nil
+}
+
+// Registra uma sangria ou suprimento. Sangrias não podem deixar o caixa negativo.
+func (s *Store) AddMovimento(ctx context.Context, m *model.CaixaMovimento) error {
+	if m.Tipo != model.MovimentoSangria && m.Tipo != model.MovimentoSuprimento {
+		return ErrMovimentoInvalido
+	}
+	if centavos(m.Valor) <= 0 {
+		return ErrValorInvalido
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := travarSessaoAberta(ctx, tx, m.IDSessao); err != nil {
+		return err
+	}
+
+	if m.Tipo == model.MovimentoSangria {
+		conc, err := conciliacao(ctx, tx, m.IDSessao)
+		if err != nil {
+			return err
+		}
+		if centavos(m.Valor) > centavos(conc.Esperado) {
+			return fmt.Errorf("%w (esperado %.2f)", ErrSaldoInsuficiente, conc.Esperado)
+		}
+	}
+
+	query := `
+		INSERT INTO caixa_movimento (id_sessao, tipo, valor, motivo)
+		VALUES ($1, $2::tipo_movimento_caixa, $3, $4)
+		RETURNING id_movimento, data_hora;`
+	err = tx.QueryRowContext(ctx, query, m.IDSessao, m.Tipo, m.Valor, m.Motivo).Scan(&m.IDMovimento, &m.DataHora)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Fecha a sessão com o dinheiro contado e retorna a conferência.
+func (s *Store) Fechar(ctx context.Context, id int64, valorContado float64) (*model.CaixaConciliacao, error) {
+	if valorContado < 0 {
+		return nil, ErrValorInvalido
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := travarSessaoAberta(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE caixa_sessao SET data_hora_fechamento = now(), valor_contado = $2 WHERE id_sessao = $1", id, valorContado)
+	if err != nil {
+		return nil, err
+	}
+
+	conc, err := conciliacao(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return conc, nil
+}
+
+func (s *Store) GetConciliacao(ctx context.Context, id int64) (*model.CaixaConciliacao, error) {
+	return conciliacao(ctx, s.db, id)
+}
+
+// Compara o dinheiro esperado no caixa com o contado.
+// Entram os pagamentos em dinheiro feitos durante a sessão e as vendas quitadas
+// em dinheiro sem pagamentos registrados.
+func conciliacao(ctx context.Context, q querier, id int64) (*model.CaixaConciliacao, error) {
+	sessao, err := scanSessao(q.QueryRowContext(ctx, selectSessao+" WHERE id_sessao = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	conc := model.CaixaConciliacao{
+		CaixaSessao: *sessao,
+		Movimentos:  make([]model.CaixaMovimento, 0),
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT id_movimento, id_sessao, tipo::text, valor, motivo, data_hora
+		FROM caixa_movimento
+		WHERE id_sessao = $1
+		ORDER BY data_hora, id_movimento;`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m model.CaixaMovimento
+		if err := rows.Scan(&m.IDMovimento, &m.IDSessao, &m.Tipo, &m.Valor, &m.Motivo, &m.DataHora); err != nil {
+			return nil, err
+		}
+		if m.Tipo == model.MovimentoSangria {
+			conc.Sangrias += m.Valor
+		} else {
+			conc.Suprimentos += m.Valor
+		}
+		conc.Movimentos = append(conc.Movimentos, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	queryDinheiro := `
+		SELECT COALESCE(SUM(valor), 0)
+		FROM (
+			SELECT p.valor
+			FROM pagamento p
+			WHERE p.tipo_pagamento = 'dinheiro'
+				AND p.data_hora >= $1 AND ($2::timestamp IS NULL OR p.data_hora < $2)
+			UNION ALL
+			SELECT vt.total_liquido
+			FROM venda_totais vt
+			JOIN Venda v ON v.id_venda = vt.id_venda
+			WHERE v.tipo_pagamento = 'dinheiro' AND vt.total_pago = 0
+				AND vt.data_hora_pagamento >= $1 AND ($2::timestamp IS NULL OR vt.data_hora_pagamento < $2)
+		) d;`
+	err = q.QueryRowContext(ctx, queryDinheiro, sessao.DataHoraAbertura, sessao.DataHoraFechamento).Scan(&conc.VendasDinheiro)
+	if err != nil {
+		return nil, err
+	}
+
+	conc.Suprimentos = arredondar(conc.Suprimentos)
+	conc.Sangrias = arredondar(conc.Sangrias)
+	conc.Esperado = arredondar(sessao.ValorAbertura + conc.Suprimentos - conc.Sangrias + conc.VendasDinheiro)
+	if sessao.ValorContado != nil {
+		d := arredondar(*sessao.ValorContado - conc.Esperado)
+		conc.Diferenca = &d
+	}
+	return &conc, nil
+}
+
+func centavos(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+func arredondar(v float64) float64 {
+	return math.Round(v*100) / 100
+}
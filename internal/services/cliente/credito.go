@@ -0,0 +1,52 @@
+package cliente
+
+import (
+	"edna/internal/model"
+	"math"
+)
+
+// Venda em aberto com o valor que falta pagar
+type Debito struct {
+	IDVenda  int64
+	Restante float64
+}
+
+// Distribui o valor entre os débitos na ordem recebida (das vendas mais antigas
+// para as mais novas), quitando cada um antes de passar ao próximo.
+// Retorna o valor destinado a cada débito.
+func DistribuirPagamento(debitos []Debito, valor float64) []float64 {
+	parcelas := make([]float64, len(debitos))
+	resta := centavos(valor)
+	for i, d := range debitos {
+		if resta <= 0 {
+			break
+		}
+		p := min(resta, centavos(d.Restante))
+		parcelas[i] = float64(p) / 100
+		resta -= p
+	}
+	return parcelas
+}
+
+// Soma o valor na faixa de idade da dívida: 0–30, 31–60, 61–90 ou mais de 90 dias
+func SomarFaixa(f *model.FaixasAging, dias int, valor float64) {
+	switch {
+	case dias <= 30:
+		f.Ate30 = arredondar(f.Ate30 + valor)
+	case dias <= 60:
+		f.De31a60 = arredondar(f.De31a60 + valor)
+	case dias <= 90:
+		f.De61a90 = arredondar(f.De61a90 + valor)
+	default:
+		f.Acima90 = arredondar(f.Acima90 + valor)
+	}
+	f.Total = arredondar(f.Total + valor)
+}
+
+func centavos(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+func arredondar(v float64) float64 {
+	return math.Round(v*100) / 100
+}
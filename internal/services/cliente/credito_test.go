@@ -0,0 +1,40 @@
+package cliente
+
+import (
+	"edna/internal/model"
+	"slices"
+	"testing"
+)
+
+func TestDistribuirPagamento(t *testing.T) {
+	debitos := []Debito{{1, 30.10}, {2, 12.50}, {3, 40}}
+	cases := []struct {
+		nome     string
+		valor    float64
+		esperado []float64
+	}{
+		{"quita as mais antigas primeiro", 50, []float64{30.10, 12.50, 7.40}},
+		{"parcial na primeira", 10, []float64{10, 0, 0}},
+		{"tudo", 82.60, []float64{30.10, 12.50, 40}},
+	}
+	for _, c := range cases {
+		if got := DistribuirPagamento(debitos, c.valor); !slices.Equal(got, c.esperado) {
+			t.Fatalf("%s: expected %v, got %v", c.nome, c.esperado, got)
+		}
+	}
+}
+
+func TestSomarFaixa(t *testing.T) {
+	var f model.FaixasAging
+	for _, d := range []struct {
+		dias  int
+		valor float64
+	}{{0, 1}, {30, 2}, {31, 4}, {60, 8}, {61, 16}, {90, 32}, {91, 64}} {
+		SomarFaixa(&f, d.dias, d.valor)
+	}
+
+	esperado := model.FaixasAging{Ate30: 3, De31a60: 12, De61a90: 48, Acima90: 64, Total: 127}
+	if f != esperado {
+		t.Fatalf("expected %+v, got %+v", esperado, f)
+	}
+}
@@ -16,7 +16,7 @@ func NewClienteFilter(params url.Values) (util.Filter, error) {
 		return filter, err
 	}
 
-	attrs := []string{"nome", "cpf", "data_nascimento"}
+	attrs := []string{"nome", "cpf", "data_nascimento", "limite_credito"}
 	if err := filter.GetSorts(params, attrs); err != nil {
 		return filter, err
 	}
@@ -35,6 +35,10 @@ func NewClienteFilter(params url.Values) (util.Filter, error) {
 		return filter, err
 	}
 
+	if err := filter.GetFilterFloat(params, "limite_credito"); err != nil {
+		return filter, err
+	}
+
 	return filter, nil
 }
 
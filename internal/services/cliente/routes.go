@@ -2,10 +2,13 @@ package cliente
 
 import (
 	"context"
+	"edna/internal/documento"
 	"edna/internal/model"
+	"edna/internal/services/pagamento"
 	"edna/internal/types"
 	"edna/internal/util"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -21,6 +24,8 @@ type ClienteStore interface {
 	GetByIDWithSaldo(ctx context.Context, id int64) (*model.ClienteWithSaldo, error)
 	Update(ctx context.Context, props *model.Cliente) error
 	Delete(ctx context.Context, id int64) (*model.Cliente, error)
+	Quitar(ctx context.Context, idCliente int64, q model.QuitacaoCreate) (*model.Quitacao, error)
+	GetAging(ctx context.Context, data string) (*model.RelatorioAging, error)
 }
 
 func NewHandler(store ClienteStore) *Handler {
@@ -30,9 +35,11 @@ func NewHandler(store ClienteStore) *Handler {
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /clientes", h.getAll)
 	mux.HandleFunc("GET /clientes/saldo", h.getAllWithSaldo)
+	mux.HandleFunc("GET /clientes/aging", h.getAging)
 	mux.HandleFunc("POST /clientes", h.create)
 	mux.HandleFunc("GET /clientes/{id}", h.fetch)
 	mux.HandleFunc("GET /clientes/{id}/saldo", h.fetchSaldo)
+	mux.HandleFunc("POST /clientes/{id}/quitar", h.quitar)
 	mux.HandleFunc("PUT /clientes/{id}", h.update)
 	mux.HandleFunc("DELETE /clientes/{id}", h.delete)
 }
@@ -42,7 +49,8 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 // @Produce json
 // @Param filter-nome query string false "Filter by nome using operators: like, ilike, eq, ne. Format: operator.value (e.g. like.João)"
 // @Param filter-cnpj query string false "Filter by cnpj using operators: eq, ne, like, ilike. Format: operator.value (e.g. eq.123456789)"
-// @Param sort query string false "Sort fields: nome, cnpj. Prefix with '-' for desc. Comma separated for multiple fields (e.g. -nome,cnpj)"
+// @Param filter-limite_credito query float32 false "Filter by limite_credito using operators: eq, ne, gt, lt, gte, lte. Format: operator.value (e.g. gt.100)"
+// @Param sort query string false "Sort fields: nome, cnpj, limite_credito. Prefix with '-' for desc. Comma separated for multiple fields (e.g. -nome,cnpj)"
 // @Param offset query int false "Pagination offset (default 0)"
 // @Param limit query int false "Pagination limit (default 10)"
 // @Success 200 {array} model.Cliente
@@ -54,17 +62,17 @@ func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
 
 	filters, err := NewClienteFilter(r.URL.Query())
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	clientes, err := h.store.GetAll(ctx, filters)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	err = util.WriteJSON(w, http.StatusOK, clientes)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
@@ -86,17 +94,17 @@ func (h *Handler) getAllWithSaldo(w http.ResponseWriter, r *http.Request) {
 
 	filters, err := NewClienteWithSaldoFilter(r.URL.Query())
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	clientes, err := h.store.GetAllWithSaldo(ctx, filters)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	err = util.WriteJSON(w, http.StatusOK, clientes)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
@@ -107,6 +115,7 @@ func (h *Handler) getAllWithSaldo(w http.ResponseWriter, r *http.Request) {
 // @Param fornecedor body model.ClienteCreate true "Cliente payload"
 // @Success 201 {object} model.Cliente
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
 // @Failure 422 {object} types.ErrorResponse
 // @Router /clientes [post]
 func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
@@ -125,10 +134,23 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToCliente()
+	if err := normalizarCPF(&model); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	err = h.store.Create(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		if errors.Is(err, types.ErrDocumentoDuplicado) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -160,7 +182,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "Cliente not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -171,6 +193,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Update Cliente
+// @Description Records anonymized under the LGPD cannot be updated (409).
 // @Tags Cliente
 // @Accept json
 // @Produce json
@@ -178,6 +201,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 // @Param fornecedor body model.ClienteCreate true "Cliente payload"
 // @Success 200 {object} model.Cliente
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
 // @Failure 422 {object} types.ErrorResponse
 // @Router /clientes/{id} [put]
 func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
@@ -197,15 +221,28 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToCliente()
 	model.Id = id
+	if err := normalizarCPF(&model); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	err = h.store.Update(ctx, &model)
 	if err != nil {
 		if err == types.ErrNotFound {
 			util.ErrorJSON(w, "Cliente not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		if errors.Is(err, types.ErrDocumentoDuplicado) || errors.Is(err, types.ErrAnonimizado) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -237,7 +274,7 @@ func (h *Handler) fetchSaldo(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "Cliente not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -245,11 +282,14 @@ func (h *Handler) fetchSaldo(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Delete Cliente
+// @Description Only clients without sales can be deleted. Clients with sales must be anonymized instead.
 // @Tags Cliente
 // @Produce json
 // @Param id path int true "Cliente ID"
 // @Success 200 {object} model.Cliente
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
 // @Failure 422 {object} types.ErrorResponse
 // @Router /clientes/{id} [delete]
 func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
@@ -268,9 +308,108 @@ func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "Cliente not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		if err == ErrClienteComVendas {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
 	util.WriteJSON(w, http.StatusOK, model)
 }
+
+// @Summary Settle client tab
+// @Description Pays several open (fiado) sales of the client at once, oldest first. Without valor the chosen sales are paid in full; without vendas every open sale is considered.
+// @Tags Cliente
+// @Accept json
+// @Produce json
+// @Param id path int true "Cliente ID"
+// @Param quitacao body model.QuitacaoCreate true "Quitacao payload"
+// @Success 200 {object} model.Quitacao
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /clientes/{id}/quitar [post]
+func (h *Handler) quitar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.QuitacaoCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	quitacao, err := h.store.Quitar(ctx, id, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrNotFound):
+			util.ErrorJSON(w, "Cliente not found.", http.StatusNotFound)
+		case errors.Is(err, ErrSemDebitos), errors.Is(err, ErrVendaNaoAberta):
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, ErrValorExcedeSaldo), errors.Is(err, pagamento.ErrValorInvalido),
+			errors.Is(err, pagamento.ErrTipoPagamentoInvalido):
+			util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		default:
+			util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+		}
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, quitacao)
+}
+
+// @Summary Client debt ageing report
+// @Description Open (fiado) balance of each client bucketed by the age of the sale: 0-30, 31-60, 61-90 and over 90 days
+// @Tags Cliente
+// @Produce json
+// @Param data query string false "Reference date YYYY-MM-DD (default today). Balances are as of that date: only payments made up to it count"
+// @Success 200 {object} model.RelatorioAging
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /clientes/aging [get]
+func (h *Handler) getAging(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	relatorio, err := h.store.GetAging(ctx, r.URL.Query().Get("data"))
+	if err != nil {
+		if errors.Is(err, ErrDataInvalida) {
+			util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, relatorio); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CPF é opcional, quando informado é gravado só com os dígitos
+func normalizarCPF(c *model.Cliente) error {
+	if c.CPF == nil || *c.CPF == "" {
+		c.CPF = nil
+		return nil
+	}
+	cpf, err := documento.CPF(*c.CPF)
+	if err != nil {
+		return err
+	}
+	c.CPF = &cpf
+	return nil
+}
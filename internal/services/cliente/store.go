@@ -4,11 +4,24 @@ import (
 	"context"
 	"database/sql"
 	"edna/internal/model"
+	"edna/internal/services/pagamento"
 	"edna/internal/types"
 	"edna/internal/util"
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+)
+
+var (
+	ErrLimiteInvalido   = errors.New("Limite de crédito não pode ser negativo")
+	ErrSemDebitos       = errors.New("Cliente não tem vendas em aberto para quitar")
+	ErrVendaNaoAberta   = errors.New("Venda não está em aberto para este cliente")
+	ErrValorExcedeSaldo = errors.New("Valor da quitação excede o saldo das vendas escolhidas")
+	ErrDataInvalida     = errors.New("Data de referência inválida, use YYYY-MM-DD")
+	ErrClienteComVendas = errors.New("Cliente tem vendas registradas, use a anonimização para remover os dados pessoais")
 )
 
 type Store struct {
@@ -20,7 +33,7 @@ func NewStore(db *sql.DB) *Store {
 }
 
 func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.Cliente, error) {
-	query := "SELECT id_cliente, nome, cpf, data_nascimento FROM Cliente AS c"
+	query := "SELECT id_cliente, nome, cpf, data_nascimento, limite_credito FROM Cliente AS c"
 
 	rows, err := util.QueryRowsWithFilter(s.db, ctx, query, &filter, "c")
 	if err != nil {
@@ -30,7 +43,7 @@ func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.Cliente
 	clientes := make([]model.Cliente, 0)
 	for rows.Next() {
 		var c model.Cliente
-		err = rows.Scan(&c.Id, &c.Nome, &c.CPF, &c.DataNascimento)
+		err = rows.Scan(&c.Id, &c.Nome, &c.CPF, &c.DataNascimento, &c.LimiteCredito)
 		if err != nil {
 			return nil, err
 		}
@@ -41,15 +54,15 @@ func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.Cliente
 
 func (s *Store) GetAllWithSaldo(ctx context.Context, filter util.Filter) ([]model.ClienteWithSaldo, error) {
 	// Criamos uma lista de ids de clientes que estão devendo dinheiro
+	// (valor líquido das vendas não quitadas menos os pagamentos parciais)
 	// Juntamos com clientes e substituimos por zero valores nulos.
 	query := `
 	WITH ClienteDevedor AS (
-		SELECT id_cliente, COALESCE(SUM(quantidade * valor_unitario), 0)::numeric(12, 2) as saldo_devedor
-		FROM Venda
-		LEFT JOIN item_venda USING(id_venda)
+		SELECT id_cliente, COALESCE(SUM(total_liquido - total_pago), 0)::numeric(12, 2) as saldo_devedor
+		FROM venda_totais
 	 	WHERE data_hora_pagamento IS NULL
 		GROUP BY id_cliente
-	) SELECT id_cliente, nome, cpf, data_nascimento,
+	) SELECT id_cliente, nome, cpf, data_nascimento, limite_credito,
 		COALESCE(saldo_devedor, 0)::numeric(12, 2)
 		FROM Cliente
 		LEFT JOIN ClienteDevedor USING(id_cliente)
@@ -122,7 +135,7 @@ func (s *Store) GetAllWithSaldo(ctx context.Context, filter util.Filter) ([]mode
 		query += " LIMIT $" + strconv.Itoa(len(values))
 	}
 
-	rows, err := s.db.QueryContext(ctx, query, values)
+	rows, err := s.db.QueryContext(ctx, query, values...)
 	if err != nil {
 		return nil, err
 	}
@@ -131,7 +144,7 @@ func (s *Store) GetAllWithSaldo(ctx context.Context, filter util.Filter) ([]mode
 	clientes := make([]model.ClienteWithSaldo, 0)
 	for rows.Next() {
 		var c model.ClienteWithSaldo
-		err = rows.Scan(&c.Id, &c.Nome, &c.CPF, &c.DataNascimento, &c.SaldoDevedor)
+		err = rows.Scan(&c.Id, &c.Nome, &c.CPF, &c.DataNascimento, &c.LimiteCredito, &c.SaldoDevedor)
 		if err != nil {
 			return nil, err
 		}
@@ -141,11 +154,11 @@ func (s *Store) GetAllWithSaldo(ctx context.Context, filter util.Filter) ([]mode
 }
 
 func (s *Store) GetByID(ctx context.Context, id int64) (*model.Cliente, error) {
-	query := "SELECT id_cliente, nome, cpf, data_nascimento FROM Cliente WHERE id_cliente = $1;"
+	query := "SELECT id_cliente, nome, cpf, data_nascimento, limite_credito FROM Cliente WHERE id_cliente = $1;"
 	row := s.db.QueryRowContext(ctx, query, id)
 
 	var c model.Cliente
-	err := row.Scan(&c.Id, &c.Nome, &c.CPF, &c.DataNascimento)
+	err := row.Scan(&c.Id, &c.Nome, &c.CPF, &c.DataNascimento, &c.LimiteCredito)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, types.ErrNotFound
@@ -158,12 +171,11 @@ func (s *Store) GetByID(ctx context.Context, id int64) (*model.Cliente, error) {
 func (s *Store) GetByIDWithSaldo(ctx context.Context, id int64) (*model.ClienteWithSaldo, error) {
 	query := `
 	WITH ClienteDevedor AS (
-		SELECT id_cliente, COALESCE(SUM(quantidade * valor_unitario), 0)::numeric(12, 2) as saldo_devedor
-		FROM Venda
-		LEFT JOIN item_venda USING(id_venda)
+		SELECT id_cliente, COALESCE(SUM(total_liquido - total_pago), 0)::numeric(12, 2) as saldo_devedor
+		FROM venda_totais
 	 	WHERE data_hora_pagamento IS NULL
 		GROUP BY id_cliente
-	) SELECT id_cliente, nome, cpf, data_nascimento,
+	) SELECT id_cliente, nome, cpf, data_nascimento, limite_credito,
 		COALESCE(saldo_devedor, 0)::numeric(12, 2)
 		FROM Cliente
 	 	LEFT JOIN ClienteDevedor USING(id_cliente)
@@ -172,7 +184,7 @@ func (s *Store) GetByIDWithSaldo(ctx context.Context, id int64) (*model.ClienteW
 	row := s.db.QueryRowContext(ctx, query, id)
 
 	var c model.ClienteWithSaldo
-	err := row.Scan(&c.Id, &c.Nome, &c.CPF, &c.DataNascimento, &c.SaldoDevedor)
+	err := row.Scan(&c.Id, &c.Nome, &c.CPF, &c.DataNascimento, &c.LimiteCredito, &c.SaldoDevedor)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, types.ErrNotFound
@@ -182,15 +194,45 @@ func (s *Store) GetByIDWithSaldo(ctx context.Context, id int64) (*model.ClienteW
 	return &c, nil
 }
 
+// Confere se o CPF já pertence a outro cliente, para uma mensagem melhor que a
+// do índice único cliente_cpf_unico
+func (s *Store) cpfEmUso(ctx context.Context, cpf *string, id int64) error {
+	if cpf == nil {
+		return nil
+	}
+	var emUso bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Cliente WHERE cpf = $1 AND id_cliente <> $2)", *cpf, id).Scan(&emUso)
+	if err != nil {
+		return err
+	}
+	if emUso {
+		return fmt.Errorf("%w: CPF %s", types.ErrDocumentoDuplicado, *cpf)
+	}
+	return nil
+}
+
 func (s *Store) Create(ctx context.Context, props *model.Cliente) error {
-	query := "INSERT INTO Cliente (nome, cpf, data_nascimento) VALUES ($1, $2, $3) RETURNING id_cliente;"
-	res := s.db.QueryRowContext(ctx, query, props.Nome, props.CPF, props.DataNascimento)
+	if props.LimiteCredito != nil && *props.LimiteCredito < 0 {
+		return ErrLimiteInvalido
+	}
+	if err := s.cpfEmUso(ctx, props.CPF, 0); err != nil {
+		return err
+	}
+	query := "INSERT INTO Cliente (nome, cpf, data_nascimento, limite_credito) VALUES ($1, $2, $3, $4) RETURNING id_cliente;"
+	res := s.db.QueryRowContext(ctx, query, props.Nome, props.CPF, props.DataNascimento, props.LimiteCredito)
 	return res.Scan(&props.Id)
 }
 
 func (s *Store) Update(ctx context.Context, props *model.Cliente) error {
-	query := "UPDATE Cliente SET nome = $1, cpf = $2, data_nascimento = $3 WHERE id_cliente = $4;"
-	res, err := s.db.ExecContext(ctx, query, props.Nome, props.CPF, props.DataNascimento, props.Id)
+	if props.LimiteCredito != nil && *props.LimiteCredito < 0 {
+		return ErrLimiteInvalido
+	}
+	if err := s.cpfEmUso(ctx, props.CPF, props.Id); err != nil {
+		return err
+	}
+	// Cliente anonimizado não recebe dados pessoais de volta
+	query := "UPDATE Cliente SET nome = $1, cpf = $2, data_nascimento = $3, limite_credito = $4 WHERE id_cliente = $5 AND anonimizado_em IS NULL;"
+	res, err := s.db.ExecContext(ctx, query, props.Nome, props.CPF, props.DataNascimento, props.LimiteCredito, props.Id)
 	if err != nil {
 		return err
 	}
@@ -199,16 +241,36 @@ func (s *Store) Update(ctx context.Context, props *model.Cliente) error {
 		return err
 	}
 	if rowsAffected == 0 {
+		var anonimizado bool
+		err := s.db.QueryRowContext(ctx, "SELECT anonimizado_em IS NOT NULL FROM Cliente WHERE id_cliente = $1", props.Id).Scan(&anonimizado)
+		if err == sql.ErrNoRows {
+			return types.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if anonimizado {
+			return types.ErrAnonimizado
+		}
 		return types.ErrNotFound
 	}
 	return nil
 }
 
+// Só apaga clientes sem vendas, para não perder o histórico financeiro
 func (s *Store) Delete(ctx context.Context, id int64) (*model.Cliente, error) {
-	query := "DELETE FROM Cliente WHERE id_cliente = $1 RETURNING id_cliente, nome, cpf, data_nascimento;"
+	var comVendas bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Venda WHERE id_cliente = $1)", id).Scan(&comVendas); err != nil {
+		return nil, err
+	}
+	if comVendas {
+		return nil, ErrClienteComVendas
+	}
+
+	query := "DELETE FROM Cliente WHERE id_cliente = $1 RETURNING id_cliente, nome, cpf, data_nascimento, limite_credito;"
 	var m model.Cliente
 	row := s.db.QueryRowContext(ctx, query, id)
-	err := row.Scan(&m.Id, &m.Nome, &m.CPF, &m.DataNascimento)
+	err := row.Scan(&m.Id, &m.Nome, &m.CPF, &m.DataNascimento, &m.LimiteCredito)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, types.ErrNotFound
@@ -217,3 +279,224 @@ func (s *Store) Delete(ctx context.Context, id int64) (*model.Cliente, error) {
 	}
 	return &m, nil
 }
+
+// Saldo devedor do cliente: valor líquido das vendas não quitadas menos os pagamentos parciais
+func saldoDevedor(ctx context.Context, tx *sql.Tx, idCliente int64) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(total_liquido - total_pago), 0)::float8
+		FROM venda_totais
+		WHERE id_cliente = $1 AND data_hora_pagamento IS NULL`
+	var saldo float64
+	err := tx.QueryRowContext(ctx, query, idCliente).Scan(&saldo)
+	return saldo, err
+}
+
+// Bloqueia o cliente da venda e garante que o fiado não passou do limite de crédito.
+// Usada depois de inserir ou alterar itens na mesma transação. Vendas já quitadas
+// e clientes sem limite não são conferidos.
+func ConferirLimiteVenda(ctx context.Context, tx *sql.Tx, idVenda int64) error {
+	var idCliente int64
+	var quitada bool
+	err := tx.QueryRowContext(ctx, "SELECT id_cliente, data_hora_pagamento IS NOT NULL FROM Venda WHERE id_venda = $1", idVenda).
+		Scan(&idCliente, &quitada)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.ErrNotFound
+		}
+		return err
+	}
+	if quitada {
+		return nil
+	}
+
+	var limite *float64
+	err = tx.QueryRowContext(ctx, "SELECT limite_credito FROM Cliente WHERE id_cliente = $1 FOR UPDATE", idCliente).Scan(&limite)
+	if err != nil {
+		return err
+	}
+	if limite == nil {
+		return nil
+	}
+	saldo, err := saldoDevedor(ctx, tx, idCliente)
+	if err != nil {
+		return err
+	}
+	if centavos(saldo) > centavos(*limite) {
+		return fmt.Errorf("%w: saldo devedor %.2f, limite %.2f", types.ErrLimiteCredito, saldo, *limite)
+	}
+	return nil
+}
+
+// Paga várias vendas em aberto do cliente de uma vez, das mais antigas para as mais novas.
+// Cada venda recebe um pagamento e é quitada quando o valor cobre o restante dela.
+func (s *Store) Quitar(ctx context.Context, idCliente int64, q model.QuitacaoCreate) (*model.Quitacao, error) {
+	if !pagamento.TipoAceito(q.TipoPagamento) {
+		return nil, fmt.Errorf("%w: %q", pagamento.ErrTipoPagamentoInvalido, q.TipoPagamento)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, "SELECT id_cliente FROM Cliente WHERE id_cliente = $1 FOR UPDATE", idCliente).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+
+	query := `
+		SELECT id_venda, (total_liquido - total_pago)::float8
+		FROM venda_totais
+		WHERE id_cliente = $1 AND data_hora_pagamento IS NULL AND total_liquido > total_pago
+		ORDER BY data_hora_venda, id_venda`
+	rows, err := tx.QueryContext(ctx, query, idCliente)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	debitos := make([]Debito, 0)
+	for rows.Next() {
+		var d Debito
+		if err := rows.Scan(&d.IDVenda, &d.Restante); err != nil {
+			return nil, err
+		}
+		if len(q.Vendas) == 0 || slices.Contains(q.Vendas, d.IDVenda) {
+			debitos = append(debitos, d)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, idVenda := range q.Vendas {
+		if !slices.ContainsFunc(debitos, func(d Debito) bool { return d.IDVenda == idVenda }) {
+			return nil, fmt.Errorf("%w: venda %d", ErrVendaNaoAberta, idVenda)
+		}
+	}
+	if len(debitos) == 0 {
+		return nil, ErrSemDebitos
+	}
+
+	var total float64
+	for _, d := range debitos {
+		total += d.Restante
+	}
+	valor := arredondar(total)
+	if q.Valor != nil {
+		valor = *q.Valor
+	}
+	if centavos(valor) <= 0 {
+		return nil, pagamento.ErrValorInvalido
+	}
+	if centavos(valor) > centavos(total) {
+		return nil, fmt.Errorf("%w (saldo %.2f)", ErrValorExcedeSaldo, total)
+	}
+
+	quitacao := model.Quitacao{
+		IDCliente:      idCliente,
+		ValorPago:      arredondar(valor),
+		Pagamentos:     make([]model.Pagamento, 0, len(debitos)),
+		VendasQuitadas: make([]int64, 0, len(debitos)),
+	}
+	var dataHora time.Time
+	if q.DataHora != nil {
+		dataHora = *q.DataHora
+	}
+	for i, parcela := range DistribuirPagamento(debitos, valor) {
+		if parcela == 0 {
+			continue
+		}
+		p := model.Pagamento{
+			IDVenda:       debitos[i].IDVenda,
+			TipoPagamento: q.TipoPagamento,
+			Valor:         parcela,
+			DataHora:      dataHora,
+		}
+		totais, err := pagamento.Registrar(ctx, tx, &p)
+		if err != nil {
+			return nil, err
+		}
+		quitacao.Pagamentos = append(quitacao.Pagamentos, p)
+		if totais.StatusPagamento == model.StatusPagamentoPago {
+			quitacao.VendasQuitadas = append(quitacao.VendasQuitadas, p.IDVenda)
+		}
+	}
+
+	saldo, err := saldoDevedor(ctx, tx, idCliente)
+	if err != nil {
+		return nil, err
+	}
+	quitacao.SaldoDevedor = arredondar(saldo)
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &quitacao, nil
+}
+
+// Saldo devedor dos clientes separado pela idade de cada venda em aberto
+// na data de referência (YYYY-MM-DD, padrão hoje). O saldo é o daquela data:
+// vendas quitadas depois dela contam como em aberto e só os pagamentos feitos
+// até ela abatem o restante.
+func (s *Store) GetAging(ctx context.Context, data string) (*model.RelatorioAging, error) {
+	ref := time.Now()
+	if data != "" {
+		t, err := time.Parse("2006-01-02", data)
+		if err != nil {
+			return nil, ErrDataInvalida
+		}
+		ref = t
+	}
+	rel := model.RelatorioAging{
+		DataReferencia: ref.Format("2006-01-02"),
+		Clientes:       make([]model.AgingCliente, 0),
+	}
+
+	query := `
+		SELECT c.id_cliente, c.nome, c.limite_credito, vt.data_hora_venda,
+			($1::date - vt.data_hora_venda::date), (vt.total_liquido - COALESCE(p.pago, 0))::float8
+		FROM venda_totais vt
+		JOIN Cliente c ON c.id_cliente = vt.id_cliente
+		LEFT JOIN (
+			SELECT id_venda, SUM(valor) AS pago
+			FROM pagamento
+			WHERE data_hora::date <= $1::date
+			GROUP BY id_venda
+		) p ON p.id_venda = vt.id_venda
+		WHERE (vt.data_hora_pagamento IS NULL OR vt.data_hora_pagamento::date > $1::date)
+			AND vt.total_liquido > COALESCE(p.pago, 0)
+			AND vt.data_hora_venda::date <= $1::date
+		ORDER BY c.nome, c.id_cliente, vt.data_hora_venda`
+	rows, err := s.db.QueryContext(ctx, query, rel.DataReferencia)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c model.AgingCliente
+		var dataVenda time.Time
+		var dias int
+		var restante float64
+		if err := rows.Scan(&c.IDCliente, &c.Nome, &c.LimiteCredito, &dataVenda, &dias, &restante); err != nil {
+			return nil, err
+		}
+		n := len(rel.Clientes)
+		if n == 0 || rel.Clientes[n-1].IDCliente != c.IDCliente {
+			c.VendaMaisAntiga = dataVenda
+			rel.Clientes = append(rel.Clientes, c)
+			n++
+		}
+		atual := &rel.Clientes[n-1]
+		atual.VendasEmAberto++
+		SomarFaixa(&atual.FaixasAging, dias, restante)
+		SomarFaixa(&rel.Total, dias, restante)
+	}
+	return &rel, rows.Err()
+}
@@ -0,0 +1,34 @@
+package compras
+
+import "edna/internal/model"
+
+// Status de um pedido enviado a partir do que já foi recebido em cada linha.
+// Receber a mais numa linha não compensa a falta em outra.
+func StatusRecebimento(itens []model.ItemPedidoCompra) string {
+	completo := len(itens) > 0
+	algum := false
+	for _, it := range itens {
+		if it.QuantidadeRecebida > 0 {
+			algum = true
+		}
+		if it.QuantidadeRecebida < it.Quantidade {
+			completo = false
+		}
+	}
+	switch {
+	case completo:
+		return model.PedidoRecebido
+	case algum:
+		return model.PedidoParcialmenteRecebido
+	default:
+		return model.PedidoEnviado
+	}
+}
+
+// Linha com quantidade recebida diferente da pedida ou preço médio diferente do combinado
+func Divergente(d model.DivergenciaItem) bool {
+	if d.Diferenca != 0 {
+		return true
+	}
+	return d.PrecoMedioRecebido != nil && centavos(*d.PrecoMedioRecebido) != centavos(d.PrecoCombinado)
+}
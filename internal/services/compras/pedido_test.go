@@ -0,0 +1,27 @@
+package compras
+
+import (
+	"edna/internal/model"
+	"testing"
+)
+
+func TestStatusRecebimento(t *testing.T) {
+	item := func(pedida, recebida int64) model.ItemPedidoCompra {
+		return model.ItemPedidoCompra{Quantidade: pedida, QuantidadeRecebida: recebida}
+	}
+	cases := []struct {
+		nome     string
+		itens    []model.ItemPedidoCompra
+		esperado string
+	}{
+		{"nada recebido", []model.ItemPedidoCompra{item(10, 0), item(5, 0)}, model.PedidoEnviado},
+		{"uma linha recebida", []model.ItemPedidoCompra{item(10, 10), item(5, 0)}, model.PedidoParcialmenteRecebido},
+		{"sobra nao compensa falta", []model.ItemPedidoCompra{item(10, 15), item(5, 4)}, model.PedidoParcialmenteRecebido},
+		{"tudo recebido", []model.ItemPedidoCompra{item(10, 10), item(5, 6)}, model.PedidoRecebido},
+	}
+	for _, c := range cases {
+		if got := StatusRecebimento(c.itens); got != c.esperado {
+			t.Fatalf("%s: expected %s, got %s", c.nome, c.esperado, got)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package compras
+
+import (
+	"context"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// Padrões de GET /compras/sugestoes
+const (
+	DiasVendasPadrao = 30
+	CoberturaPadrao  = 7
+)
+
+type Handler struct {
+	store ComprasStore
+}
+
+type ComprasStore interface {
+	GetSugestoes(ctx context.Context, dias, cobertura int) ([]model.SugestaoCompra, error)
+	GetPedidos(ctx context.Context, filter util.Filter) ([]model.PedidoCompra, error)
+	GetPedido(ctx context.Context, id int64) (*model.PedidoCompra, error)
+	CreatePedido(ctx context.Context, p *model.PedidoCompra) error
+	UpdatePedido(ctx context.Context, p *model.PedidoCompra) error
+	EnviarPedido(ctx context.Context, id int64) (*model.PedidoCompra, error)
+	CancelarPedido(ctx context.Context, id int64) (*model.PedidoCompra, error)
+	ReceberItem(ctx context.Context, idPedido, idItem int64, rc model.RecebimentoCreate) (*model.Lote, error)
+	GetDivergencias(ctx context.Context, id int64) (*model.DivergenciasPedido, error)
+}
+
+func NewHandler(store ComprasStore) *Handler {
+	return &Handler{store}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /compras/sugestoes", h.getSugestoes)
+
+	mux.HandleFunc("GET /compras/pedidos", h.getPedidos)
+	mux.HandleFunc("POST /compras/pedidos", h.createPedido)
+	mux.HandleFunc("GET /compras/pedidos/{id}", h.fetchPedido)
+	mux.HandleFunc("PUT /compras/pedidos/{id}", h.updatePedido)
+	mux.HandleFunc("POST /compras/pedidos/{id}/enviar", h.enviarPedido)
+	mux.HandleFunc("POST /compras/pedidos/{id}/cancelar", h.cancelarPedido)
+	mux.HandleFunc("POST /compras/pedidos/{id}/itens/{id_item}/receber", h.receberItem)
+	mux.HandleFunc("GET /compras/pedidos/{id}/divergencias", h.fetchDivergencias)
+}
+
+// Lê um parâmetro inteiro positivo da query, usando padrao quando ausente
+func intParam(r *http.Request, nome string, padrao int) (int, bool) {
+	v := r.URL.Query().Get(nome)
+	if v == "" {
+		return padrao, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// @Summary Purchase suggestions
+// @Description Lists products below their minimum stock with a suggested quantity, based on the average daily sales of the last `dias` days, and the supplier of the most recent lote
+// @Tags Compras
+// @Produce json
+// @Param dias query int false "Days of sales used for the daily average (default 30)"
+// @Param cobertura query int false "Days of sales the purchase should cover (default 7)"
+// @Success 200 {array} model.SugestaoCompra
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /compras/sugestoes [get]
+func (h *Handler) getSugestoes(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	dias, ok := intParam(r, "dias", DiasVendasPadrao)
+	if !ok {
+		util.ErrorJSON(w, "dias must be a positive integer", http.StatusBadRequest)
+		return
+	}
+	cobertura, ok := intParam(r, "cobertura", CoberturaPadrao)
+	if !ok {
+		util.ErrorJSON(w, "cobertura must be a positive integer", http.StatusBadRequest)
+		return
+	}
+
+	sugestoes, err := h.store.GetSugestoes(ctx, dias, cobertura)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, sugestoes); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary List purchase orders
+// @Tags Compras
+// @Produce json
+// @Param filter-status query string false "Filter by status (rascunho, enviado, parcialmente_recebido, recebido, cancelado) using operators: eq, ne"
+// @Param filter-id_fornecedor query int false "Filter by id_fornecedor using operators: eq, ne, gt, lt"
+// @Param filter-data_criacao query string false "Filter by data_criacao using operators: eq, ne, gt, lt"
+// @Param sort query string false "Sort fields: data_criacao, data_envio, data_previsao, id_fornecedor, status. Prefix with '-' for desc."
+// @Param offset query int false "Pagination offset (default 0)"
+// @Param limit query int false "Pagination limit (default 10)"
+// @Success 200 {array} model.PedidoCompra
+// @Failure 500 {object} types.ErrorResponse
+// @Router /compras/pedidos [get]
+func (h *Handler) getPedidos(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	filters, err := NewPedidoCompraFilter(r.URL.Query())
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	pedidos, err := h.store.GetPedidos(ctx, filters)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := util.WriteJSON(w, http.StatusOK, pedidos); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Create purchase order
+// @Description Creates a draft purchase order with its lines (product, quantity and agreed unit price)
+// @Tags Compras
+// @Accept json
+// @Produce json
+// @Param pedido body model.PedidoCompraCreate true "Pedido payload"
+// @Success 201 {object} model.PedidoCompra
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /compras/pedidos [post]
+func (h *Handler) createPedido(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	var payload model.PedidoCompraCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	pedido := payload.ToPedidoCompra()
+	if err := h.store.CreatePedido(ctx, &pedido); err != nil {
+		writePedidoError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, pedido)
+}
+
+// @Summary Get purchase order by ID
+// @Tags Compras
+// @Produce json
+// @Param id path int true "Pedido ID"
+// @Success 200 {object} model.PedidoCompra
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /compras/pedidos/{id} [get]
+func (h *Handler) fetchPedido(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pedido, err := h.store.GetPedido(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Pedido de compra not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, pedido); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Update purchase order
+// @Description Replaces the supplier, notes and lines of a draft purchase order
+// @Tags Compras
+// @Accept json
+// @Produce json
+// @Param id path int true "Pedido ID"
+// @Param pedido body model.PedidoCompraCreate true "Pedido payload"
+// @Success 200 {object} model.PedidoCompra
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /compras/pedidos/{id} [put]
+func (h *Handler) updatePedido(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.PedidoCompraCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	pedido := payload.ToPedidoCompra()
+	pedido.IDPedido = id
+	if err := h.store.UpdatePedido(ctx, &pedido); err != nil {
+		writePedidoError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, pedido)
+}
+
+// @Summary Send purchase order
+// @Description Moves a draft purchase order to enviado
+// @Tags Compras
+// @Produce json
+// @Param id path int true "Pedido ID"
+// @Success 200 {object} model.PedidoCompra
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /compras/pedidos/{id}/enviar [post]
+func (h *Handler) enviarPedido(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pedido, err := h.store.EnviarPedido(ctx, id)
+	if err != nil {
+		writePedidoError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, pedido)
+}
+
+// @Summary Cancel purchase order
+// @Description Cancels a purchase order that was not fully received. Lotes already received are kept.
+// @Tags Compras
+// @Produce json
+// @Param id path int true "Pedido ID"
+// @Success 200 {object} model.PedidoCompra
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /compras/pedidos/{id}/cancelar [post]
+func (h *Handler) cancelarPedido(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pedido, err := h.store.CancelarPedido(ctx, id)
+	if err != nil {
+		writePedidoError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, pedido)
+}
+
+// @Summary Receive a purchase order line
+// @Description Creates the Lote for the received quantity of the line and updates the order status (parcialmente_recebido or recebido)
+// @Tags Compras
+// @Accept json
+// @Produce json
+// @Param id path int true "Pedido ID"
+// @Param id_item path int true "Item do pedido ID"
+// @Param recebimento body model.RecebimentoCreate true "Recebimento payload"
+// @Success 201 {object} model.Lote
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /compras/pedidos/{id}/itens/{id_item}/receber [post]
+func (h *Handler) receberItem(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	idItem, err := strconv.ParseInt(r.PathValue("id_item"), 10, 64)
+	if err != nil {
+		util.ErrorJSON(w, util.ErrInvalidID.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.RecebimentoCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	lote, err := h.store.ReceberItem(ctx, id, idItem, payload)
+	if err != nil {
+		writePedidoError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, lote)
+}
+
+// @Summary Purchase order discrepancies
+// @Description Lines where the received quantity differs from the ordered quantity (diferenca = recebida - pedida) or the average received price differs from the agreed price
+// @Tags Compras
+// @Produce json
+// @Param id path int true "Pedido ID"
+// @Success 200 {object} model.DivergenciasPedido
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /compras/pedidos/{id}/divergencias [get]
+func (h *Handler) fetchDivergencias(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	div, err := h.store.GetDivergencias(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Pedido de compra not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, div); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writePedidoError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		util.ErrorJSON(w, "Pedido de compra not found.", http.StatusNotFound)
+	case errors.Is(err, ErrPedidoNaoEditavel), errors.Is(err, ErrTransicaoInvalida):
+		util.ErrorJSON(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrPedidoSemItens), errors.Is(err, ErrItemInvalido), errors.Is(err, ErrQuantidadeInvalida):
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+	default:
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+	}
+}
@@ -0,0 +1,479 @@
+package compras
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/services/lote"
+	"edna/internal/types"
+	"edna/internal/util"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrPedidoSemItens     = errors.New("Pedido de compra precisa de ao menos um item")
+	ErrItemInvalido       = errors.New("Item do pedido precisa de quantidade e preco_unitario positivos")
+	ErrPedidoNaoEditavel  = errors.New("Só pedidos em rascunho podem ser alterados")
+	ErrTransicaoInvalida  = errors.New("Transição de status inválida para o pedido")
+	ErrQuantidadeInvalida = errors.New("Quantidade recebida deve ser positiva")
+)
+
+// *sql.DB e *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Produtos com estoque disponível abaixo do mínimo cadastrado em reposicao_produto.
+// A média diária considera as vendas dos últimos `dias` dias e o fornecedor sugerido
+// é o do lote mais recente do produto. Ingredientes consumidos por receitas contam como vendidos.
+func (s *Store) GetSugestoes(ctx context.Context, dias, cobertura int) ([]model.SugestaoCompra, error) {
+	query := `
+	WITH saidas AS (
+		SELECT id_venda, id_lote, quantidade
+			FROM item_venda
+			WHERE id_lote IS NOT NULL
+		UNION ALL
+		SELECT iv.id_venda, cr.id_lote, cr.quantidade
+			FROM consumo_receita cr
+			JOIN item_venda iv ON iv.id_item_venda = cr.id_item_venda
+	), vendidos AS (
+		SELECT l.id_produto, SUM(s.quantidade) AS quantidade
+			FROM saidas s
+			JOIN Lote l ON l.id_lote = s.id_lote
+			JOIN Venda v ON v.id_venda = s.id_venda
+			WHERE v.data_hora_venda >= now() - make_interval(days => $1)
+			GROUP BY l.id_produto
+	), ultimo_lote AS (
+		SELECT DISTINCT ON (id_produto) id_produto, id_fornecedor, preco_unitario
+			FROM Lote
+			ORDER BY id_produto, data_fornecimento DESC, id_lote DESC
+	)
+	SELECT rp.id_produto, ep.nome, ep.disponivel, rp.estoque_minimo, rp.quantidade_reposicao,
+			COALESCE(vd.quantidade, 0), ul.id_fornecedor, f.nome, ul.preco_unitario
+		FROM reposicao_produto rp
+		JOIN estoque_produto ep ON ep.id_produto = rp.id_produto
+		LEFT JOIN vendidos vd ON vd.id_produto = rp.id_produto
+		LEFT JOIN ultimo_lote ul ON ul.id_produto = rp.id_produto
+		LEFT JOIN Fornecedor f ON f.id_fornecedor = ul.id_fornecedor
+		WHERE ep.disponivel < rp.estoque_minimo
+		ORDER BY ep.disponivel - rp.estoque_minimo, ep.nome`
+
+	rows, err := s.db.QueryContext(ctx, query, dias)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sugestoes := make([]model.SugestaoCompra, 0)
+	for rows.Next() {
+		var sc model.SugestaoCompra
+		err := rows.Scan(&sc.IDProduto, &sc.Nome, &sc.Disponivel, &sc.EstoqueMinimo, &sc.QuantidadeReposicao,
+			&sc.VendidosPeriodo, &sc.IDFornecedor, &sc.NomeFornecedor, &sc.UltimoPrecoUnitario)
+		if err != nil {
+			return nil, err
+		}
+		if dias > 0 {
+			sc.MediaDiaria = math.Round(float64(sc.VendidosPeriodo)/float64(dias)*100) / 100
+		}
+		sc.QuantidadeSugerida = SugerirQuantidade(sc.Disponivel, sc.EstoqueMinimo, sc.QuantidadeReposicao,
+			float64(sc.VendidosPeriodo)/float64(max(dias, 1)), cobertura)
+		if sc.UltimoPrecoUnitario != nil {
+			custo := math.Round(*sc.UltimoPrecoUnitario*float64(sc.QuantidadeSugerida)*100) / 100
+			sc.CustoEstimado = &custo
+		}
+		sugestoes = append(sugestoes, sc)
+	}
+	return sugestoes, rows.Err()
+}
+
+const selectPedido = `
+	SELECT id_pedido, id_fornecedor, status::text, observacao, data_previsao, data_criacao, data_envio
+	FROM pedido_compra`
+
+func scanPedido(row interface{ Scan(...any) error }) (*model.PedidoCompra, error) {
+	var p model.PedidoCompra
+	err := row.Scan(&p.IDPedido, &p.IDFornecedor, &p.Status, &p.Observacao, &p.DataPrevisao, &p.DataCriacao, &p.DataEnvio)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	p.Itens = make([]model.ItemPedidoCompra, 0)
+	return &p, nil
+}
+
+// Linhas dos pedidos com a quantidade já recebida em lotes
+func itensPedidos(ctx context.Context, q querier, ids []int64) (map[int64][]model.ItemPedidoCompra, error) {
+	query := `
+		SELECT ip.id_item_pedido, ip.id_pedido, ip.id_produto, ip.quantidade, ip.preco_unitario,
+			COALESCE(SUM(l.quantidade_inicial), 0)
+		FROM item_pedido_compra ip
+		LEFT JOIN recebimento_compra rc ON rc.id_item_pedido = ip.id_item_pedido
+		LEFT JOIN Lote l ON l.id_lote = rc.id_lote
+		WHERE ip.id_pedido = ANY($1)
+		GROUP BY ip.id_item_pedido
+		ORDER BY ip.id_item_pedido`
+	rows, err := q.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	itens := make(map[int64][]model.ItemPedidoCompra)
+	for rows.Next() {
+		var it model.ItemPedidoCompra
+		if err := rows.Scan(&it.IDItemPedido, &it.IDPedido, &it.IDProduto, &it.Quantidade, &it.PrecoUnitario, &it.QuantidadeRecebida); err != nil {
+			return nil, err
+		}
+		itens[it.IDPedido] = append(itens[it.IDPedido], it)
+	}
+	return itens, rows.Err()
+}
+
+func getPedido(ctx context.Context, q querier, id int64) (*model.PedidoCompra, error) {
+	p, err := scanPedido(q.QueryRowContext(ctx, selectPedido+" WHERE id_pedido = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	itens, err := itensPedidos(ctx, q, []int64{id})
+	if err != nil {
+		return nil, err
+	}
+	if it, ok := itens[id]; ok {
+		p.Itens = it
+	}
+	return p, nil
+}
+
+func (s *Store) GetPedidos(ctx context.Context, filter util.Filter) ([]model.PedidoCompra, error) {
+	query := "SELECT * FROM (" + selectPedido + ") AS p"
+	rows, err := util.QueryRowsWithFilter(s.db, ctx, query, &filter, "p")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pedidos := make([]model.PedidoCompra, 0)
+	ids := make([]int64, 0)
+	for rows.Next() {
+		p, err := scanPedido(rows)
+		if err != nil {
+			return nil, err
+		}
+		pedidos = append(pedidos, *p)
+		ids = append(ids, p.IDPedido)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	itens, err := itensPedidos(ctx, s.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pedidos {
+		if it, ok := itens[pedidos[i].IDPedido]; ok {
+			pedidos[i].Itens = it
+		}
+	}
+	return pedidos, nil
+}
+
+func (s *Store) GetPedido(ctx context.Context, id int64) (*model.PedidoCompra, error) {
+	return getPedido(ctx, s.db, id)
+}
+
+func validarItens(itens []model.ItemPedidoCompra) error {
+	if len(itens) == 0 {
+		return ErrPedidoSemItens
+	}
+	for _, it := range itens {
+		if it.Quantidade <= 0 || centavos(it.PrecoUnitario) <= 0 {
+			return fmt.Errorf("%w (produto %d)", ErrItemInvalido, it.IDProduto)
+		}
+	}
+	return nil
+}
+
+func inserirItens(ctx context.Context, tx *sql.Tx, p *model.PedidoCompra) error {
+	query := `
+		INSERT INTO item_pedido_compra (id_pedido, id_produto, quantidade, preco_unitario)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id_item_pedido;`
+	for i := range p.Itens {
+		it := &p.Itens[i]
+		it.IDPedido = p.IDPedido
+		it.QuantidadeRecebida = 0
+		if err := tx.QueryRowContext(ctx, query, p.IDPedido, it.IDProduto, it.Quantidade, it.PrecoUnitario).Scan(&it.IDItemPedido); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cria o pedido em rascunho com as suas linhas
+func (s *Store) CreatePedido(ctx context.Context, p *model.PedidoCompra) error {
+	if err := validarItens(p.Itens); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO pedido_compra (id_fornecedor, observacao, data_previsao)
+		VALUES ($1, $2, $3)
+		RETURNING id_pedido, status::text, data_criacao;`
+	err = tx.QueryRowContext(ctx, query, p.IDFornecedor, p.Observacao, p.DataPrevisao).Scan(&p.IDPedido, &p.Status, &p.DataCriacao)
+	if err != nil {
+		return err
+	}
+	if err := inserirItens(ctx, tx, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Bloqueia o pedido e retorna o status atual
+func travarPedido(ctx context.Context, tx *sql.Tx, id int64) (string, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, "SELECT status::text FROM pedido_compra WHERE id_pedido = $1 FOR UPDATE", id).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", types.ErrNotFound
+		}
+		return "", err
+	}
+	return status, nil
+}
+
+// Substitui cabeçalho e linhas de um pedido ainda em rascunho
+func (s *Store) UpdatePedido(ctx context.Context, p *model.PedidoCompra) error {
+	if err := validarItens(p.Itens); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := travarPedido(ctx, tx, p.IDPedido)
+	if err != nil {
+		return err
+	}
+	if status != model.PedidoRascunho {
+		return ErrPedidoNaoEditavel
+	}
+
+	query := `
+		UPDATE pedido_compra SET id_fornecedor = $2, observacao = $3, data_previsao = $4
+		WHERE id_pedido = $1
+		RETURNING status::text, data_criacao, data_envio;`
+	err = tx.QueryRowContext(ctx, query, p.IDPedido, p.IDFornecedor, p.Observacao, p.DataPrevisao).Scan(&p.Status, &p.DataCriacao, &p.DataEnvio)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM item_pedido_compra WHERE id_pedido = $1", p.IDPedido); err != nil {
+		return err
+	}
+	if err := inserirItens(ctx, tx, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Marca o pedido em rascunho como enviado ao fornecedor
+func (s *Store) EnviarPedido(ctx context.Context, id int64) (*model.PedidoCompra, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	status, err := travarPedido(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status != model.PedidoRascunho {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrTransicaoInvalida, status, model.PedidoEnviado)
+	}
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT count(*) FROM item_pedido_compra WHERE id_pedido = $1", id).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrPedidoSemItens
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE pedido_compra SET status = 'enviado', data_envio = now() WHERE id_pedido = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	p, err := getPedido(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	return p, tx.Commit()
+}
+
+// Cancela o pedido. Lotes já recebidos continuam no estoque.
+func (s *Store) CancelarPedido(ctx context.Context, id int64) (*model.PedidoCompra, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	status, err := travarPedido(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status == model.PedidoRecebido || status == model.PedidoCancelado {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrTransicaoInvalida, status, model.PedidoCancelado)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE pedido_compra SET status = 'cancelado' WHERE id_pedido = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	p, err := getPedido(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	return p, tx.Commit()
+}
+
+// Recebe uma linha do pedido criando o Lote correspondente e atualiza o status do pedido
+func (s *Store) ReceberItem(ctx context.Context, idPedido, idItem int64, rc model.RecebimentoCreate) (*model.Lote, error) {
+	if rc.Quantidade <= 0 {
+		return nil, ErrQuantidadeInvalida
+	}
+	if rc.PrecoUnitario != nil && centavos(*rc.PrecoUnitario) <= 0 {
+		return nil, fmt.Errorf("%w (preco_unitario)", ErrItemInvalido)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	status, err := travarPedido(ctx, tx, idPedido)
+	if err != nil {
+		return nil, err
+	}
+	if status != model.PedidoEnviado && status != model.PedidoParcialmenteRecebido {
+		return nil, fmt.Errorf("%w: pedido %s não pode receber itens", ErrTransicaoInvalida, status)
+	}
+
+	p, err := getPedido(ctx, tx, idPedido)
+	if err != nil {
+		return nil, err
+	}
+	var item *model.ItemPedidoCompra
+	for i := range p.Itens {
+		if p.Itens[i].IDItemPedido == idItem {
+			item = &p.Itens[i]
+		}
+	}
+	if item == nil {
+		return nil, types.ErrNotFound
+	}
+
+	quantidade := int(rc.Quantidade)
+	l := model.Lote{
+		IdFornecedor:      p.IDFornecedor,
+		IdProduto:         item.IDProduto,
+		DataFornecimento:  time.Now(),
+		Validade:          rc.Validade,
+		PrecoUnitario:     item.PrecoUnitario,
+		QuantidadeInicial: &quantidade,
+	}
+	if rc.DataFornecimento != nil {
+		l.DataFornecimento = *rc.DataFornecimento
+	}
+	if rc.PrecoUnitario != nil {
+		l.PrecoUnitario = *rc.PrecoUnitario
+	}
+	if err := lote.Inserir(ctx, tx, &l); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO recebimento_compra (id_lote, id_item_pedido) VALUES ($1, $2)", l.Id, idItem)
+	if err != nil {
+		return nil, err
+	}
+
+	item.QuantidadeRecebida += rc.Quantidade
+	_, err = tx.ExecContext(ctx, "UPDATE pedido_compra SET status = $2::status_pedido_compra WHERE id_pedido = $1", idPedido, StatusRecebimento(p.Itens))
+	if err != nil {
+		return nil, err
+	}
+	return &l, tx.Commit()
+}
+
+// Linhas do pedido em que o recebido não bate com o pedido, em quantidade ou preço
+func (s *Store) GetDivergencias(ctx context.Context, id int64) (*model.DivergenciasPedido, error) {
+	p, err := scanPedido(s.db.QueryRowContext(ctx, selectPedido+" WHERE id_pedido = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	div := model.DivergenciasPedido{
+		IDPedido:     p.IDPedido,
+		IDFornecedor: p.IDFornecedor,
+		Status:       p.Status,
+		Itens:        make([]model.DivergenciaItem, 0),
+	}
+
+	query := `
+		SELECT ip.id_item_pedido, ip.id_produto, pr.nome, ip.quantidade, ip.preco_unitario,
+			COALESCE(SUM(l.quantidade_inicial), 0),
+			ROUND(SUM(l.preco_unitario * l.quantidade_inicial) / NULLIF(SUM(l.quantidade_inicial), 0), 2)
+		FROM item_pedido_compra ip
+		JOIN Produto pr ON pr.id_produto = ip.id_produto
+		LEFT JOIN recebimento_compra rc ON rc.id_item_pedido = ip.id_item_pedido
+		LEFT JOIN Lote l ON l.id_lote = rc.id_lote
+		WHERE ip.id_pedido = $1
+		GROUP BY ip.id_item_pedido, pr.nome
+		ORDER BY ip.id_item_pedido`
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d model.DivergenciaItem
+		err := rows.Scan(&d.IDItemPedido, &d.IDProduto, &d.NomeProduto, &d.QuantidadePedida, &d.PrecoCombinado,
+			&d.QuantidadeRecebida, &d.PrecoMedioRecebido)
+		if err != nil {
+			return nil, err
+		}
+		d.Diferenca = d.QuantidadeRecebida - d.QuantidadePedida
+		if Divergente(d) {
+			div.Itens = append(div.Itens, d)
+		}
+	}
+	return &div, rows.Err()
+}
+
+func centavos(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
@@ -0,0 +1,12 @@
+package compras
+
+import "math"
+
+// Quantidade a comprar para um produto abaixo do estoque mínimo: o suficiente
+// para voltar ao mínimo e cobrir a média de vendas pelos dias de cobertura,
+// nunca menos que a quantidade de reposição cadastrada.
+func SugerirQuantidade(disponivel, minimo, reposicao int64, mediaDiaria float64, cobertura int) int64 {
+	falta := max(minimo-disponivel, 0)
+	demanda := int64(math.Ceil(mediaDiaria * float64(cobertura)))
+	return max(reposicao, falta+demanda)
+}
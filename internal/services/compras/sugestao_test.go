@@ -0,0 +1,23 @@
+package compras
+
+import "testing"
+
+func TestSugerirQuantidade(t *testing.T) {
+	cases := []struct {
+		nome                          string
+		disponivel, minimo, reposicao int64
+		media                         float64
+		cobertura                     int
+		esperado                      int64
+	}{
+		{"sem vendas usa a reposicao", 2, 10, 24, 0, 7, 24},
+		{"demanda maior que a reposicao", 2, 10, 24, 5.5, 7, 8 + 39},
+		{"estoque zerado repoe o minimo e a demanda", 0, 12, 6, 1, 7, 12 + 7},
+	}
+	for _, c := range cases {
+		got := SugerirQuantidade(c.disponivel, c.minimo, c.reposicao, c.media, c.cobertura)
+		if got != c.esperado {
+			t.Fatalf("%s: expected %d, got %d", c.nome, c.esperado, got)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package desconto
+
+import (
+	"context"
+	"edna/internal/model"
+	"math"
+	"sort"
+)
+
+// Estados visitados pela busca exaustiva. Acima disso fica a melhor combinação
+// encontrada até ali, que nunca é pior que a gulosa.
+const LimiteEstados = 200_000
+
+// Linha de item_venda a ser avaliada, já com o produto do lote.
+type Item struct {
+	IDItemVenda   int64
+	IDProduto     int64
+	Quantidade    int64
+	ValorUnitario float64
+}
+
+// Oferta ativa com os produtos e quantidades que formam uma aplicação.
+type Regra struct {
+	model.Oferta
+	Itens []model.ItemOferta
+}
+
+// Desconto de uma aplicação da oferta sobre o valor bruto dos itens consumidos.
+// Valor fixo tem precedência sobre percentual, e nunca gera desconto negativo.
+func (r Regra) desconto(bruto float64) float64 {
+	switch {
+	case r.ValorFixo != nil:
+		return math.Max(0, bruto-*r.ValorFixo)
+	case r.PercentualDesconto != nil:
+		return bruto * float64(*r.PercentualDesconto) / 100
+	default:
+		return 0
+	}
+}
+
+// Unidades de um produto na venda, das mais caras para as mais baratas.
+// As ofertas consomem sempre as unidades mais caras primeiro.
+type estoque struct {
+	linhas []int // índices em itens
+	total  int64
+}
+
+type motor struct {
+	itens    []Item
+	produtos map[int64]*estoque
+	regras   []Regra
+
+	melhor     float64
+	melhorApls []int64
+
+	ctx     context.Context
+	estados int
+	err     error
+}
+
+// Avalia as ofertas sobre os itens de uma venda e escolhe a combinação de
+// aplicações com maior desconto para o cliente. Cada unidade vendida participa
+// de no máximo uma aplicação. Para quando o contexto é cancelado.
+func Avaliar(ctx context.Context, idVenda int64, itens []Item, regras []Regra) (model.AvaliacaoOfertas, error) {
+	m := motor{
+		itens:    itens,
+		produtos: make(map[int64]*estoque),
+		ctx:      ctx,
+	}
+	for i, it := range itens {
+		e, ok := m.produtos[it.IDProduto]
+		if !ok {
+			e = &estoque{}
+			m.produtos[it.IDProduto] = e
+		}
+		e.linhas = append(e.linhas, i)
+		e.total += it.Quantidade
+	}
+	for _, e := range m.produtos {
+		sort.SliceStable(e.linhas, func(a, b int) bool {
+			return itens[e.linhas[a]].ValorUnitario > itens[e.linhas[b]].ValorUnitario
+		})
+	}
+
+	aval := model.AvaliacaoOfertas{
+		IDVenda:          idVenda,
+		Aplicaveis:       make([]model.OfertaAplicavel, 0),
+		MelhorCombinacao: make([]model.OfertaAplicavel, 0),
+		Itens:            make([]model.ItemDesconto, 0, len(itens)),
+		Aplicacoes:       make([]model.AplicaOferta, 0),
+	}
+
+	// Ofertas que cabem na venda quando avaliadas isoladamente
+	vazio := make(map[int64]int64)
+	for _, r := range regras {
+		n := m.maxAplicacoes(r, vazio)
+		if n == 0 {
+			continue
+		}
+		d := r.desconto(m.brutoAplicacao(r, vazio))
+		if d <= 0 {
+			continue
+		}
+		m.regras = append(m.regras, r)
+		aval.Aplicaveis = append(aval.Aplicaveis, model.OfertaAplicavel{
+			IDOferta:   r.Id,
+			Nome:       r.Nome,
+			Aplicacoes: n,
+			Desconto:   arredondar(d),
+		})
+	}
+
+	m.melhorApls = make([]int64, len(m.regras))
+	m.guloso()
+	m.buscar(0, make(map[int64]int64), make([]int64, len(m.regras)), 0)
+	if m.err != nil {
+		return aval, m.err
+	}
+
+	m.montar(&aval)
+	return aval, nil
+}
+
+// Ponto de partida da busca: aplica as ofertas da de maior desconto por
+// aplicação para a menor, enquanto couberem.
+func (m *motor) guloso() {
+	vazio := make(map[int64]int64)
+	ordem := make([]int, len(m.regras))
+	descontos := make([]float64, len(m.regras))
+	for i, r := range m.regras {
+		ordem[i] = i
+		descontos[i] = r.desconto(m.brutoAplicacao(r, vazio))
+	}
+	sort.SliceStable(ordem, func(a, b int) bool { return descontos[ordem[a]] > descontos[ordem[b]] })
+
+	consumido := make(map[int64]int64)
+	for _, i := range ordem {
+		r := m.regras[i]
+		for m.maxAplicacoes(r, consumido) > 0 {
+			d := r.desconto(m.brutoAplicacao(r, consumido))
+			if d <= 0 {
+				break
+			}
+			m.melhor += d
+			m.melhorApls[i]++
+			for _, io := range r.Itens {
+				consumido[io.IDProduto] += io.Quantidade
+			}
+		}
+	}
+}
+
+func (m *motor) esgotada() bool {
+	return m.err != nil || m.estados >= LimiteEstados
+}
+
+// Conta o estado e diz se a busca deve parar, pelo limite ou pelo contexto.
+func (m *motor) parar() bool {
+	if m.esgotada() {
+		return true
+	}
+	if m.estados%1024 == 0 {
+		m.err = m.ctx.Err()
+	}
+	m.estados++
+	return m.err != nil
+}
+
+// Quantas vezes a oferta ainda cabe nas unidades não consumidas.
+func (m *motor) maxAplicacoes(r Regra, consumido map[int64]int64) int64 {
+	if len(r.Itens) == 0 {
+		return 0
+	}
+	n := int64(math.MaxInt64)
+	for _, io := range r.Itens {
+		e, ok := m.produtos[io.IDProduto]
+		if !ok || io.Quantidade <= 0 {
+			return 0
+		}
+		n = min(n, (e.total-consumido[io.IDProduto])/io.Quantidade)
+	}
+	return n
+}
+
+// Valor das próximas n unidades do produto a partir da posição inicio.
+func (m *motor) valor(idProduto, inicio, n int64) float64 {
+	var total float64
+	for _, p := range m.consumir(idProduto, inicio, n) {
+		total += p.valor
+	}
+	return total
+}
+
+// Valor bruto da próxima aplicação da oferta.
+func (m *motor) brutoAplicacao(r Regra, consumido map[int64]int64) float64 {
+	var bruto float64
+	for _, io := range r.Itens {
+		bruto += m.valor(io.IDProduto, consumido[io.IDProduto], io.Quantidade)
+	}
+	return bruto
+}
+
+// Busca exaustiva sobre o número de aplicações de cada oferta, até LimiteEstados.
+// Como as unidades mais caras são consumidas primeiro o desconto de cada nova
+// aplicação não cresce, então paramos de aplicar quando ele zera.
+func (m *motor) buscar(i int, consumido map[int64]int64, apls []int64, total float64) {
+	if m.parar() {
+		return
+	}
+	if i == len(m.regras) {
+		if total > m.melhor+1e-9 {
+			m.melhor = total
+			copy(m.melhorApls, apls)
+		}
+		return
+	}
+
+	r := m.regras[i]
+	m.buscar(i+1, consumido, apls, total)
+
+	n := m.maxAplicacoes(r, consumido)
+	acumulado := 0.0
+	var k int64
+	for k = 1; k <= n && !m.esgotada(); k++ {
+		d := r.desconto(m.brutoAplicacao(r, consumido))
+		if d <= 0 {
+			break
+		}
+		acumulado += d
+		for _, io := range r.Itens {
+			consumido[io.IDProduto] += io.Quantidade
+		}
+		apls[i] = k
+		m.buscar(i+1, consumido, apls, total+acumulado)
+	}
+
+	// desfaz o consumo
+	for _, io := range r.Itens {
+		consumido[io.IDProduto] -= io.Quantidade * (k - 1)
+	}
+	apls[i] = 0
+}
+
+// Reaplica a melhor combinação distribuindo o desconto de cada aplicação
+// entre as linhas consumidas, proporcionalmente ao valor de cada uma.
+func (m *motor) montar(aval *model.AvaliacaoOfertas) {
+	descontoLinha := make([]float64, len(m.itens))
+	ofertasLinha := make([][]int64, len(m.itens))
+	// (oferta, linha) -> desconto
+	type chave struct {
+		oferta int64
+		linha  int
+	}
+	porAplicacao := make(map[chave]float64)
+	ordem := make([]chave, 0)
+
+	consumido := make(map[int64]int64)
+	for i, r := range m.regras {
+		n := m.melhorApls[i]
+		if n == 0 {
+			continue
+		}
+		var total float64
+		for range n {
+			bruto := m.brutoAplicacao(r, consumido)
+			d := r.desconto(bruto)
+			total += d
+			for _, io := range r.Itens {
+				for _, p := range m.consumir(io.IDProduto, consumido[io.IDProduto], io.Quantidade) {
+					c := chave{r.Id, p.linha}
+					if _, ok := porAplicacao[c]; !ok {
+						ordem = append(ordem, c)
+					}
+					porAplicacao[c] += d * p.valor / bruto
+				}
+				consumido[io.IDProduto] += io.Quantidade
+			}
+		}
+		aval.MelhorCombinacao = append(aval.MelhorCombinacao, model.OfertaAplicavel{
+			IDOferta:   r.Id,
+			Nome:       r.Nome,
+			Aplicacoes: n,
+			Desconto:   arredondar(total),
+		})
+	}
+
+	for _, c := range ordem {
+		d := arredondar(porAplicacao[c])
+		descontoLinha[c.linha] += d
+		ofertasLinha[c.linha] = append(ofertasLinha[c.linha], c.oferta)
+		aval.Aplicacoes = append(aval.Aplicacoes, model.AplicaOferta{
+			IDOferta:    c.oferta,
+			IDVenda:     aval.IDVenda,
+			IDItemVenda: m.itens[c.linha].IDItemVenda,
+			Desconto:    d,
+		})
+	}
+
+	for i, it := range m.itens {
+		bruto := arredondar(float64(it.Quantidade) * it.ValorUnitario)
+		d := arredondar(descontoLinha[i])
+		ofertas := ofertasLinha[i]
+		if ofertas == nil {
+			ofertas = make([]int64, 0)
+		}
+		aval.Itens = append(aval.Itens, model.ItemDesconto{
+			IDItemVenda:   it.IDItemVenda,
+			IDProduto:     it.IDProduto,
+			Quantidade:    it.Quantidade,
+			ValorUnitario: it.ValorUnitario,
+			ValorBruto:    bruto,
+			Desconto:      d,
+			ValorLiquido:  arredondar(bruto - d),
+			Ofertas:       ofertas,
+		})
+		aval.TotalBruto += bruto
+		aval.TotalDesconto += d
+	}
+	aval.TotalBruto = arredondar(aval.TotalBruto)
+	aval.TotalDesconto = arredondar(aval.TotalDesconto)
+	aval.TotalLiquido = arredondar(aval.TotalBruto - aval.TotalDesconto)
+}
+
+type parcela struct {
+	linha int
+	valor float64
+}
+
+// Valor consumido de cada linha ao pegar n unidades do produto a partir de inicio.
+func (m *motor) consumir(idProduto, inicio, n int64) []parcela {
+	res := make([]parcela, 0, 1)
+	pos := int64(0)
+	for _, idx := range m.produtos[idProduto].linhas {
+		it := m.itens[idx]
+		ini := max(inicio, pos)
+		fim := min(inicio+n, pos+it.Quantidade)
+		if fim > ini {
+			res = append(res, parcela{idx, float64(fim-ini) * it.ValorUnitario})
+		}
+		pos += it.Quantidade
+	}
+	return res
+}
+
+func arredondar(v float64) float64 {
+	return math.Round(v*100) / 100
+}
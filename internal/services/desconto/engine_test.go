@@ -0,0 +1,126 @@
+package desconto
+
+import (
+	"context"
+	"edna/internal/model"
+	"testing"
+)
+
+const (
+	cerveja = 1
+	fritas  = 2
+	refri   = 3
+)
+
+func regra(id int64, fixo *float64, pct *int, itens map[int64]int64) Regra {
+	r := Regra{Oferta: model.Oferta{Id: id, ValorFixo: fixo, PercentualDesconto: pct}}
+	for p, q := range itens {
+		r.Itens = append(r.Itens, model.ItemOferta{IDOferta: id, IDProduto: p, Quantidade: q})
+	}
+	return r
+}
+
+func TestAvaliarCombo(t *testing.T) {
+	fixo := 28.0
+	pct := 20
+	itens := []Item{
+		{IDItemVenda: 10, IDProduto: cerveja, Quantidade: 2, ValorUnitario: 12.50},
+		{IDItemVenda: 11, IDProduto: fritas, Quantidade: 1, ValorUnitario: 25.00},
+		{IDItemVenda: 12, IDProduto: refri, Quantidade: 1, ValorUnitario: 6.00},
+	}
+	regras := []Regra{
+		regra(1, nil, &pct, map[int64]int64{cerveja: 1}),
+		regra(2, &fixo, nil, map[int64]int64{fritas: 1, refri: 1}),
+	}
+
+	aval, err := Avaliar(context.Background(), 99, itens, regras)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(aval.Aplicaveis) != 2 {
+		t.Fatalf("expected 2 applicable offers, got %d", len(aval.Aplicaveis))
+	}
+	// 2 cervejas * 2.50 + combo (31 - 28)
+	if aval.TotalDesconto != 8.00 {
+		t.Fatalf("expected total discount 8.00, got %.2f", aval.TotalDesconto)
+	}
+	if aval.TotalBruto != 56.00 || aval.TotalLiquido != 48.00 {
+		t.Fatalf("unexpected totals: bruto %.2f liquido %.2f", aval.TotalBruto, aval.TotalLiquido)
+	}
+
+	var soma float64
+	for _, a := range aval.Aplicacoes {
+		if a.IDVenda != 99 {
+			t.Fatalf("expected id_venda 99, got %d", a.IDVenda)
+		}
+		soma += a.Desconto
+	}
+	if arredondar(soma) != aval.TotalDesconto {
+		t.Fatalf("per item discounts %.2f do not add up to %.2f", soma, aval.TotalDesconto)
+	}
+}
+
+func TestAvaliarEscolheMelhorCombinacao(t *testing.T) {
+	// Oferta 1 usa a cerveja sozinha, oferta 2 dá mais desconto usando a mesma cerveja
+	pctPequeno := 10
+	pctGrande := 50
+	itens := []Item{
+		{IDItemVenda: 1, IDProduto: cerveja, Quantidade: 1, ValorUnitario: 10},
+		{IDItemVenda: 2, IDProduto: refri, Quantidade: 1, ValorUnitario: 10},
+	}
+	regras := []Regra{
+		regra(1, nil, &pctPequeno, map[int64]int64{cerveja: 1}),
+		regra(2, nil, &pctGrande, map[int64]int64{cerveja: 1, refri: 1}),
+	}
+
+	aval, err := Avaliar(context.Background(), 1, itens, regras)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(aval.MelhorCombinacao) != 1 || aval.MelhorCombinacao[0].IDOferta != 2 {
+		t.Fatalf("expected only offer 2 in the best combination, got %+v", aval.MelhorCombinacao)
+	}
+	if aval.TotalDesconto != 10 {
+		t.Fatalf("expected discount 10, got %.2f", aval.TotalDesconto)
+	}
+}
+
+func TestAvaliarSemOfertas(t *testing.T) {
+	itens := []Item{{IDItemVenda: 1, IDProduto: cerveja, Quantidade: 3, ValorUnitario: 12.50}}
+
+	aval, err := Avaliar(context.Background(), 1, itens, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if aval.TotalDesconto != 0 || aval.TotalLiquido != 37.50 || len(aval.Aplicacoes) != 0 {
+		t.Fatalf("expected no discount, got %+v", aval)
+	}
+}
+
+func TestAvaliarLimiteEstados(t *testing.T) {
+	// 20 ofertas independentes com até 4 aplicações cada passam muito do limite
+	pct := 10
+	itens := make([]Item, 0)
+	regras := make([]Regra, 0)
+	for p := int64(1); p <= 20; p++ {
+		itens = append(itens, Item{IDItemVenda: p, IDProduto: p, Quantidade: 4, ValorUnitario: 10})
+		regras = append(regras, regra(p, nil, &pct, map[int64]int64{p: 1}))
+	}
+
+	aval, err := Avaliar(context.Background(), 1, itens, regras)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aval.TotalDesconto != 80.00 {
+		t.Fatalf("expected the greedy combination (80.00), got %.2f", aval.TotalDesconto)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Avaliar(ctx, 1, itens, regras); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
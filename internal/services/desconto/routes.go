@@ -0,0 +1,121 @@
+package desconto
+
+import (
+	"context"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type Handler struct {
+	store DescontoStore
+}
+
+type DescontoStore interface {
+	GetItensVenda(ctx context.Context, idVenda int64) ([]Item, time.Time, error)
+	GetRegrasAtivas(ctx context.Context, data time.Time) ([]Regra, error)
+	Aplicar(ctx context.Context, idVenda int64) (*model.AvaliacaoOfertas, error)
+}
+
+func NewHandler(store DescontoStore) *Handler {
+	return &Handler{store}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /vendas/{id}/ofertas-aplicaveis", h.getAplicaveis)
+	mux.HandleFunc("POST /vendas/{id}/ofertas-aplicaveis/aplicar", h.aplicarMelhor)
+}
+
+// @Summary Evaluate applicable Ofertas for a Venda
+// @Description Finds the offers active at the sale date that match its items and the combination with the biggest discount.
+// @Tags Venda
+// @Produce json
+// @Param id path int true "Venda ID"
+// @Success 200 {object} model.AvaliacaoOfertas
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /vendas/{id}/ofertas-aplicaveis [get]
+func (h *Handler) getAplicaveis(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	aval, err := h.avaliar(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Venda not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, aval); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Apply the best Ofertas combination to a Venda
+// @Description Replaces the aplica_oferta rows of the sale with the best combination found, evaluated with the sale locked.
+// @Description A sale that is paid, has payments or has redeemed points is rejected with 409.
+// @Tags Venda
+// @Produce json
+// @Param id path int true "Venda ID"
+// @Success 200 {object} model.AvaliacaoOfertas
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /vendas/{id}/ofertas-aplicaveis/aplicar [post]
+func (h *Handler) aplicarMelhor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	aval, err := h.store.Aplicar(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrNotFound):
+			util.ErrorJSON(w, "Venda not found.", http.StatusNotFound)
+		case errors.Is(err, ErrVendaFechada):
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+		default:
+			util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+		}
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, aval); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) avaliar(ctx context.Context, idVenda int64) (*model.AvaliacaoOfertas, error) {
+	itens, dataVenda, err := h.store.GetItensVenda(ctx, idVenda)
+	if err != nil {
+		return nil, err
+	}
+	regras, err := h.store.GetRegrasAtivas(ctx, dataVenda)
+	if err != nil {
+		return nil, err
+	}
+	aval, err := Avaliar(ctx, idVenda, itens, regras)
+	if err != nil {
+		return nil, err
+	}
+	return &aval, nil
+}
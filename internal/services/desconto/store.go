@@ -0,0 +1,206 @@
+package desconto
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/types"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrVendaFechada       = errors.New("Ofertas só podem ser alteradas em venda sem pagamentos nem pontos resgatados")
+	ErrOfertaNaoAplicavel = errors.New("Oferta não se aplica ao item da venda")
+)
+
+// *sql.DB e *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db}
+}
+
+// Busca os itens da venda com o produto de cada lote e a data da venda.
+func (s *Store) GetItensVenda(ctx context.Context, idVenda int64) ([]Item, time.Time, error) {
+	var dataVenda time.Time
+	err := s.db.QueryRowContext(ctx, "SELECT data_hora_venda FROM Venda WHERE id_venda = $1", idVenda).Scan(&dataVenda)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, dataVenda, types.ErrNotFound
+		}
+		return nil, dataVenda, err
+	}
+	itens, err := itensVenda(ctx, s.db, idVenda)
+	return itens, dataVenda, err
+}
+
+func itensVenda(ctx context.Context, q querier, idVenda int64) ([]Item, error) {
+	query := `
+		SELECT iv.id_item_venda, iv.id_produto, iv.quantidade, iv.valor_unitario
+		FROM item_venda iv
+		WHERE iv.id_venda = $1
+		ORDER BY iv.id_item_venda;`
+	rows, err := q.QueryContext(ctx, query, idVenda)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	itens := make([]Item, 0)
+	for rows.Next() {
+		var it Item
+		if err := rows.Scan(&it.IDItemVenda, &it.IDProduto, &it.Quantidade, &it.ValorUnitario); err != nil {
+			return nil, err
+		}
+		itens = append(itens, it)
+	}
+	return itens, rows.Err()
+}
+
+// Ofertas vigentes na data, com seus itens. Datas nulas não limitam o período.
+func (s *Store) GetRegrasAtivas(ctx context.Context, data time.Time) ([]Regra, error) {
+	return regrasAtivas(ctx, s.db, data)
+}
+
+func regrasAtivas(ctx context.Context, q querier, data time.Time) ([]Regra, error) {
+	query := `
+		SELECT o.id_oferta, o.nome, o.data_criacao, o.data_inicio, o.data_fim, o.valor_fixo, o.percentual_desconto,
+			io.quantidade, io.id_produto
+		FROM Oferta o
+		JOIN contem_item_oferta io ON io.id_oferta = o.id_oferta
+		WHERE (o.data_inicio IS NULL OR o.data_inicio <= $1::date)
+			AND (o.data_fim IS NULL OR o.data_fim >= $1::date)
+		ORDER BY o.id_oferta, io.id_produto;`
+	rows, err := q.QueryContext(ctx, query, data)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	regras := make([]Regra, 0)
+	for rows.Next() {
+		var o model.Oferta
+		var io model.ItemOferta
+		err := rows.Scan(&o.Id, &o.Nome, &o.DataCriacao, &o.DataInicio, &o.DataFim, &o.ValorFixo, &o.PercentualDesconto,
+			&io.Quantidade, &io.IDProduto)
+		if err != nil {
+			return nil, err
+		}
+		io.IDOferta = o.Id
+		if n := len(regras); n == 0 || regras[n-1].Id != o.Id {
+			regras = append(regras, Regra{Oferta: o})
+		}
+		regras[len(regras)-1].Itens = append(regras[len(regras)-1].Itens, io)
+	}
+	return regras, rows.Err()
+}
+
+// Avalia as ofertas e substitui as aplicadas na venda pela melhor combinação,
+// tudo com a venda bloqueada. Venda quitada, com pagamentos ou com pontos
+// resgatados já tem o total fechado e não muda.
+func (s *Store) Aplicar(ctx context.Context, idVenda int64) (*model.AvaliacaoOfertas, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	dataVenda, err := TravarVendaAberta(ctx, tx, idVenda)
+	if err != nil {
+		return nil, err
+	}
+
+	itens, err := itensVenda(ctx, tx, idVenda)
+	if err != nil {
+		return nil, err
+	}
+	regras, err := regrasAtivas(ctx, tx, dataVenda)
+	if err != nil {
+		return nil, err
+	}
+	aval, err := Avaliar(ctx, idVenda, itens, regras)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM aplica_oferta WHERE id_venda = $1", idVenda); err != nil {
+		return nil, err
+	}
+	query := `
+		INSERT INTO aplica_oferta (id_oferta, id_venda, id_item_venda, desconto)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id_aplica_oferta;`
+	for i := range aval.Aplicacoes {
+		a := &aval.Aplicacoes[i]
+		err := tx.QueryRowContext(ctx, query, a.IDOferta, a.IDVenda, a.IDItemVenda, a.Desconto).Scan(&a.IDAplicaOferta)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &aval, tx.Commit()
+}
+
+// Bloqueia a venda para alterar as ofertas aplicadas e retorna a data da venda.
+// Venda quitada, com pagamentos ou com pontos resgatados já tem o total fechado.
+func TravarVendaAberta(ctx context.Context, tx *sql.Tx, idVenda int64) (time.Time, error) {
+	var dataVenda time.Time
+	var fechada bool
+	query := `
+		SELECT data_hora_venda,
+			data_hora_pagamento IS NOT NULL
+			OR EXISTS (SELECT 1 FROM pagamento WHERE id_venda = $1)
+			OR EXISTS (SELECT 1 FROM aplica_pontos WHERE id_venda = $1)
+		FROM Venda WHERE id_venda = $1 FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, idVenda).Scan(&dataVenda, &fechada); err != nil {
+		if err == sql.ErrNoRows {
+			return dataVenda, types.ErrNotFound
+		}
+		return dataVenda, err
+	}
+	if fechada {
+		return dataVenda, ErrVendaFechada
+	}
+	return dataVenda, nil
+}
+
+// Desconto de cada item da venda (por id_item_venda) quando a oferta é avaliada
+// sozinha na data da venda. Itens que a oferta não alcança ficam de fora, e uma
+// oferta fora do período não dá desconto a nenhum.
+func DescontosOferta(ctx context.Context, q querier, idVenda, idOferta int64) (map[int64]float64, error) {
+	var dataVenda time.Time
+	err := q.QueryRowContext(ctx, "SELECT data_hora_venda FROM Venda WHERE id_venda = $1", idVenda).Scan(&dataVenda)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	itens, err := itensVenda(ctx, q, idVenda)
+	if err != nil {
+		return nil, err
+	}
+	regras, err := regrasAtivas(ctx, q, dataVenda)
+	if err != nil {
+		return nil, err
+	}
+	regras = slices.DeleteFunc(regras, func(r Regra) bool { return r.Id != idOferta })
+
+	aval, err := Avaliar(ctx, idVenda, itens, regras)
+	if err != nil {
+		return nil, err
+	}
+	descontos := make(map[int64]float64)
+	for _, a := range aval.Aplicacoes {
+		descontos[a.IDItemVenda] += a.Desconto
+	}
+	return descontos, nil
+}
@@ -0,0 +1,232 @@
+package escala
+
+import (
+	"context"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type Handler struct {
+	store EscalaStore
+}
+
+type EscalaStore interface {
+	GetSemana(ctx context.Context, segunda time.Time) (*model.SemanaEscala, error)
+	GetByID(ctx context.Context, id int64) (*model.Escala, error)
+	Create(ctx context.Context, e *model.Escala) error
+	Delete(ctx context.Context, id int64) (*model.Escala, error)
+	GetCobertura(ctx context.Context) ([]model.CoberturaMinima, error)
+	SetCobertura(ctx context.Context, m *model.CoberturaMinima) error
+}
+
+func NewHandler(store EscalaStore) *Handler {
+	return &Handler{store}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /escalas", h.getSemana)
+	mux.HandleFunc("POST /escalas", h.create)
+	mux.HandleFunc("GET /escalas/{id}", h.fetch)
+	mux.HandleFunc("DELETE /escalas/{id}", h.delete)
+	mux.HandleFunc("GET /escalas/cobertura", h.getCobertura)
+	mux.HandleFunc("PUT /escalas/cobertura", h.setCobertura)
+}
+
+// @Summary Weekly roster
+// @Description Every shift of the ISO week (monday to sunday) with the employees scheduled, the shifts below the minimum coverage and the sales registered by an employee outside their scheduled shift. The madrugada shift of a date runs from 00:00 to 06:00 of the next day. Days with nothing scheduled are not checked for sales.
+// @Tags Escala
+// @Produce json
+// @Param semana query string false "ISO week, YYYY-Www (default current week)"
+// @Success 200 {object} model.SemanaEscala
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /escalas [get]
+func (h *Handler) getSemana(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	segunda := InicioSemana(time.Now())
+	if s := r.URL.Query().Get("semana"); s != "" {
+		var err error
+		if segunda, err = ParseSemana(s); err != nil {
+			util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	semana, err := h.store.GetSemana(ctx, segunda)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, semana); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Get Escala by ID
+// @Tags Escala
+// @Produce json
+// @Param id path int true "Escala ID"
+// @Success 200 {object} model.Escala
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /escalas/{id} [get]
+func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	escala, err := h.store.GetByID(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Escala not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, escala); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Schedule an employee
+// @Description Puts the employee on a shift of a date. The same employee can work more than one shift a day, but only once per shift.
+// @Tags Escala
+// @Accept json
+// @Produce json
+// @Param escala body model.EscalaCreate true "Escala payload"
+// @Success 201 {object} model.Escala
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /escalas [post]
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	var payload model.EscalaCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	escala := payload.ToEscala()
+	if err := h.store.Create(ctx, &escala); err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, escala)
+}
+
+// @Summary Remove an employee from a shift
+// @Tags Escala
+// @Produce json
+// @Param id path int true "Escala ID"
+// @Success 200 {object} model.Escala
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /escalas/{id} [delete]
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	escala, err := h.store.Delete(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Escala not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, escala)
+}
+
+// @Summary List minimum coverage
+// @Description Minimum number of employees of each type per shift and weekday (0 = sunday). Shifts below it show up as gaps in the weekly roster.
+// @Tags Escala
+// @Produce json
+// @Success 200 {array} model.CoberturaMinima
+// @Failure 500 {object} types.ErrorResponse
+// @Router /escalas/cobertura [get]
+func (h *Handler) getCobertura(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	minimos, err := h.store.GetCobertura(ctx)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, minimos); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Set minimum coverage
+// @Description Sets the minimum number of employees of a type for a shift on a weekday. A quantidade of zero removes the minimum. Returns the full list.
+// @Tags Escala
+// @Accept json
+// @Produce json
+// @Param cobertura body model.CoberturaMinima true "Cobertura payload"
+// @Success 200 {array} model.CoberturaMinima
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /escalas/cobertura [put]
+func (h *Handler) setCobertura(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	var payload model.CoberturaMinima
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	if err := h.store.SetCobertura(ctx, &payload); err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	minimos, err := h.store.GetCobertura(ctx)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, minimos)
+}
@@ -0,0 +1,151 @@
+package escala
+
+import (
+	"edna/internal/model"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var ErrSemanaInvalida = errors.New("Semana inválida, use YYYY-Www")
+
+var reSemana = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+
+// Segunda-feira da semana ISO, ex.: "2024-W19"
+func ParseSemana(s string) (time.Time, error) {
+	m := reSemana.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, ErrSemanaInvalida
+	}
+	ano, _ := strconv.Atoi(m[1])
+	semana, _ := strconv.Atoi(m[2])
+
+	// A semana 1 é a que contém o dia 4 de janeiro
+	jan4 := time.Date(ano, 1, 4, 0, 0, 0, 0, time.UTC)
+	segunda := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+7*(semana-1))
+	if a, w := segunda.ISOWeek(); semana < 1 || a != ano || w != semana {
+		return time.Time{}, ErrSemanaInvalida
+	}
+	return segunda, nil
+}
+
+// Segunda-feira da semana de uma data
+func InicioSemana(t time.Time) time.Time {
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+}
+
+func FormatSemana(segunda time.Time) string {
+	ano, semana := segunda.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", ano, semana)
+}
+
+// Data e turno da escala de um horário. Das 00:00 às 06:00 é a madrugada
+// do dia anterior.
+func TurnoDe(t time.Time) (string, string) {
+	switch h := t.Hour(); {
+	case h < 6:
+		return t.AddDate(0, 0, -1).Format(time.DateOnly), model.ExpedienteMadrugada
+	case h < 12:
+		return t.Format(time.DateOnly), model.ExpedienteManha
+	case h < 18:
+		return t.Format(time.DateOnly), model.ExpedienteTarde
+	default:
+		return t.Format(time.DateOnly), model.ExpedienteNoite
+	}
+}
+
+// Funcionário escalado em uma data e turno
+type escalado struct {
+	model.FuncionarioEscalado
+	Data       string
+	Expediente string
+}
+
+type vendaRegistrada struct {
+	IDVenda       int64
+	IDFuncionario int64
+	Nome          string
+	DataHora      time.Time
+}
+
+type turno struct {
+	data, expediente string
+}
+
+// Monta a grade da semana com todos os turnos, as lacunas contra os mínimos
+// e as vendas registradas fora da escala do funcionário. Dias sem nenhuma
+// escala planejada não têm vendas conferidas.
+func montarSemana(segunda time.Time, escalados []escalado, minimos []model.CoberturaMinima, vendas []vendaRegistrada) model.SemanaEscala {
+	porTurno := make(map[turno][]model.FuncionarioEscalado)
+	porTipo := make(map[turno]map[string]int64)
+	noTurno := make(map[turno]map[int64]bool)
+	planejado := make(map[string]bool)
+	for _, e := range escalados {
+		t := turno{e.Data, e.Expediente}
+		porTurno[t] = append(porTurno[t], e.FuncionarioEscalado)
+		if porTipo[t] == nil {
+			porTipo[t] = make(map[string]int64)
+			noTurno[t] = make(map[int64]bool)
+		}
+		porTipo[t][e.Tipo]++
+		noTurno[t][e.IDFuncionario] = true
+		planejado[e.Data] = true
+	}
+
+	semana := model.SemanaEscala{
+		Semana:             FormatSemana(segunda),
+		Inicio:             segunda.Format(time.DateOnly),
+		Fim:                segunda.AddDate(0, 0, 6).Format(time.DateOnly),
+		Dias:               make([]model.DiaEscala, 0, 7),
+		Lacunas:            make([]model.LacunaCobertura, 0),
+		VendasForaDeEscala: make([]model.VendaForaDeEscala, 0),
+	}
+	for i := range 7 {
+		dia := segunda.AddDate(0, 0, i)
+		data := dia.Format(time.DateOnly)
+		d := model.DiaEscala{Data: data, DiaSemana: int(dia.Weekday()), Turnos: make([]model.TurnoEscala, 0, len(model.Expedientes))}
+		for _, exp := range model.Expedientes {
+			funcionarios := porTurno[turno{data, exp}]
+			if funcionarios == nil {
+				funcionarios = make([]model.FuncionarioEscalado, 0)
+			}
+			d.Turnos = append(d.Turnos, model.TurnoEscala{Expediente: exp, Funcionarios: funcionarios})
+		}
+		semana.Dias = append(semana.Dias, d)
+
+		for _, m := range minimos {
+			if m.DiaSemana != d.DiaSemana {
+				continue
+			}
+			n := porTipo[turno{data, m.Expediente}][m.Tipo]
+			if n < m.Quantidade {
+				semana.Lacunas = append(semana.Lacunas, model.LacunaCobertura{
+					Data:       data,
+					Expediente: m.Expediente,
+					Tipo:       m.Tipo,
+					Minimo:     m.Quantidade,
+					Escalados:  n,
+				})
+			}
+		}
+	}
+
+	for _, v := range vendas {
+		data, exp := TurnoDe(v.DataHora)
+		if !planejado[data] || noTurno[turno{data, exp}][v.IDFuncionario] {
+			continue
+		}
+		semana.VendasForaDeEscala = append(semana.VendasForaDeEscala, model.VendaForaDeEscala{
+			IDVenda:       v.IDVenda,
+			IDFuncionario: v.IDFuncionario,
+			Nome:          v.Nome,
+			DataHoraVenda: v.DataHora,
+			Data:          data,
+			Expediente:    exp,
+		})
+	}
+	return semana
+}
@@ -0,0 +1,96 @@
+package escala
+
+import (
+	"edna/internal/model"
+	"testing"
+	"time"
+)
+
+func TestParseSemana(t *testing.T) {
+	cases := []struct {
+		semana   string
+		esperado string
+		ok       bool
+	}{
+		{"2024-W19", "2024-05-06", true},
+		{"2021-W01", "2021-01-04", true},
+		{"2020-W53", "2020-12-28", true},
+		{"2021-W53", "", false},
+		{"2024-W00", "", false},
+		{"2024-19", "", false},
+	}
+	for _, c := range cases {
+		segunda, err := ParseSemana(c.semana)
+		if (err == nil) != c.ok || (c.ok && segunda.Format(time.DateOnly) != c.esperado) {
+			t.Fatalf("%s: expected %s (%v), got %s, %v", c.semana, c.esperado, c.ok, segunda.Format(time.DateOnly), err)
+		}
+		if c.ok && FormatSemana(segunda) != c.semana {
+			t.Fatalf("%s: formatted as %s", c.semana, FormatSemana(segunda))
+		}
+	}
+
+	if got := InicioSemana(time.Date(2024, 5, 12, 23, 0, 0, 0, time.UTC)); got.Format(time.DateOnly) != "2024-05-06" {
+		t.Fatalf("expected sunday to belong to the week of 2024-05-06, got %s", got)
+	}
+}
+
+func TestTurnoDe(t *testing.T) {
+	cases := []struct {
+		hora       time.Time
+		data       string
+		expediente string
+	}{
+		{time.Date(2024, 5, 10, 5, 59, 0, 0, time.UTC), "2024-05-09", model.ExpedienteMadrugada},
+		{time.Date(2024, 5, 10, 6, 0, 0, 0, time.UTC), "2024-05-10", model.ExpedienteManha},
+		{time.Date(2024, 5, 10, 12, 30, 0, 0, time.UTC), "2024-05-10", model.ExpedienteTarde},
+		{time.Date(2024, 5, 10, 23, 59, 0, 0, time.UTC), "2024-05-10", model.ExpedienteNoite},
+	}
+	for _, c := range cases {
+		data, exp := TurnoDe(c.hora)
+		if data != c.data || exp != c.expediente {
+			t.Fatalf("%s: expected %s %s, got %s %s", c.hora, c.data, c.expediente, data, exp)
+		}
+	}
+}
+
+func TestMontarSemana(t *testing.T) {
+	segunda := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	escalados := []escalado{
+		{model.FuncionarioEscalado{IDEscala: 1, IDFuncionario: 1, Nome: "Ana", Tipo: "caixa"}, "2024-05-10", model.ExpedienteNoite},
+		{model.FuncionarioEscalado{IDEscala: 2, IDFuncionario: 2, Nome: "Bruno", Tipo: "seguranca"}, "2024-05-11", model.ExpedienteNoite},
+	}
+	minimos := []model.CoberturaMinima{
+		{DiaSemana: 5, Expediente: model.ExpedienteNoite, Tipo: "seguranca", Quantidade: 1},
+		{DiaSemana: 6, Expediente: model.ExpedienteNoite, Tipo: "seguranca", Quantidade: 1},
+	}
+	vendas := []vendaRegistrada{
+		// Na escala: sexta à noite
+		{IDVenda: 10, IDFuncionario: 1, DataHora: time.Date(2024, 5, 10, 21, 0, 0, 0, time.UTC)},
+		// Madrugada de sexta, Ana só estava escalada à noite
+		{IDVenda: 11, IDFuncionario: 1, Nome: "Ana", DataHora: time.Date(2024, 5, 11, 1, 0, 0, 0, time.UTC)},
+		// Terça não tem escala planejada, não é conferida
+		{IDVenda: 12, IDFuncionario: 1, DataHora: time.Date(2024, 5, 7, 15, 0, 0, 0, time.UTC)},
+	}
+
+	semana := montarSemana(segunda, escalados, minimos, vendas)
+	if semana.Semana != "2024-W19" || semana.Inicio != "2024-05-06" || semana.Fim != "2024-05-12" {
+		t.Fatalf("unexpected week bounds: %s %s %s", semana.Semana, semana.Inicio, semana.Fim)
+	}
+	if len(semana.Dias) != 7 || len(semana.Dias[4].Turnos) != 4 || len(semana.Dias[4].Turnos[2].Funcionarios) != 1 {
+		t.Fatalf("unexpected roster: %+v", semana.Dias)
+	}
+
+	if len(semana.Lacunas) != 1 {
+		t.Fatalf("expected one gap, got %+v", semana.Lacunas)
+	}
+	if l := semana.Lacunas[0]; l.Data != "2024-05-10" || l.Tipo != "seguranca" || l.Minimo != 1 || l.Escalados != 0 {
+		t.Fatalf("unexpected gap: %+v", l)
+	}
+
+	if len(semana.VendasForaDeEscala) != 1 {
+		t.Fatalf("expected one flagged sale, got %+v", semana.VendasForaDeEscala)
+	}
+	if v := semana.VendasForaDeEscala[0]; v.IDVenda != 11 || v.Data != "2024-05-10" || v.Expediente != model.ExpedienteMadrugada {
+		t.Fatalf("unexpected flagged sale: %+v", v)
+	}
+}
@@ -0,0 +1,155 @@
+package escala
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/types"
+	"time"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Escala da semana que começa na segunda-feira passada, com as lacunas de
+// cobertura e as vendas registradas fora da escala
+func (s *Store) GetSemana(ctx context.Context, segunda time.Time) (*model.SemanaEscala, error) {
+	inicio := segunda.Format(time.DateOnly)
+
+	query := `
+		SELECT e.id_escala, e.id_funcionario, f.nome, f.tipo::text, e.observacao, e.data::text, e.expediente::text
+		FROM escala e
+		JOIN Funcionario f ON f.id_funcionario = e.id_funcionario
+		WHERE e.data BETWEEN $1::date AND $1::date + 6
+		ORDER BY e.data, e.expediente, f.tipo, f.nome`
+	rows, err := s.db.QueryContext(ctx, query, inicio)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	escalados := make([]escalado, 0)
+	for rows.Next() {
+		var e escalado
+		if err := rows.Scan(&e.IDEscala, &e.IDFuncionario, &e.Nome, &e.Tipo, &e.Observacao, &e.Data, &e.Expediente); err != nil {
+			return nil, err
+		}
+		escalados = append(escalados, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	minimos, err := s.GetCobertura(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A semana de turnos vai da manhã de segunda até a madrugada de domingo
+	query = `
+		SELECT v.id_venda, v.id_funcionario, f.nome, v.data_hora_venda
+		FROM Venda v
+		JOIN Funcionario f ON f.id_funcionario = v.id_funcionario
+		WHERE v.data_hora_venda >= $1::date + interval '6 hours'
+		  AND v.data_hora_venda < $1::date + 7 + interval '6 hours'
+		ORDER BY v.data_hora_venda`
+	rows, err = s.db.QueryContext(ctx, query, inicio)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vendas := make([]vendaRegistrada, 0)
+	for rows.Next() {
+		var v vendaRegistrada
+		if err := rows.Scan(&v.IDVenda, &v.IDFuncionario, &v.Nome, &v.DataHora); err != nil {
+			return nil, err
+		}
+		vendas = append(vendas, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	semana := montarSemana(segunda, escalados, minimos, vendas)
+	return &semana, nil
+}
+
+func (s *Store) GetByID(ctx context.Context, id int64) (*model.Escala, error) {
+	query := "SELECT id_escala, id_funcionario, data::text, expediente::text, observacao FROM escala WHERE id_escala = $1"
+	var e model.Escala
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&e.IDEscala, &e.IDFuncionario, &e.Data, &e.Expediente, &e.Observacao)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Funcionário escalado duas vezes no mesmo turno viola a unicidade (409)
+func (s *Store) Create(ctx context.Context, e *model.Escala) error {
+	query := `
+		INSERT INTO escala (id_funcionario, data, expediente, observacao)
+		VALUES ($1, $2::date, $3::tipo_de_expediente, $4)
+		RETURNING id_escala`
+	return s.db.QueryRowContext(ctx, query, e.IDFuncionario, e.Data, e.Expediente, e.Observacao).Scan(&e.IDEscala)
+}
+
+func (s *Store) Delete(ctx context.Context, id int64) (*model.Escala, error) {
+	query := `
+		DELETE FROM escala WHERE id_escala = $1
+		RETURNING id_escala, id_funcionario, data::text, expediente::text, observacao`
+	var e model.Escala
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&e.IDEscala, &e.IDFuncionario, &e.Data, &e.Expediente, &e.Observacao)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *Store) GetCobertura(ctx context.Context) ([]model.CoberturaMinima, error) {
+	query := `
+		SELECT dia_semana, expediente::text, tipo::text, quantidade
+		FROM cobertura_minima
+		ORDER BY dia_semana, expediente, tipo`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	minimos := make([]model.CoberturaMinima, 0)
+	for rows.Next() {
+		var m model.CoberturaMinima
+		if err := rows.Scan(&m.DiaSemana, &m.Expediente, &m.Tipo, &m.Quantidade); err != nil {
+			return nil, err
+		}
+		minimos = append(minimos, m)
+	}
+	return minimos, rows.Err()
+}
+
+// Define o mínimo de um tipo em um turno. Quantidade zero remove o mínimo.
+func (s *Store) SetCobertura(ctx context.Context, m *model.CoberturaMinima) error {
+	if m.Quantidade == 0 {
+		query := "DELETE FROM cobertura_minima WHERE dia_semana = $1 AND expediente = $2::tipo_de_expediente AND tipo = $3::tipo_de_funcionario"
+		_, err := s.db.ExecContext(ctx, query, m.DiaSemana, m.Expediente, m.Tipo)
+		return err
+	}
+	query := `
+		INSERT INTO cobertura_minima (dia_semana, expediente, tipo, quantidade)
+		VALUES ($1, $2::tipo_de_expediente, $3::tipo_de_funcionario, $4)
+		ON CONFLICT (dia_semana, expediente, tipo) DO UPDATE SET quantidade = EXCLUDED.quantidade`
+	_, err := s.db.ExecContext(ctx, query, m.DiaSemana, m.Expediente, m.Tipo, m.Quantidade)
+	return err
+}
@@ -0,0 +1,100 @@
+package estoque
+
+import (
+	"edna/internal/util"
+	"net/url"
+)
+
+func NewEstoqueProdutoFilter(params url.Values) (util.Filter, error) {
+	var filter util.Filter
+	if err := filter.GetOffset(params); err != nil {
+		return filter, err
+	}
+
+	if err := filter.GetLimit(params); err != nil {
+		return filter, err
+	}
+
+	attrs := []string{"id_produto", "nome", "categoria", "marca", "disponivel", "vencido", "lotes"}
+
+	if err := filter.GetSorts(params, attrs); err != nil {
+		return filter, err
+	}
+
+	for _, attr := range []string{"nome", "categoria", "marca"} {
+		if err := filter.GetFilterStr(params, attr); err != nil {
+			return filter, err
+		}
+	}
+
+	for _, attr := range []string{"id_produto", "disponivel", "vencido", "lotes"} {
+		if err := filter.GetFilterInt(params, attr); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+func NewEstoqueLoteFilter(params url.Values) (util.Filter, error) {
+	var filter util.Filter
+	if err := filter.GetOffset(params); err != nil {
+		return filter, err
+	}
+
+	if err := filter.GetLimit(params); err != nil {
+		return filter, err
+	}
+
+	attrs := []string{"id_lote", "id_produto", "id_fornecedor", "data_fornecimento", "validade", "vendidos", "disponivel"}
+
+	if err := filter.GetSorts(params, attrs); err != nil {
+		return filter, err
+	}
+
+	for _, attr := range []string{"id_lote", "id_produto", "id_fornecedor", "vendidos", "disponivel"} {
+		if err := filter.GetFilterInt(params, attr); err != nil {
+			return filter, err
+		}
+	}
+
+	for _, attr := range []string{"data_fornecimento", "validade"} {
+		if err := filter.GetFilterTime(params, attr); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+func NewMovimentoFilter(params url.Values) (util.Filter, error) {
+	var filter util.Filter
+	if err := filter.GetOffset(params); err != nil {
+		return filter, err
+	}
+
+	if err := filter.GetLimit(params); err != nil {
+		return filter, err
+	}
+
+	attrs := []string{"data_hora", "id_lote", "id_produto", "tipo", "quantidade"}
+
+	if err := filter.GetSorts(params, attrs); err != nil {
+		return filter, err
+	}
+
+	for _, attr := range []string{"tipo", "motivo"} {
+		if err := filter.GetFilterStr(params, attr); err != nil {
+			return filter, err
+		}
+	}
+
+	for _, attr := range []string{"id_lote", "id_produto", "id_venda"} {
+		if err := filter.GetFilterInt(params, attr); err != nil {
+			return filter, err
+		}
+	}
+
+	if err := filter.GetFilterTime(params, "data_hora"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
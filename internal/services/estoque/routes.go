@@ -0,0 +1,165 @@
+package estoque
+
+import (
+	"context"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"net/http"
+)
+
+type Handler struct {
+	store EstoqueStore
+}
+
+type EstoqueStore interface {
+	GetProdutos(ctx context.Context, filter util.Filter) ([]model.EstoqueProduto, error)
+	GetProduto(ctx context.Context, idProduto int64) (*model.EstoqueProduto, error)
+	GetLotes(ctx context.Context, filter util.Filter) ([]model.EstoqueLote, error)
+	GetMovimentos(ctx context.Context, filter util.Filter) ([]model.MovimentoEstoque, error)
+}
+
+func NewHandler(store EstoqueStore) *Handler {
+	return &Handler{store}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /estoque", h.getProdutos)
+	mux.HandleFunc("GET /estoque/lotes", h.getLotes)
+	mux.HandleFunc("GET /estoque/movimentos", h.getMovimentos)
+	mux.HandleFunc("GET /estoque/{id}", h.fetchProduto)
+}
+
+// @Summary List stock per Produto
+// @Description Available quantity per product. Units in expired lots are reported apart and are not available.
+// @Tags Estoque
+// @Produce json
+// @Param filter-nome query string false "Filter by nome using operators: like, ilike, eq, ne"
+// @Param filter-categoria query string false "Filter by categoria using operators: like, ilike, eq, ne"
+// @Param filter-disponivel query int false "Filter by disponivel using operators: eq, ne, gt, lt, ge, le"
+// @Param sort query string false "Sort fields: id_produto, nome, categoria, marca, disponivel, vencido, lotes. Prefix with '-' for desc."
+// @Param offset query int false "Pagination offset (default 0)"
+// @Param limit query int false "Pagination limit (default 10)"
+// @Success 200 {array} model.EstoqueProduto
+// @Failure 500 {object} types.ErrorResponse
+// @Router /estoque [get]
+func (h *Handler) getProdutos(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	filters, err := NewEstoqueProdutoFilter(r.URL.Query())
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	produtos, err := h.store.GetProdutos(ctx, filters)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := util.WriteJSON(w, http.StatusOK, produtos); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Get stock of a Produto
+// @Tags Estoque
+// @Produce json
+// @Param id path int true "Produto ID"
+// @Success 200 {object} model.EstoqueProduto
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /estoque/{id} [get]
+func (h *Handler) fetchProduto(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	produto, err := h.store.GetProduto(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Produto not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, produto); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary List stock per Lote
+// @Tags Estoque
+// @Produce json
+// @Param filter-id_produto query int false "Filter by id_produto using operators: eq, ne, gt, lt, ge, le"
+// @Param filter-id_fornecedor query int false "Filter by id_fornecedor using operators: eq, ne, gt, lt, ge, le"
+// @Param filter-disponivel query int false "Filter by disponivel using operators: eq, ne, gt, lt, ge, le"
+// @Param filter-validade query string false "Filter by validade using operators: eq, ne, gt, lt, ge, le. Format: operator.YYYY-MM-DD HH:MM:SS"
+// @Param sort query string false "Sort fields: id_lote, id_produto, id_fornecedor, data_fornecimento, validade, vendidos, disponivel. Prefix with '-' for desc."
+// @Param offset query int false "Pagination offset (default 0)"
+// @Param limit query int false "Pagination limit (default 10)"
+// @Success 200 {array} model.EstoqueLote
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /estoque/lotes [get]
+func (h *Handler) getLotes(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	filters, err := NewEstoqueLoteFilter(r.URL.Query())
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lotes, err := h.store.GetLotes(ctx, filters)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := util.WriteJSON(w, http.StatusOK, lotes); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Stock movement history
+// @Description Entries (entrada), sales (venda), losses (perda) and inventory corrections (ajuste). Outgoing movements have negative quantities.
+// @Tags Estoque
+// @Produce json
+// @Param filter-id_produto query int false "Filter by id_produto using operators: eq, ne, gt, lt, ge, le"
+// @Param filter-id_lote query int false "Filter by id_lote using operators: eq, ne, gt, lt, ge, le"
+// @Param filter-tipo query string false "Filter by tipo using operators: eq, ne, like, ilike"
+// @Param filter-motivo query string false "Filter by motivo using operators: eq, ne, like, ilike"
+// @Param filter-data_hora query string false "Filter by data_hora using operators: eq, ne, gt, lt, ge, le. Format: operator.YYYY-MM-DD HH:MM:SS"
+// @Param sort query string false "Sort fields: data_hora, id_lote, id_produto, tipo, quantidade. Prefix with '-' for desc. Default -data_hora"
+// @Param offset query int false "Pagination offset (default 0)"
+// @Param limit query int false "Pagination limit (default 10)"
+// @Success 200 {array} model.MovimentoEstoque
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /estoque/movimentos [get]
+func (h *Handler) getMovimentos(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	filters, err := NewMovimentoFilter(r.URL.Query())
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	movimentos, err := h.store.GetMovimentos(ctx, filters)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := util.WriteJSON(w, http.StatusOK, movimentos); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,157 @@
+package estoque
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"fmt"
+)
+
+// *sql.DB e *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db}
+}
+
+// Quantidade disponível de um lote.
+func DisponivelLote(ctx context.Context, q querier, idLote int64) (int64, error) {
+	var disponivel int64
+	err := q.QueryRowContext(ctx, "SELECT disponivel FROM estoque_lote WHERE id_lote = $1", idLote).Scan(&disponivel)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, types.ErrNotFound
+		}
+		return 0, err
+	}
+	return disponivel, nil
+}
+
+// Bloqueia o lote e garante que ele não ficou com estoque negativo.
+// Usada depois de inserir ou alterar item_venda na mesma transação.
+func ConferirLote(ctx context.Context, tx *sql.Tx, idLote int64) error {
+	if _, err := tx.ExecContext(ctx, "SELECT id_lote FROM Lote WHERE id_lote = $1 FOR UPDATE", idLote); err != nil {
+		return err
+	}
+	disponivel, err := DisponivelLote(ctx, tx, idLote)
+	if err != nil {
+		return err
+	}
+	if disponivel < 0 {
+		return fmt.Errorf("%w: lote %d, faltam %d unidades", types.ErrEstoqueInsuficiente, idLote, -disponivel)
+	}
+	return nil
+}
+
+// Lotes válidos do produto com estoque, do que vence primeiro ao último (FIFO).
+// Os lotes do produto ficam bloqueados até o fim da transação.
+func LotesDisponiveis(ctx context.Context, tx *sql.Tx, idProduto int64) ([]model.EstoqueLote, error) {
+	// Bloqueia primeiro, a consulta seguinte enxerga as vendas já confirmadas
+	// por quem segurava os lotes antes.
+	lockQuery := `
+		SELECT id_lote FROM Lote
+		WHERE id_produto = $1 AND (validade IS NULL OR validade > CURRENT_DATE)
+		FOR UPDATE;`
+	if _, err := tx.ExecContext(ctx, lockQuery, idProduto); err != nil {
+		return nil, err
+	}
+
+	query := selectLote + `
+		WHERE id_produto = $1 AND NOT vencido AND disponivel > 0
+		ORDER BY validade ASC, id_lote ASC;`
+	return scanLotes(tx.QueryContext(ctx, query, idProduto))
+}
+
+const selectLote = `
+	SELECT id_lote, id_produto, id_fornecedor, data_fornecimento, validade,
+		quantidade_inicial, estragados, ajustes, vendidos, disponivel, vencido
+	FROM estoque_lote`
+
+func scanLotes(rows *sql.Rows, err error) ([]model.EstoqueLote, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lotes := make([]model.EstoqueLote, 0)
+	for rows.Next() {
+		var l model.EstoqueLote
+		err := rows.Scan(&l.IDLote, &l.IDProduto, &l.IDFornecedor, &l.DataFornecimento, &l.Validade,
+			&l.QuantidadeInicial, &l.Estragados, &l.Ajustes, &l.Vendidos, &l.Disponivel, &l.Vencido)
+		if err != nil {
+			return nil, err
+		}
+		lotes = append(lotes, l)
+	}
+	return lotes, rows.Err()
+}
+
+func (s *Store) GetProdutos(ctx context.Context, filter util.Filter) ([]model.EstoqueProduto, error) {
+	query := "SELECT id_produto, nome, categoria, marca, disponivel, vencido, lotes FROM estoque_produto AS ep"
+	rows, err := util.QueryRowsWithFilter(s.db, ctx, query, &filter, "ep")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	produtos := make([]model.EstoqueProduto, 0)
+	for rows.Next() {
+		var p model.EstoqueProduto
+		if err := rows.Scan(&p.IDProduto, &p.Nome, &p.Categoria, &p.Marca, &p.Disponivel, &p.Vencido, &p.Lotes); err != nil {
+			return nil, err
+		}
+		produtos = append(produtos, p)
+	}
+	return produtos, rows.Err()
+}
+
+func (s *Store) GetProduto(ctx context.Context, idProduto int64) (*model.EstoqueProduto, error) {
+	query := "SELECT id_produto, nome, categoria, marca, disponivel, vencido, lotes FROM estoque_produto WHERE id_produto = $1"
+	var p model.EstoqueProduto
+	err := s.db.QueryRowContext(ctx, query, idProduto).
+		Scan(&p.IDProduto, &p.Nome, &p.Categoria, &p.Marca, &p.Disponivel, &p.Vencido, &p.Lotes)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *Store) GetLotes(ctx context.Context, filter util.Filter) ([]model.EstoqueLote, error) {
+	var values []any
+	query := selectLote + " AS el" + filter.ToQuery(&values, "el")
+	return scanLotes(s.db.QueryContext(ctx, query, values...))
+}
+
+func (s *Store) GetMovimentos(ctx context.Context, filter util.Filter) ([]model.MovimentoEstoque, error) {
+	if len(filter.Sorts) == 0 {
+		filter.Sorts = []string{"-data_hora"}
+	}
+	query := "SELECT id_lote, id_produto, tipo, data_hora, quantidade, id_venda, motivo FROM estoque_movimento AS em"
+	rows, err := util.QueryRowsWithFilter(s.db, ctx, query, &filter, "em")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movimentos := make([]model.MovimentoEstoque, 0)
+	for rows.Next() {
+		var m model.MovimentoEstoque
+		if err := rows.Scan(&m.IDLote, &m.IDProduto, &m.Tipo, &m.DataHora, &m.Quantidade, &m.IDVenda, &m.Motivo); err != nil {
+			return nil, err
+		}
+		movimentos = append(movimentos, m)
+	}
+	return movimentos, rows.Err()
+}
@@ -0,0 +1,116 @@
+package fidelidade
+
+import (
+	"edna/internal/model"
+	"math"
+	"sort"
+	"time"
+)
+
+// Item da venda com o valor líquido (já com as ofertas) e a taxa da regra que vale para ele
+type ItemPontuavel struct {
+	Liquido       float64
+	PontosPorReal float64
+}
+
+// Pontos ganhos numa venda. O desconto pago com pontos não gera pontos, então
+// a pontuação é reduzida na proporção do desconto. Frações de ponto são descartadas.
+func CalcularPontos(itens []ItemPontuavel, descontoPontos float64) int64 {
+	var liquido, pontos float64
+	for _, it := range itens {
+		if it.Liquido <= 0 {
+			continue
+		}
+		liquido += it.Liquido
+		pontos += it.Liquido * it.PontosPorReal
+	}
+	if liquido <= 0 {
+		return 0
+	}
+	fator := max(liquido-descontoPontos, 0) / liquido
+	// Arredonda antes do piso para 1.9999999 contar como 2
+	return int64(math.Floor(math.Round(pontos*fator*1e6) / 1e6))
+}
+
+type credito struct {
+	validade time.Time
+	resta    int64
+}
+
+// Fim do último dia de validade
+func expiraEm(validade time.Time) time.Time {
+	return time.Date(validade.Year(), validade.Month(), validade.Day()+1, 0, 0, 0, 0, validade.Location())
+}
+
+// Apura o saldo a partir do extrato em ordem cronológica. Débitos consomem os
+// créditos que vencem primeiro e o que sobra de um crédito depois da validade
+// vira uma expiração no histórico. Um débito maior que os créditos (estorno de
+// pontos já usados) deixa o saldo negativo, coberto pelos próximos créditos.
+func Apurar(movs []model.MovimentoPontos, agora time.Time) model.PontosCliente {
+	res := model.PontosCliente{Historico: make([]model.MovimentoPontos, 0, len(movs))}
+	creditos := make([]credito, 0)
+	var deficit int64
+
+	expirar := func(ate time.Time, idCliente int64) {
+		for len(creditos) > 0 && !expiraEm(creditos[0].validade).After(ate) {
+			c := creditos[0]
+			creditos = creditos[1:]
+			if c.resta == 0 {
+				continue
+			}
+			validade := c.validade
+			res.Historico = append(res.Historico, model.MovimentoPontos{
+				IDCliente: idCliente,
+				Tipo:      model.PontosExpiracao,
+				Pontos:    -c.resta,
+				DataHora:  expiraEm(c.validade),
+				Validade:  &validade,
+			})
+		}
+	}
+
+	for _, m := range movs {
+		expirar(m.DataHora, m.IDCliente)
+		res.IDCliente = m.IDCliente
+		res.Historico = append(res.Historico, m)
+
+		if m.Pontos > 0 {
+			cobre := min(deficit, m.Pontos)
+			deficit -= cobre
+			c := credito{resta: m.Pontos - cobre, validade: time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)}
+			if m.Validade != nil {
+				c.validade = *m.Validade
+			}
+			i := sort.Search(len(creditos), func(i int) bool { return creditos[i].validade.After(c.validade) })
+			creditos = append(creditos[:i], append([]credito{c}, creditos[i:]...)...)
+			continue
+		}
+		debito := -m.Pontos
+		for i := range creditos {
+			if debito == 0 {
+				break
+			}
+			usa := min(debito, creditos[i].resta)
+			creditos[i].resta -= usa
+			debito -= usa
+		}
+		deficit += debito
+	}
+	expirar(agora, res.IDCliente)
+	res.Saldo = -deficit
+
+	for _, c := range creditos {
+		if c.resta == 0 {
+			continue
+		}
+		res.Saldo += c.resta
+		if res.ProximaExpiracao == nil {
+			validade := c.validade
+			res.ProximaExpiracao = &validade
+		}
+		if c.validade.Equal(*res.ProximaExpiracao) {
+			res.PontosAExpirar += c.resta
+		}
+	}
+	return res
+}
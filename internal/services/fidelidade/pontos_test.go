@@ -0,0 +1,77 @@
+package fidelidade
+
+import (
+	"edna/internal/model"
+	"testing"
+	"time"
+)
+
+func TestCalcularPontos(t *testing.T) {
+	itens := []ItemPontuavel{
+		{Liquido: 25.00, PontosPorReal: 1},
+		{Liquido: 12.50, PontosPorReal: 2},
+		{Liquido: 10.00, PontosPorReal: 0},
+	}
+	if got := CalcularPontos(itens, 0); got != 50 {
+		t.Fatalf("expected 50 points, got %d", got)
+	}
+	// 47.50 líquido, 9.50 pagos com pontos: 80% dos pontos
+	if got := CalcularPontos(itens, 9.50); got != 40 {
+		t.Fatalf("expected 40 points with redeemed discount, got %d", got)
+	}
+	if got := CalcularPontos(nil, 0); got != 0 {
+		t.Fatalf("expected no points for empty sale, got %d", got)
+	}
+}
+
+func TestApurar(t *testing.T) {
+	dia := func(d int) time.Time { return time.Date(2025, 1, d, 12, 0, 0, 0, time.UTC) }
+	validade := func(d int) *time.Time {
+		v := time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC)
+		return &v
+	}
+	movs := []model.MovimentoPontos{
+		{IDCliente: 1, Tipo: model.PontosAcumulo, Pontos: 100, DataHora: dia(1), Validade: validade(10)},
+		{IDCliente: 1, Tipo: model.PontosAcumulo, Pontos: 50, DataHora: dia(2), Validade: validade(20)},
+		{IDCliente: 1, Tipo: model.PontosResgate, Pontos: -30, DataHora: dia(5)},
+		// 70 pontos do primeiro crédito expiram no fim do dia 10
+		{IDCliente: 1, Tipo: model.PontosResgate, Pontos: -20, DataHora: dia(12)},
+	}
+
+	res := Apurar(movs, dia(15))
+	if res.Saldo != 30 {
+		t.Fatalf("expected balance 30, got %d", res.Saldo)
+	}
+	if len(res.Historico) != 5 || res.Historico[3].Tipo != model.PontosExpiracao || res.Historico[3].Pontos != -70 {
+		t.Fatalf("expected an expiration of 70 points before the second redemption, got %+v", res.Historico)
+	}
+	if res.ProximaExpiracao == nil || !res.ProximaExpiracao.Equal(*validade(20)) || res.PontosAExpirar != 30 {
+		t.Fatalf("unexpected next expiration: %v %d", res.ProximaExpiracao, res.PontosAExpirar)
+	}
+
+	if res := Apurar(movs, dia(21)); res.Saldo != 0 || res.ProximaExpiracao != nil {
+		t.Fatalf("expected everything expired, got %+v", res)
+	}
+}
+
+func TestApurarEstornoDePontosUsados(t *testing.T) {
+	dia := func(d int) time.Time { return time.Date(2025, 1, d, 12, 0, 0, 0, time.UTC) }
+	validade := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	movs := []model.MovimentoPontos{
+		{IDCliente: 1, Tipo: model.PontosAcumulo, Pontos: 100, DataHora: dia(1), Validade: &validade},
+		{IDCliente: 1, Tipo: model.PontosResgate, Pontos: -80, DataHora: dia(2)},
+		// Pagamento da primeira venda removido depois do resgate
+		{IDCliente: 1, Tipo: model.PontosEstorno, Pontos: -100, DataHora: dia(3)},
+	}
+
+	res := Apurar(movs, dia(4))
+	if res.Saldo != -80 || res.ProximaExpiracao != nil {
+		t.Fatalf("expected a deficit of 80 points, got %+v", res)
+	}
+
+	// O próximo crédito cobre o déficit antes de virar saldo
+	movs = append(movs, model.MovimentoPontos{IDCliente: 1, Tipo: model.PontosAcumulo, Pontos: 100, DataHora: dia(5), Validade: &validade})
+	if res := Apurar(movs, dia(6)); res.Saldo != 20 || res.PontosAExpirar != 20 {
+		t.Fatalf("expected 20 points left after covering the deficit, got %+v", res)
+	}
+}
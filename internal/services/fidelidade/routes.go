@@ -0,0 +1,390 @@
+package fidelidade
+
+import (
+	"context"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type Handler struct {
+	store FidelidadeStore
+}
+
+type FidelidadeStore interface {
+	GetPontos(ctx context.Context, idCliente int64) (*model.PontosCliente, error)
+	Ajustar(ctx context.Context, idCliente int64, a model.AjustePontosCreate) (*model.MovimentoPontos, error)
+	Resgatar(ctx context.Context, idVenda, pontos int64) (*model.AplicaPontos, error)
+	CancelarResgate(ctx context.Context, idVenda int64) (*model.AplicaPontos, error)
+	GetRegras(ctx context.Context) ([]model.RegraFidelidade, error)
+	CreateRegra(ctx context.Context, r *model.RegraFidelidade) error
+	UpdateRegra(ctx context.Context, r *model.RegraFidelidade) error
+	DeleteRegra(ctx context.Context, id int64) (*model.RegraFidelidade, error)
+	GetConfig(ctx context.Context) (*model.ConfigFidelidade, error)
+	UpdateConfig(ctx context.Context, c *model.ConfigFidelidade) error
+}
+
+func NewHandler(store FidelidadeStore) *Handler {
+	return &Handler{store}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /clientes/{id}/pontos", h.fetchPontos)
+	mux.HandleFunc("POST /clientes/{id}/pontos/ajustes", h.ajustar)
+	mux.HandleFunc("POST /vendas/{id}/pontos", h.resgatar)
+	mux.HandleFunc("DELETE /vendas/{id}/pontos", h.cancelarResgate)
+	mux.HandleFunc("GET /fidelidade/regras", h.getRegras)
+	mux.HandleFunc("POST /fidelidade/regras", h.createRegra)
+	mux.HandleFunc("PUT /fidelidade/regras/{id}", h.updateRegra)
+	mux.HandleFunc("DELETE /fidelidade/regras/{id}", h.deleteRegra)
+	mux.HandleFunc("GET /fidelidade/config", h.fetchConfig)
+	mux.HandleFunc("PUT /fidelidade/config", h.updateConfig)
+}
+
+// @Summary Get client loyalty points
+// @Description Current balance, its value as discount, the next expiration and the full history including computed expirations. Points are consumed oldest expiry first.
+// @Tags Fidelidade
+// @Produce json
+// @Param id path int true "Cliente ID"
+// @Success 200 {object} model.PontosCliente
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /clientes/{id}/pontos [get]
+func (h *Handler) fetchPontos(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pontos, err := h.store.GetPontos(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Cliente not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, pontos); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Adjust client loyalty points
+// @Description Manual credit (positive) or debit (negative) with a reason, made by a gerente. Debits cannot exceed the balance; credits expire like earned points.
+// @Tags Fidelidade
+// @Accept json
+// @Produce json
+// @Param id path int true "Cliente ID"
+// @Param ajuste body model.AjustePontosCreate true "Ajuste payload"
+// @Success 201 {object} model.MovimentoPontos
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /clientes/{id}/pontos/ajustes [post]
+func (h *Handler) ajustar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.AjustePontosCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	movimento, err := h.store.Ajustar(ctx, id, payload)
+	if err != nil {
+		writeFidelidadeError(w, err, "Cliente not found.")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, movimento)
+}
+
+// @Summary Redeem points on a sale
+// @Description Redeems points of the sale's client as a discount on an open sale. If the discount covers what is left, the sale is settled and earns points on the remaining net value.
+// @Tags Fidelidade
+// @Accept json
+// @Produce json
+// @Param id path int true "Venda ID"
+// @Param resgate body model.ResgatePontosCreate true "Resgate payload"
+// @Success 201 {object} model.AplicaPontos
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /vendas/{id}/pontos [post]
+func (h *Handler) resgatar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.ResgatePontosCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	resgate, err := h.store.Resgatar(ctx, id, payload.Pontos)
+	if err != nil {
+		writeFidelidadeError(w, err, "Venda not found.")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, resgate)
+}
+
+// @Summary Cancel points redemption
+// @Description Removes the points discount from an open sale and gives the points back to the client.
+// @Tags Fidelidade
+// @Produce json
+// @Param id path int true "Venda ID"
+// @Success 200 {object} model.AplicaPontos
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /vendas/{id}/pontos [delete]
+func (h *Handler) cancelarResgate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resgate, err := h.store.CancelarResgate(ctx, id)
+	if err != nil {
+		writeFidelidadeError(w, err, "Resgate not found.")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, resgate)
+}
+
+// @Summary List earning rules
+// @Description Points per real of net value. A product rule wins over a category rule, which wins over the default rule (no product and no category).
+// @Tags Fidelidade
+// @Produce json
+// @Success 200 {array} model.RegraFidelidade
+// @Failure 500 {object} types.ErrorResponse
+// @Router /fidelidade/regras [get]
+func (h *Handler) getRegras(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	regras, err := h.store.GetRegras(ctx)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := util.WriteJSON(w, http.StatusOK, regras); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Create earning rule
+// @Tags Fidelidade
+// @Accept json
+// @Produce json
+// @Param regra body model.RegraFidelidadeCreate true "Regra payload"
+// @Success 201 {object} model.RegraFidelidade
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /fidelidade/regras [post]
+func (h *Handler) createRegra(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	var payload model.RegraFidelidadeCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	regra := payload.ToRegraFidelidade()
+	if err := h.store.CreateRegra(ctx, &regra); err != nil {
+		writeFidelidadeError(w, err, "Regra not found.")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, regra)
+}
+
+// @Summary Update earning rule
+// @Tags Fidelidade
+// @Accept json
+// @Produce json
+// @Param id path int true "Regra ID"
+// @Param regra body model.RegraFidelidadeCreate true "Regra payload"
+// @Success 200 {object} model.RegraFidelidade
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /fidelidade/regras/{id} [put]
+func (h *Handler) updateRegra(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.RegraFidelidadeCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	regra := payload.ToRegraFidelidade()
+	regra.IDRegra = id
+	if err := h.store.UpdateRegra(ctx, &regra); err != nil {
+		writeFidelidadeError(w, err, "Regra not found.")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, regra)
+}
+
+// @Summary Delete earning rule
+// @Tags Fidelidade
+// @Produce json
+// @Param id path int true "Regra ID"
+// @Success 200 {object} model.RegraFidelidade
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /fidelidade/regras/{id} [delete]
+func (h *Handler) deleteRegra(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	regra, err := h.store.DeleteRegra(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Regra not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, regra)
+}
+
+// @Summary Get loyalty settings
+// @Tags Fidelidade
+// @Produce json
+// @Success 200 {object} model.ConfigFidelidade
+// @Failure 500 {object} types.ErrorResponse
+// @Router /fidelidade/config [get]
+func (h *Handler) fetchConfig(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	config, err := h.store.GetConfig(ctx)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := util.WriteJSON(w, http.StatusOK, config); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Update loyalty settings
+// @Description Sets how many days earned points last and how much each point is worth when redeemed. Already earned points keep their expiry date.
+// @Tags Fidelidade
+// @Accept json
+// @Produce json
+// @Param config body model.ConfigFidelidade true "Config payload"
+// @Success 200 {object} model.ConfigFidelidade
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /fidelidade/config [put]
+func (h *Handler) updateConfig(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	var config model.ConfigFidelidade
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.UpdateConfig(ctx, &config); err != nil {
+		writeFidelidadeError(w, err, "")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, config)
+}
+
+func writeFidelidadeError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		util.ErrorJSON(w, notFound, http.StatusNotFound)
+	case errors.Is(err, ErrSaldoInsuficiente), errors.Is(err, ErrVendaFechada),
+		errors.Is(err, ErrResgateExistente), errors.Is(err, ErrResgateExcedeVenda):
+		util.ErrorJSON(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrPontosInvalidos), errors.Is(err, ErrAjusteSemMotivo),
+		errors.Is(err, ErrRegraInvalida), errors.Is(err, ErrConfigInvalida):
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrFuncionarioGerente):
+		util.ErrorJSON(w, err.Error(), http.StatusForbidden)
+	default:
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+	}
+}
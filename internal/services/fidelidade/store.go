@@ -0,0 +1,495 @@
+package fidelidade
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/services/totais"
+	"edna/internal/types"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrRegraInvalida      = errors.New("Regra precisa de pontos_por_real não negativo e no máximo um entre id_produto e categoria")
+	ErrConfigInvalida     = errors.New("dias_validade e valor_ponto devem ser positivos")
+	ErrPontosInvalidos    = errors.New("Quantidade de pontos inválida")
+	ErrSaldoInsuficiente  = errors.New("Saldo de pontos insuficiente")
+	ErrVendaFechada       = errors.New("Pontos só podem ser resgatados em vendas em aberto")
+	ErrResgateExistente   = errors.New("Venda já tem pontos resgatados")
+	ErrResgateExcedeVenda = errors.New("Desconto dos pontos excede o restante da venda")
+	ErrAjusteSemMotivo    = errors.New("Ajuste de pontos precisa de motivo")
+	ErrFuncionarioGerente = errors.New("Ajuste de pontos exige um funcionário do tipo gerente")
+)
+
+// *sql.DB e *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func getConfig(ctx context.Context, q querier) (model.ConfigFidelidade, error) {
+	var c model.ConfigFidelidade
+	err := q.QueryRowContext(ctx, "SELECT dias_validade, valor_ponto FROM config_fidelidade").Scan(&c.DiasValidade, &c.ValorPonto)
+	return c, err
+}
+
+// Extrato gravado do cliente em ordem cronológica
+func movimentos(ctx context.Context, q querier, idCliente int64) ([]model.MovimentoPontos, error) {
+	query := `
+		SELECT id_movimento, id_cliente, id_venda, tipo::text, pontos, data_hora, validade, motivo, id_funcionario
+		FROM movimento_pontos
+		WHERE id_cliente = $1
+		ORDER BY data_hora, id_movimento`
+	rows, err := q.QueryContext(ctx, query, idCliente)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movs := make([]model.MovimentoPontos, 0)
+	for rows.Next() {
+		var m model.MovimentoPontos
+		if err := rows.Scan(&m.IDMovimento, &m.IDCliente, &m.IDVenda, &m.Tipo, &m.Pontos, &m.DataHora, &m.Validade, &m.Motivo, &m.IDFuncionario); err != nil {
+			return nil, err
+		}
+		movs = append(movs, m)
+	}
+	return movs, rows.Err()
+}
+
+// Bloqueia o cliente para serializar movimentos de pontos e retorna o saldo atual
+func travarSaldo(ctx context.Context, tx *sql.Tx, idCliente int64) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, "SELECT id_cliente FROM Cliente WHERE id_cliente = $1 FOR UPDATE", idCliente).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, types.ErrNotFound
+		}
+		return 0, err
+	}
+	movs, err := movimentos(ctx, tx, idCliente)
+	if err != nil {
+		return 0, err
+	}
+	return Apurar(movs, time.Now()).Saldo, nil
+}
+
+// Credita os pontos de uma venda quitada ao cliente, com a validade configurada.
+// Chamada na mesma transação que quita a venda, não pontua a mesma venda duas
+// vezes. Uma venda estornada e quitada de novo pontua outra vez.
+func Acumular(ctx context.Context, tx *sql.Tx, idVenda int64) error {
+	var idCliente int64
+	var dataPagamento *time.Time
+	err := tx.QueryRowContext(ctx, "SELECT id_cliente, data_hora_pagamento FROM Venda WHERE id_venda = $1", idVenda).
+		Scan(&idCliente, &dataPagamento)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.ErrNotFound
+		}
+		return err
+	}
+	if dataPagamento == nil {
+		return nil
+	}
+	pontuados, err := pontosVenda(ctx, tx, idVenda)
+	if err != nil || pontuados > 0 {
+		return err
+	}
+
+	query := `
+		SELECT (iv.quantidade * iv.valor_unitario - COALESCE(ao.desconto, 0))::float8,
+			COALESCE(rp.pontos_por_real, rc.pontos_por_real, rd.pontos_por_real, 0)::float8
+		FROM item_venda iv
+		JOIN Produto p ON p.id_produto = iv.id_produto
+		LEFT JOIN (
+			SELECT id_item_venda, SUM(desconto) AS desconto
+			FROM aplica_oferta
+			GROUP BY id_item_venda
+		) ao ON ao.id_item_venda = iv.id_item_venda
+		LEFT JOIN regra_fidelidade rp ON rp.id_produto = iv.id_produto
+		LEFT JOIN regra_fidelidade rc ON rc.id_produto IS NULL AND rc.categoria = p.categoria
+		LEFT JOIN regra_fidelidade rd ON rd.id_produto IS NULL AND rd.categoria IS NULL
+		WHERE iv.id_venda = $1`
+	rows, err := tx.QueryContext(ctx, query, idVenda)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	itens := make([]ItemPontuavel, 0)
+	for rows.Next() {
+		var it ItemPontuavel
+		if err := rows.Scan(&it.Liquido, &it.PontosPorReal); err != nil {
+			return err
+		}
+		itens = append(itens, it)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	var descontoPontos float64
+	err = tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(desconto), 0)::float8 FROM aplica_pontos WHERE id_venda = $1", idVenda).Scan(&descontoPontos)
+	if err != nil {
+		return err
+	}
+	pontos := CalcularPontos(itens, descontoPontos)
+	if pontos <= 0 {
+		return nil
+	}
+
+	config, err := getConfig(ctx, tx)
+	if err != nil {
+		return err
+	}
+	query = `
+		INSERT INTO movimento_pontos (id_cliente, id_venda, tipo, pontos, validade, data_hora)
+		VALUES ($1, $2, 'acumulo', $3, $4::date + $5::int, $4)`
+	_, err = tx.ExecContext(ctx, query, idCliente, idVenda, pontos, *dataPagamento, config.DiasValidade)
+	return err
+}
+
+// Quita a venda quando pagamentos e descontos já cobrem o total, creditando os
+// pontos. Único caminho que marca a venda como paga, chamado na transação que
+// registrou o pagamento ou o resgate. Retorna se a venda foi quitada agora.
+func Quitar(ctx context.Context, tx *sql.Tx, idVenda int64, dataHora time.Time) (bool, error) {
+	t, err := totais.Venda(ctx, tx, idVenda)
+	if err != nil {
+		return false, err
+	}
+	if t.StatusPagamento == model.StatusPagamentoPago || math.Round(t.Restante*100) > 0 {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE Venda SET data_hora_pagamento = $2 WHERE id_venda = $1", idVenda, dataHora); err != nil {
+		return false, err
+	}
+	return true, Acumular(ctx, tx, idVenda)
+}
+
+// Retira os pontos de uma venda que voltou a ficar em aberto com um débito de
+// estorno. O acúmulo fica no extrato: se os pontos já foram resgatados em outra
+// venda, o saldo do cliente fica negativo em vez de perder o débito.
+func Estornar(ctx context.Context, tx *sql.Tx, idVenda int64) error {
+	pontos, err := pontosVenda(ctx, tx, idVenda)
+	if err != nil || pontos <= 0 {
+		return err
+	}
+	query := `
+		INSERT INTO movimento_pontos (id_cliente, id_venda, tipo, pontos)
+		SELECT id_cliente, id_venda, 'estorno', $2 FROM Venda WHERE id_venda = $1`
+	_, err = tx.ExecContext(ctx, query, idVenda, -pontos)
+	return err
+}
+
+// Pontos acumulados pela venda que ainda não foram estornados
+func pontosVenda(ctx context.Context, tx *sql.Tx, idVenda int64) (int64, error) {
+	var pontos int64
+	query := "SELECT COALESCE(SUM(pontos), 0) FROM movimento_pontos WHERE id_venda = $1 AND tipo IN ('acumulo', 'estorno')"
+	err := tx.QueryRowContext(ctx, query, idVenda).Scan(&pontos)
+	return pontos, err
+}
+
+// Saldo e extrato do cliente, com as expirações calculadas até agora
+func (s *Store) GetPontos(ctx context.Context, idCliente int64) (*model.PontosCliente, error) {
+	var existe bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Cliente WHERE id_cliente = $1)", idCliente).Scan(&existe); err != nil {
+		return nil, err
+	}
+	if !existe {
+		return nil, types.ErrNotFound
+	}
+
+	movs, err := movimentos(ctx, s.db, idCliente)
+	if err != nil {
+		return nil, err
+	}
+	config, err := getConfig(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	pontos := Apurar(movs, time.Now())
+	pontos.IDCliente = idCliente
+	pontos.ValorSaldo = math.Round(float64(pontos.Saldo)*config.ValorPonto*100) / 100
+	return &pontos, nil
+}
+
+// Ajuste manual feito por um gerente. Créditos ganham a validade configurada
+// e débitos não podem deixar o saldo negativo.
+func (s *Store) Ajustar(ctx context.Context, idCliente int64, a model.AjustePontosCreate) (*model.MovimentoPontos, error) {
+	if a.Pontos == 0 {
+		return nil, ErrPontosInvalidos
+	}
+	if a.Motivo == "" {
+		return nil, ErrAjusteSemMotivo
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var tipo string
+	err = tx.QueryRowContext(ctx, "SELECT tipo::text FROM Funcionario WHERE id_funcionario = $1", a.IDFuncionario).Scan(&tipo)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if tipo != model.FuncionarioGerente {
+		return nil, ErrFuncionarioGerente
+	}
+
+	saldo, err := travarSaldo(ctx, tx, idCliente)
+	if err != nil {
+		return nil, err
+	}
+	if saldo+a.Pontos < 0 {
+		return nil, fmt.Errorf("%w (saldo %d)", ErrSaldoInsuficiente, saldo)
+	}
+	config, err := getConfig(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := model.MovimentoPontos{
+		IDCliente:     idCliente,
+		Tipo:          model.PontosAjuste,
+		Pontos:        a.Pontos,
+		Motivo:        &a.Motivo,
+		IDFuncionario: &a.IDFuncionario,
+	}
+	query := `
+		INSERT INTO movimento_pontos (id_cliente, tipo, pontos, validade, motivo, id_funcionario)
+		VALUES ($1, 'ajuste', $2, CASE WHEN $2 > 0 THEN CURRENT_DATE + $3::int END, $4, $5)
+		RETURNING id_movimento, data_hora, validade`
+	err = tx.QueryRowContext(ctx, query, idCliente, a.Pontos, config.DiasValidade, a.Motivo, a.IDFuncionario).
+		Scan(&m.IDMovimento, &m.DataHora, &m.Validade)
+	if err != nil {
+		return nil, err
+	}
+	return &m, tx.Commit()
+}
+
+// Resgata pontos do cliente da venda como desconto numa venda em aberto.
+// Se o desconto cobrir o restante a venda é quitada e pontuada.
+func (s *Store) Resgatar(ctx context.Context, idVenda, pontos int64) (*model.AplicaPontos, error) {
+	if pontos <= 0 {
+		return nil, ErrPontosInvalidos
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var idCliente int64
+	var quitada bool
+	err = tx.QueryRowContext(ctx, "SELECT id_cliente, data_hora_pagamento IS NOT NULL FROM Venda WHERE id_venda = $1 FOR UPDATE", idVenda).
+		Scan(&idCliente, &quitada)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	if quitada {
+		return nil, ErrVendaFechada
+	}
+	var resgatada bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM aplica_pontos WHERE id_venda = $1)", idVenda).Scan(&resgatada); err != nil {
+		return nil, err
+	}
+	if resgatada {
+		return nil, ErrResgateExistente
+	}
+
+	saldo, err := travarSaldo(ctx, tx, idCliente)
+	if err != nil {
+		return nil, err
+	}
+	if pontos > saldo {
+		return nil, fmt.Errorf("%w (saldo %d)", ErrSaldoInsuficiente, saldo)
+	}
+	config, err := getConfig(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	t, err := totais.Venda(ctx, tx, idVenda)
+	if err != nil {
+		return nil, err
+	}
+	desconto := math.Round(float64(pontos)*config.ValorPonto*100) / 100
+	if desconto <= 0 {
+		return nil, ErrPontosInvalidos
+	}
+	if math.Round(desconto*100) > math.Round(t.Restante*100) {
+		return nil, fmt.Errorf("%w (restante %.2f)", ErrResgateExcedeVenda, t.Restante)
+	}
+
+	ap := model.AplicaPontos{IDVenda: idVenda, Pontos: pontos, Desconto: desconto}
+	query := `
+		INSERT INTO movimento_pontos (id_cliente, id_venda, tipo, pontos)
+		VALUES ($1, $2, 'resgate', $3)
+		RETURNING id_movimento`
+	if err := tx.QueryRowContext(ctx, query, idCliente, idVenda, -pontos).Scan(&ap.IDMovimento); err != nil {
+		return nil, err
+	}
+	query = `
+		INSERT INTO aplica_pontos (id_venda, id_movimento, pontos, desconto)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id_aplica_pontos`
+	if err := tx.QueryRowContext(ctx, query, idVenda, ap.IDMovimento, pontos, desconto).Scan(&ap.IDAplicaPontos); err != nil {
+		return nil, err
+	}
+
+	if _, err := Quitar(ctx, tx, idVenda, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if t, err = totais.Venda(ctx, tx, idVenda); err != nil {
+		return nil, err
+	}
+	ap.VendaTotais = *t
+	return &ap, tx.Commit()
+}
+
+// Desfaz o resgate de uma venda ainda em aberto, devolvendo os pontos ao cliente
+func (s *Store) CancelarResgate(ctx context.Context, idVenda int64) (*model.AplicaPontos, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var quitada bool
+	err = tx.QueryRowContext(ctx, "SELECT data_hora_pagamento IS NOT NULL FROM Venda WHERE id_venda = $1 FOR UPDATE", idVenda).Scan(&quitada)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	if quitada {
+		return nil, ErrVendaFechada
+	}
+
+	var ap model.AplicaPontos
+	query := `
+		DELETE FROM aplica_pontos WHERE id_venda = $1
+		RETURNING id_aplica_pontos, id_venda, id_movimento, pontos, desconto`
+	err = tx.QueryRowContext(ctx, query, idVenda).Scan(&ap.IDAplicaPontos, &ap.IDVenda, &ap.IDMovimento, &ap.Pontos, &ap.Desconto)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM movimento_pontos WHERE id_movimento = $1", ap.IDMovimento); err != nil {
+		return nil, err
+	}
+
+	t, err := totais.Venda(ctx, tx, idVenda)
+	if err != nil {
+		return nil, err
+	}
+	ap.VendaTotais = *t
+	return &ap, tx.Commit()
+}
+
+func (s *Store) GetConfig(ctx context.Context) (*model.ConfigFidelidade, error) {
+	c, err := getConfig(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Novos valores valem para os próximos acúmulos e resgates, não alteram validades já gravadas
+func (s *Store) UpdateConfig(ctx context.Context, c *model.ConfigFidelidade) error {
+	if c.DiasValidade <= 0 || c.ValorPonto <= 0 {
+		return ErrConfigInvalida
+	}
+	_, err := s.db.ExecContext(ctx, "UPDATE config_fidelidade SET dias_validade = $1, valor_ponto = $2", c.DiasValidade, c.ValorPonto)
+	return err
+}
+
+func validarRegra(r *model.RegraFidelidade) error {
+	if r.PontosPorReal < 0 || (r.IDProduto != nil && r.Categoria != nil) {
+		return ErrRegraInvalida
+	}
+	return nil
+}
+
+func (s *Store) GetRegras(ctx context.Context) ([]model.RegraFidelidade, error) {
+	query := `
+		SELECT id_regra, id_produto, categoria, pontos_por_real
+		FROM regra_fidelidade
+		ORDER BY id_produto NULLS LAST, categoria NULLS LAST`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	regras := make([]model.RegraFidelidade, 0)
+	for rows.Next() {
+		var r model.RegraFidelidade
+		if err := rows.Scan(&r.IDRegra, &r.IDProduto, &r.Categoria, &r.PontosPorReal); err != nil {
+			return nil, err
+		}
+		regras = append(regras, r)
+	}
+	return regras, rows.Err()
+}
+
+func (s *Store) CreateRegra(ctx context.Context, r *model.RegraFidelidade) error {
+	if err := validarRegra(r); err != nil {
+		return err
+	}
+	query := "INSERT INTO regra_fidelidade (id_produto, categoria, pontos_por_real) VALUES ($1, $2, $3) RETURNING id_regra"
+	return s.db.QueryRowContext(ctx, query, r.IDProduto, r.Categoria, r.PontosPorReal).Scan(&r.IDRegra)
+}
+
+func (s *Store) UpdateRegra(ctx context.Context, r *model.RegraFidelidade) error {
+	if err := validarRegra(r); err != nil {
+		return err
+	}
+	query := "UPDATE regra_fidelidade SET id_produto = $1, categoria = $2, pontos_por_real = $3 WHERE id_regra = $4"
+	res, err := s.db.ExecContext(ctx, query, r.IDProduto, r.Categoria, r.PontosPorReal, r.IDRegra)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return types.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) DeleteRegra(ctx context.Context, id int64) (*model.RegraFidelidade, error) {
+	query := "DELETE FROM regra_fidelidade WHERE id_regra = $1 RETURNING id_regra, id_produto, categoria, pontos_por_real"
+	var r model.RegraFidelidade
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&r.IDRegra, &r.IDProduto, &r.Categoria, &r.PontosPorReal)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,66 @@
+package fornecedor
+
+import (
+	"edna/internal/model"
+	"math"
+)
+
+// Entrega de um produto por um fornecedor, na ordem de fornecimento
+type Entrega struct {
+	IDFornecedor   int64
+	NomeFornecedor string
+	IDProduto      int64
+	NomeProduto    string
+	model.PontoPreco
+}
+
+// Agrupa as entregas por fornecedor e produto e calcula último, média, mínimo e
+// máximo. Alta marca quem subiu o preço mais que limiteAlta (%) desde a entrega anterior.
+// As entregas devem vir ordenadas por fornecedor, produto e data.
+func ResumirPrecos(entregas []Entrega, limiteAlta float64) []model.PrecosFornecedorProduto {
+	res := make([]model.PrecosFornecedorProduto, 0)
+	var atual *model.PrecosFornecedorProduto
+	var soma float64
+
+	fechar := func() {
+		if atual == nil {
+			return
+		}
+		atual.Media = arredondar(soma / float64(atual.Entregas))
+		if n := len(atual.Serie); n > 1 {
+			anterior := atual.Serie[n-2].PrecoUnitario
+			v := arredondar((atual.Ultimo - anterior) / anterior * 100)
+			atual.Variacao = &v
+			atual.Alta = v > limiteAlta
+		}
+		res = append(res, *atual)
+	}
+
+	for _, e := range entregas {
+		if atual == nil || atual.IDFornecedor != e.IDFornecedor || atual.IDProduto != e.IDProduto {
+			fechar()
+			atual = &model.PrecosFornecedorProduto{
+				IDFornecedor:   e.IDFornecedor,
+				NomeFornecedor: e.NomeFornecedor,
+				IDProduto:      e.IDProduto,
+				NomeProduto:    e.NomeProduto,
+				Minimo:         e.PrecoUnitario,
+				Maximo:         e.PrecoUnitario,
+				Serie:          make([]model.PontoPreco, 0),
+			}
+			soma = 0
+		}
+		atual.Entregas++
+		atual.Ultimo = e.PrecoUnitario
+		atual.Minimo = math.Min(atual.Minimo, e.PrecoUnitario)
+		atual.Maximo = math.Max(atual.Maximo, e.PrecoUnitario)
+		atual.Serie = append(atual.Serie, e.PontoPreco)
+		soma += e.PrecoUnitario
+	}
+	fechar()
+	return res
+}
+
+func arredondar(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,46 @@
+package fornecedor
+
+import (
+	"edna/internal/model"
+	"testing"
+)
+
+func entrega(idFornecedor, idProduto, idLote int64, preco float64) Entrega {
+	return Entrega{
+		IDFornecedor: idFornecedor,
+		IDProduto:    idProduto,
+		PontoPreco:   model.PontoPreco{IDLote: idLote, PrecoUnitario: preco},
+	}
+}
+
+func TestResumirPrecos(t *testing.T) {
+	entregas := []Entrega{
+		entrega(1, 10, 1, 4.00),
+		entrega(1, 10, 2, 3.50),
+		entrega(1, 10, 3, 4.50),
+		entrega(1, 11, 4, 8.00),
+		entrega(2, 10, 5, 4.00),
+		entrega(2, 10, 6, 4.20),
+	}
+
+	res := ResumirPrecos(entregas, 10)
+
+	if len(res) != 3 {
+		t.Fatalf("expected 3 series, got %d", len(res))
+	}
+	s := res[0]
+	if s.Entregas != 3 || s.Ultimo != 4.50 || s.Minimo != 3.50 || s.Maximo != 4.50 || s.Media != 4.00 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+	// 3.50 -> 4.50 é uma alta de 28.57%
+	if s.Variacao == nil || *s.Variacao != 28.57 || !s.Alta {
+		t.Fatalf("expected a flagged 28.57%% rise, got %+v", s.Variacao)
+	}
+	if res[1].Variacao != nil || res[1].Alta {
+		t.Fatalf("a single delivery has no variation, got %+v", res[1])
+	}
+	// 4.00 -> 4.20 é 5%, abaixo do limite
+	if res[2].Variacao == nil || *res[2].Variacao != 5 || res[2].Alta {
+		t.Fatalf("expected an unflagged 5%% rise, got %+v", res[2])
+	}
+}
@@ -0,0 +1,71 @@
+package fornecedor
+
+import (
+	"edna/internal/model"
+	"sort"
+)
+
+// Critérios aceitos em ?ordem= no relatório de fornecedores
+var ordensRelatorio = map[string]bool{"gasto": true, "unidades": true, "perda": true, "validade": true}
+
+// Posição de cada fornecedor segundo melhor(a, b). Empates dividem a mesma posição.
+func posicoes(fs []model.DesempenhoFornecedor, incluir func(model.DesempenhoFornecedor) bool, melhor func(a, b model.DesempenhoFornecedor) bool) map[int64]int {
+	idx := make([]int, 0, len(fs))
+	for i, f := range fs {
+		if incluir(f) {
+			idx = append(idx, i)
+		}
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return melhor(fs[idx[a]], fs[idx[b]]) })
+
+	res := make(map[int64]int, len(idx))
+	for pos, i := range idx {
+		if pos > 0 && !melhor(fs[idx[pos-1]], fs[i]) {
+			res[fs[i].IdFornecedor] = res[fs[idx[pos-1]].IdFornecedor]
+			continue
+		}
+		res[fs[i].IdFornecedor] = pos + 1
+	}
+	return res
+}
+
+// Preenche os ranks de cada critério e ordena os fornecedores pelo critério pedido.
+// Fornecedores sem lotes com validade ficam sem rank de validade e por último nessa ordem.
+func Ranquear(fs []model.DesempenhoFornecedor, ordem string) {
+	todos := func(model.DesempenhoFornecedor) bool { return true }
+	comValidade := func(f model.DesempenhoFornecedor) bool { return f.ValidadeMediaDias != nil }
+
+	gasto := posicoes(fs, todos, func(a, b model.DesempenhoFornecedor) bool { return a.TotalGasto > b.TotalGasto })
+	unidades := posicoes(fs, todos, func(a, b model.DesempenhoFornecedor) bool { return a.Unidades > b.Unidades })
+	perda := posicoes(fs, todos, func(a, b model.DesempenhoFornecedor) bool { return a.PercentualPerda < b.PercentualPerda })
+	validade := posicoes(fs, comValidade, func(a, b model.DesempenhoFornecedor) bool {
+		return *a.ValidadeMediaDias > *b.ValidadeMediaDias
+	})
+
+	for i := range fs {
+		id := fs[i].IdFornecedor
+		fs[i].RankGasto = gasto[id]
+		fs[i].RankUnidades = unidades[id]
+		fs[i].RankPerda = perda[id]
+		if r, ok := validade[id]; ok {
+			fs[i].RankValidade = &r
+		}
+	}
+
+	rank := func(f model.DesempenhoFornecedor) int {
+		switch ordem {
+		case "unidades":
+			return f.RankUnidades
+		case "perda":
+			return f.RankPerda
+		case "validade":
+			if f.RankValidade == nil {
+				return len(fs) + 1
+			}
+			return *f.RankValidade
+		default:
+			return f.RankGasto
+		}
+	}
+	sort.SliceStable(fs, func(a, b int) bool { return rank(fs[a]) < rank(fs[b]) })
+}
@@ -0,0 +1,32 @@
+package fornecedor
+
+import (
+	"edna/internal/model"
+	"testing"
+)
+
+func TestRanquear(t *testing.T) {
+	dias := func(v float64) *float64 { return &v }
+	fs := []model.DesempenhoFornecedor{
+		{IdFornecedor: 1, TotalGasto: 500, Unidades: 100, PercentualPerda: 5, ValidadeMediaDias: dias(30)},
+		{IdFornecedor: 2, TotalGasto: 900, Unidades: 80, PercentualPerda: 1},
+		{IdFornecedor: 3, TotalGasto: 500, Unidades: 120, PercentualPerda: 12, ValidadeMediaDias: dias(90)},
+	}
+
+	Ranquear(fs, "perda")
+
+	if fs[0].IdFornecedor != 2 || fs[1].IdFornecedor != 1 || fs[2].IdFornecedor != 3 {
+		t.Fatalf("expected order 2, 1, 3 by spoilage, got %d, %d, %d", fs[0].IdFornecedor, fs[1].IdFornecedor, fs[2].IdFornecedor)
+	}
+	byID := make(map[int64]model.DesempenhoFornecedor)
+	for _, f := range fs {
+		byID[f.IdFornecedor] = f
+	}
+	// 1 e 3 empatam no gasto
+	if byID[2].RankGasto != 1 || byID[1].RankGasto != 2 || byID[3].RankGasto != 2 {
+		t.Fatalf("unexpected spend ranks %d %d %d", byID[1].RankGasto, byID[2].RankGasto, byID[3].RankGasto)
+	}
+	if byID[2].RankValidade != nil || *byID[3].RankValidade != 1 || *byID[1].RankValidade != 2 {
+		t.Fatalf("unexpected shelf life ranks")
+	}
+}
@@ -2,10 +2,14 @@ package fornecedor
 
 import (
 	"context"
+	"edna/internal/documento"
 	"edna/internal/model"
 	"edna/internal/util"
+	"edna/internal/types"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 )
 
 
@@ -20,6 +24,8 @@ type FornecedorStore interface {
 	GetByID(ctx context.Context, id int64) (*model.Fornecedor, error)
 	Update(ctx context.Context, props *model.Fornecedor) error
 	Delete(ctx context.Context, id int64) (*model.Fornecedor, error)
+	GetPrecos(ctx context.Context, id int64, limiteAlta float64) (*model.PrecosFornecedor, error)
+	GetRelatorio(ctx context.Context, start, end, ordem string) (model.RelatorioFornecedores, error)
 }
 
 
@@ -30,9 +36,11 @@ func NewHandler(store FornecedorStore) *Handler {
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /fornecedores", h.getAll)
 	mux.HandleFunc("POST /fornecedores", h.create)
+	mux.HandleFunc("GET /fornecedores/relatorio", h.getRelatorio)
 	mux.HandleFunc("GET /fornecedores/{id}", h.fetch)
 	mux.HandleFunc("PUT /fornecedores/{id}", h.update)
 	mux.HandleFunc("DELETE /fornecedores/{id}", h.delete)
+	mux.HandleFunc("GET /fornecedores/{id}/precos", h.fetchPrecos)
 }
 
 // @Summary List Fornecedores
@@ -52,17 +60,17 @@ func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
 
 	filters, err := NewFornecedorFilter(r.URL.Query())
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	fornecedores, err := h.store.GetAll(ctx, filters)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	err = util.WriteJSON(w, http.StatusOK, fornecedores)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
@@ -73,6 +81,7 @@ func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
 // @Param fornecedor body model.FornecedorCreate true "Fornecedor payload"
 // @Success 201 {object} model.Fornecedor
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
 // @Failure 422 {object} types.ErrorResponse
 // @Router /fornecedores [post]
 func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
@@ -91,10 +100,23 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToFornecedor()
+	if model.CNPJ, err = documento.CNPJ(model.CNPJ); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	err = h.store.Create(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		if errors.Is(err, types.ErrDocumentoDuplicado) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -122,7 +144,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 
 	fornecedor, err := h.store.GetByID(ctx, id)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	if fornecedor == nil {
@@ -144,6 +166,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 // @Param fornecedor body model.FornecedorCreate true "Fornecedor payload"
 // @Success 200 {object} model.Fornecedor
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
 // @Failure 422 {object} types.ErrorResponse
 // @Router /fornecedores/{id} [put]
 func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
@@ -163,11 +186,24 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToFornecedor()
 	model.Id = id
+	if model.CNPJ, err = documento.CNPJ(model.CNPJ); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	err = h.store.Update(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		if errors.Is(err, types.ErrDocumentoDuplicado) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -194,9 +230,105 @@ func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
 
 	model, err := h.store.Delete(ctx, id)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
 	util.WriteJSON(w, http.StatusOK, model)
 }
+
+// Alta de preço (%) sobre a entrega anterior a partir da qual o fornecedor é sinalizado
+const LimiteAltaPadrao = 10.0
+
+// Lê ?limite_alta=N (percentual), usando LimiteAltaPadrao quando ausente
+func LimiteAltaParam(r *http.Request) (float64, bool) {
+	v := r.URL.Query().Get("limite_alta")
+	if v == "" {
+		return LimiteAltaPadrao, true
+	}
+	limite, err := strconv.ParseFloat(v, 64)
+	if err != nil || limite < 0 {
+		return 0, false
+	}
+	return limite, true
+}
+
+// @Summary Fornecedor price history
+// @Description Price-over-time series of each product delivered by the supplier with latest, average, min and max price. Suppliers whose last price rose more than limite_alta percent over the previous delivery are flagged with alta.
+// @Tags Fornecedor
+// @Produce json
+// @Param id path int true "Fornecedor ID"
+// @Param limite_alta query number false "Price rise (%) over the previous delivery that flags the supplier (default 10)"
+// @Success 200 {object} model.PrecosFornecedor
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /fornecedores/{id}/precos [get]
+func (h *Handler) fetchPrecos(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limite, ok := LimiteAltaParam(r)
+	if !ok {
+		util.ErrorJSON(w, "limite_alta must be a non-negative number", http.StatusBadRequest)
+		return
+	}
+
+	precos, err := h.store.GetPrecos(ctx, id, limite)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Fornecedor not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, precos); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Fornecedor scorecard
+// @Description Ranks suppliers by total spend, units delivered, spoilage percentage and average shelf life at delivery, over the lotes delivered in the period
+// @Tags Fornecedor
+// @Produce json
+// @Param start query string true "Period start date (YYYY-MM-DD)"
+// @Param end query string true "Period end date (YYYY-MM-DD)"
+// @Param ordem query string false "Sort by rank of: gasto (default), unidades, perda, validade"
+// @Success 200 {object} model.RelatorioFornecedores
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /fornecedores/relatorio [get]
+func (h *Handler) getRelatorio(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	q := r.URL.Query()
+	start := q.Get("start")
+	end := q.Get("end")
+
+	if start == "" || end == "" {
+		util.ErrorJSON(w, "start and end query parameters are required (YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.store.GetRelatorio(ctx, start, end, q.Get("ordem"))
+	if err != nil {
+		if errors.Is(err, ErrParametroInvalido) {
+			util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, report); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -6,8 +6,13 @@ import (
 	"edna/internal/model"
 	"edna/internal/types"
 	"edna/internal/util"
+	"errors"
+	"fmt"
+	"time"
 )
 
+var ErrParametroInvalido = errors.New("Parâmetro inválido")
+
 type Store struct {
 	db *sql.DB
 }
@@ -39,7 +44,24 @@ func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.Fornece
 }
 
 
+// Confere se o CNPJ já pertence a outro fornecedor (o índice fornecedor_cnpj_unico
+// é quem garante a unicidade)
+func (s *Store) cnpjEmUso(ctx context.Context, cnpj string, id int64) error {
+	var emUso bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Fornecedor WHERE CNPJ = $1 AND id_fornecedor <> $2)", cnpj, id).Scan(&emUso)
+	if err != nil {
+		return err
+	}
+	if emUso {
+		return fmt.Errorf("%w: CNPJ %s", types.ErrDocumentoDuplicado, cnpj)
+	}
+	return nil
+}
+
 func (s *Store) Create(ctx context.Context, props *model.Fornecedor) error {
+	if err := s.cnpjEmUso(ctx, props.CNPJ, 0); err != nil {
+		return err
+	}
 	query := "INSERT INTO Fornecedor (nome, CNPJ) VALUES ($1, $2) RETURNING id_fornecedor;"
 
 	res := s.db.QueryRowContext(ctx, query, props.Nome, props.CNPJ)
@@ -61,6 +83,9 @@ func (s *Store) GetByID(ctx context.Context, id int64) (*model.Fornecedor, error
 }
 
 func (s *Store) Update(ctx context.Context, props *model.Fornecedor) error {
+	if err := s.cnpjEmUso(ctx, props.CNPJ, props.Id); err != nil {
+		return err
+	}
 	query := "UPDATE Fornecedor SET nome = $1, CNPJ = $2 WHERE id_fornecedor = $3;"
 
 	res, err := s.db.ExecContext(ctx, query, props.Nome, props.CNPJ, props.Id)
@@ -88,3 +113,131 @@ func (s *Store) Delete(ctx context.Context, id int64) (*model.Fornecedor, error)
 	}
 	return &model, nil
 }
+
+// Lotes entregues, em ordem de fornecedor, produto e data
+func (s *Store) entregas(ctx context.Context, where string, id int64) ([]Entrega, error) {
+	query := `
+		SELECT l.id_fornecedor, f.nome, l.id_produto, p.nome, l.id_lote, l.data_fornecimento, l.preco_unitario
+		FROM Lote l
+		JOIN Fornecedor f ON f.id_fornecedor = l.id_fornecedor
+		JOIN Produto p ON p.id_produto = l.id_produto
+		WHERE ` + where + ` = $1
+		ORDER BY l.id_fornecedor, l.id_produto, l.data_fornecimento, l.id_lote`
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entregas := make([]Entrega, 0)
+	for rows.Next() {
+		var e Entrega
+		err := rows.Scan(&e.IDFornecedor, &e.NomeFornecedor, &e.IDProduto, &e.NomeProduto, &e.IDLote, &e.DataFornecimento, &e.PrecoUnitario)
+		if err != nil {
+			return nil, err
+		}
+		entregas = append(entregas, e)
+	}
+	return entregas, rows.Err()
+}
+
+// Histórico de preços de cada produto entregue pelo fornecedor
+func (s *Store) GetPrecos(ctx context.Context, id int64, limiteAlta float64) (*model.PrecosFornecedor, error) {
+	fornecedor, err := s.GetByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	entregas, err := s.entregas(ctx, "l.id_fornecedor", id)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PrecosFornecedor{
+		IDFornecedor: fornecedor.Id,
+		Nome:         fornecedor.Nome,
+		LimiteAlta:   limiteAlta,
+		Produtos:     ResumirPrecos(entregas, limiteAlta),
+	}, nil
+}
+
+// Histórico de preços de cada fornecedor que entregou o produto
+func (s *Store) GetPrecosProduto(ctx context.Context, idProduto int64, limiteAlta float64) (*model.PrecosProduto, error) {
+	precos := model.PrecosProduto{IDProduto: idProduto, LimiteAlta: limiteAlta}
+	err := s.db.QueryRowContext(ctx, "SELECT nome FROM Produto WHERE id_produto = $1", idProduto).Scan(&precos.Nome)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	entregas, err := s.entregas(ctx, "l.id_produto", idProduto)
+	if err != nil {
+		return nil, err
+	}
+	precos.Fornecedores = ResumirPrecos(entregas, limiteAlta)
+	return &precos, nil
+}
+
+// Desempenho dos fornecedores nos lotes entregues entre start e end (YYYY-MM-DD).
+// Perda são os ajustes de estoque fora de inventário (estragados do lote).
+func (s *Store) GetRelatorio(ctx context.Context, start, end, ordem string) (model.RelatorioFornecedores, error) {
+	report := model.RelatorioFornecedores{Fornecedores: make([]model.DesempenhoFornecedor, 0)}
+
+	if ordem == "" {
+		ordem = "gasto"
+	}
+	if !ordensRelatorio[ordem] {
+		return report, fmt.Errorf("%w: ordem %s (use gasto, unidades, perda ou validade)", ErrParametroInvalido, ordem)
+	}
+	startT, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return report, fmt.Errorf("%w: data de início %s", ErrParametroInvalido, start)
+	}
+	endT, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return report, fmt.Errorf("%w: data de fim %s", ErrParametroInvalido, end)
+	}
+	if endT.Before(startT) {
+		return report, fmt.Errorf("%w: data de fim deve ser >= data de início", ErrParametroInvalido)
+	}
+	report.PeriodStart = startT.Format("2006-01-02")
+	report.PeriodEnd = endT.Format("2006-01-02")
+
+	query := `
+		SELECT f.id_fornecedor, f.nome, COUNT(*),
+			COALESCE(SUM(l.preco_unitario * COALESCE(l.quantidade_inicial, 0)), 0),
+			COALESCE(SUM(el.quantidade_inicial), 0)::bigint,
+			COALESCE(SUM(el.estragados), 0)::bigint,
+			ROUND(AVG(l.validade - l.data_fornecimento) FILTER (WHERE l.validade IS NOT NULL), 1)::float8
+		FROM Lote l
+		JOIN estoque_lote el ON el.id_lote = l.id_lote
+		JOIN Fornecedor f ON f.id_fornecedor = l.id_fornecedor
+		WHERE l.data_fornecimento BETWEEN $1::date AND $2::date
+		GROUP BY f.id_fornecedor, f.nome`
+	rows, err := s.db.QueryContext(ctx, query, report.PeriodStart, report.PeriodEnd)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d model.DesempenhoFornecedor
+		err := rows.Scan(&d.IdFornecedor, &d.Nome, &d.Lotes, &d.TotalGasto, &d.Unidades, &d.Estragados, &d.ValidadeMediaDias)
+		if err != nil {
+			return report, err
+		}
+		if d.Unidades > 0 {
+			d.PercentualPerda = arredondar(float64(d.Estragados) / float64(d.Unidades) * 100)
+		}
+		d.TotalGasto = arredondar(d.TotalGasto)
+		report.Fornecedores = append(report.Fornecedores, d)
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	Ranquear(report.Fornecedores, ordem)
+	return report, nil
+}
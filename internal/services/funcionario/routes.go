@@ -2,9 +2,12 @@ package funcionario
 
 import (
 	"context"
+	"edna/internal/documento"
 	"edna/internal/model"
+	"edna/internal/types"
 	"edna/internal/util"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -49,17 +52,17 @@ func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
 
 	filters, err := NewFuncionarioFilter(r.URL.Query())
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	funcionarios, err := h.store.GetAll(ctx, filters)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	err = util.WriteJSON(w, http.StatusOK, funcionarios)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
@@ -70,6 +73,7 @@ func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
 // @Param funcionario body model.FuncionarioCreate true "Funcionario payload"
 // @Success 201 {object} model.Funcionario
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
 // @Failure 422 {object} types.ErrorResponse
 // @Router /funcionarios [post]
 func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
@@ -88,10 +92,23 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToFuncionario()
+	if model.CPF, err = documento.CPF(model.CPF); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	err = h.store.Create(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		if errors.Is(err, types.ErrDocumentoDuplicado) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -119,7 +136,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 
 	funcionario, err := h.store.GetByID(ctx, id)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	if funcionario == nil {
@@ -134,6 +151,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Update Funcionario
+// @Description Records anonymized under the LGPD cannot be updated (409).
 // @Tags Funcionario
 // @Accept json
 // @Produce json
@@ -141,6 +159,8 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 // @Param funcionario body model.FuncionarioCreate true "Funcionario payload"
 // @Success 200 {object} model.Funcionario
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
 // @Failure 422 {object} types.ErrorResponse
 // @Router /funcionarios/{id} [put]
 func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
@@ -160,11 +180,28 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToFuncionario()
 	model.Id = id
+	if model.CPF, err = documento.CPF(model.CPF); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	err = h.store.Update(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Funcionario not found.", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, types.ErrDocumentoDuplicado) || errors.Is(err, types.ErrAnonimizado) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -191,7 +228,7 @@ func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
 
 	model, err := h.store.Delete(ctx, id)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -6,6 +6,7 @@ import (
 	"edna/internal/model"
 	"edna/internal/types"
 	"edna/internal/util"
+	"fmt"
 )
 
 type Store struct {
@@ -18,7 +19,7 @@ func NewStore(db *sql.DB) *Store {
 
 func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.Funcionario, error) {
 
-	query := "SELECT id_funcionario, nome, CPF, tipo, expediente, salario, data_contratacao FROM Funcionario AS fc"
+	query := "SELECT id_funcionario, nome, COALESCE(CPF, '') AS CPF, tipo, expediente, salario, data_contratacao FROM Funcionario AS fc"
 	rows, err := util.QueryRowsWithFilter(s.db, ctx, query, &filter, "fc")
 	if err != nil {
 		return nil, err
@@ -38,14 +39,31 @@ func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.Funcion
 	return funcionarios, nil
 }
 
+// Confere se o CPF já pertence a outro funcionário. O índice único
+// funcionario_cpf_unico cobre as inserções concorrentes.
+func (s *Store) cpfEmUso(ctx context.Context, cpf string, id int64) error {
+	var emUso bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Funcionario WHERE CPF = $1 AND id_funcionario <> $2)", cpf, id).Scan(&emUso)
+	if err != nil {
+		return err
+	}
+	if emUso {
+		return fmt.Errorf("%w: CPF %s", types.ErrDocumentoDuplicado, cpf)
+	}
+	return nil
+}
+
 func (s *Store) Create(ctx context.Context, props *model.Funcionario) error {
+	if err := s.cpfEmUso(ctx, props.CPF, 0); err != nil {
+		return err
+	}
 	query := "INSERT INTO Funcionario (nome, CPF, tipo, expediente, salario, data_contratacao) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id_funcionario"
 	res := s.db.QueryRowContext(ctx, query, props.Nome, props.CPF, props.Tipo, props.Expediente, props.Salario, props.DataContratacao)
 	return res.Scan(&props.Id)
 }
 
 func (s *Store) GetByID(ctx context.Context, id int64) (*model.Funcionario, error) {
-	query := "SELECT id_funcionario, nome, CPF, tipo, expediente, salario, data_contratacao FROM Funcionario WHERE id_funcionario = $1;"
+	query := "SELECT id_funcionario, nome, COALESCE(CPF, '') AS CPF, tipo, expediente, salario, data_contratacao FROM Funcionario WHERE id_funcionario = $1;"
 
 	row := s.db.QueryRowContext(ctx, query, id)
 
@@ -62,7 +80,10 @@ func (s *Store) GetByID(ctx context.Context, id int64) (*model.Funcionario, erro
 }
 
 func (s *Store) Update(ctx context.Context, props *model.Funcionario) error {
-	query := "UPDATE Funcionario SET nome = $1, CPF = $2, tipo = $3, expediente = $4, salario = $5, data_contratacao = $6 WHERE id_funcionario = $7;"
+	if err := s.cpfEmUso(ctx, props.CPF, props.Id); err != nil {
+		return err
+	}
+	query := "UPDATE Funcionario SET nome = $1, CPF = $2, tipo = $3, expediente = $4, salario = $5, data_contratacao = $6 WHERE id_funcionario = $7 AND anonimizado_em IS NULL;"
 
 	res, err := s.db.ExecContext(ctx, query, props.Nome, props.CPF, props.Tipo, props.Expediente, props.Salario, props.DataContratacao, props.Id)
 	if err != nil {
@@ -73,13 +94,24 @@ func (s *Store) Update(ctx context.Context, props *model.Funcionario) error {
 		return err
 	}
 	if rowsAffected == 0 {
+		var anonimizado bool
+		err := s.db.QueryRowContext(ctx, "SELECT anonimizado_em IS NOT NULL FROM Funcionario WHERE id_funcionario = $1", props.Id).Scan(&anonimizado)
+		if err == sql.ErrNoRows {
+			return types.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if anonimizado {
+			return types.ErrAnonimizado
+		}
 		return types.ErrNotFound
 	}
 	return nil
 }
 
 func (s *Store) Delete(ctx context.Context, id int64) (*model.Funcionario, error) {
-	query := "DELETE FROM Funcionario WHERE id_funcionario = $1 RETURNING id_funcionario, nome, CPF, tipo, expediente, salario, data_contratacao;"
+	query := "DELETE FROM Funcionario WHERE id_funcionario = $1 RETURNING id_funcionario, nome, COALESCE(CPF, ''), tipo, expediente, salario, data_contratacao;"
 
 	var model model.Funcionario
 	row := s.db.QueryRowContext(ctx, query, id)
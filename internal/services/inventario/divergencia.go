@@ -0,0 +1,76 @@
+package inventario
+
+import (
+	"edna/internal/model"
+	"math"
+)
+
+// Estoque calculado de um lote no momento do fechamento
+type SaldoLote struct {
+	IDLote     int64
+	Disponivel int64
+}
+
+// Distribui a contagem de um produto inteiro entre os seus lotes, que devem vir
+// na ordem de saída (FIFO). Faltas saem dos primeiros lotes e sobras vão para o
+// último lote com estoque, ou para o último lote quando nenhum tem estoque.
+// Retorna a quantidade contada atribuída a cada lote.
+func Distribuir(lotes []SaldoLote, contado int64) []int64 {
+	contados := make([]int64, len(lotes))
+	var total int64
+	ultimo := len(lotes) - 1
+	for i, l := range lotes {
+		contados[i] = max(l.Disponivel, 0)
+		total += contados[i]
+		if l.Disponivel > 0 {
+			ultimo = i
+		}
+	}
+	if len(lotes) == 0 {
+		return contados
+	}
+
+	if contado > total {
+		contados[ultimo] += contado - total
+		return contados
+	}
+	falta := total - contado
+	for i := range contados {
+		if falta == 0 {
+			break
+		}
+		tira := min(falta, contados[i])
+		contados[i] -= tira
+		falta -= tira
+	}
+	return contados
+}
+
+// Calcula diferença e valor de cada linha e os totais do relatório
+func Resumir(idInventario int64, status string, itens []model.DivergenciaInventario) model.RelatorioInventario {
+	rel := model.RelatorioInventario{
+		IDInventario: idInventario,
+		Status:       status,
+		Itens:        make([]model.DivergenciaInventario, 0, len(itens)),
+	}
+	for _, d := range itens {
+		d.Diferenca = d.Contado - d.Esperado
+		d.Valor = arredondar(float64(d.Diferenca) * d.CustoUnitario)
+		if d.Diferenca < 0 {
+			rel.UnidadesFaltando -= d.Diferenca
+			rel.ValorFaltando -= d.Valor
+		} else {
+			rel.UnidadesSobrando += d.Diferenca
+			rel.ValorSobrando += d.Valor
+		}
+		rel.Itens = append(rel.Itens, d)
+	}
+	rel.ValorFaltando = arredondar(rel.ValorFaltando)
+	rel.ValorSobrando = arredondar(rel.ValorSobrando)
+	rel.ValorLiquido = arredondar(rel.ValorSobrando - rel.ValorFaltando)
+	return rel
+}
+
+func arredondar(v float64) float64 {
+	return math.Round(v*100) / 100
+}
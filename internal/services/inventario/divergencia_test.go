@@ -0,0 +1,48 @@
+package inventario
+
+import (
+	"edna/internal/model"
+	"slices"
+	"testing"
+)
+
+func TestDistribuir(t *testing.T) {
+	lotes := []SaldoLote{{1, 4}, {2, 0}, {3, 6}, {4, 0}}
+	cases := []struct {
+		nome     string
+		contado  int64
+		esperado []int64
+	}{
+		{"sem diferença", 10, []int64{4, 0, 6, 0}},
+		{"falta sai dos primeiros lotes", 5, []int64{0, 0, 5, 0}},
+		{"sobra vai para o último lote com estoque", 13, []int64{4, 0, 9, 0}},
+		{"nada contado", 0, []int64{0, 0, 0, 0}},
+	}
+	for _, c := range cases {
+		if got := Distribuir(lotes, c.contado); !slices.Equal(got, c.esperado) {
+			t.Fatalf("%s: expected %v, got %v", c.nome, c.esperado, got)
+		}
+	}
+
+	if got := Distribuir([]SaldoLote{{1, 0}, {2, 0}}, 3); !slices.Equal(got, []int64{0, 3}) {
+		t.Fatalf("expected surplus on the last lote when none has stock, got %v", got)
+	}
+}
+
+func TestResumir(t *testing.T) {
+	rel := Resumir(7, model.InventarioFechado, []model.DivergenciaInventario{
+		{IDLote: 1, Esperado: 10, Contado: 7, CustoUnitario: 2.50},
+		{IDLote: 2, Esperado: 3, Contado: 4, CustoUnitario: 1.10},
+		{IDLote: 3, Esperado: 5, Contado: 5, CustoUnitario: 9.99},
+	})
+
+	if rel.Itens[0].Diferenca != -3 || rel.Itens[0].Valor != -7.50 {
+		t.Fatalf("unexpected first line: %+v", rel.Itens[0])
+	}
+	if rel.UnidadesFaltando != 3 || rel.UnidadesSobrando != 1 {
+		t.Fatalf("unexpected units: faltando %d sobrando %d", rel.UnidadesFaltando, rel.UnidadesSobrando)
+	}
+	if rel.ValorFaltando != 7.50 || rel.ValorSobrando != 1.10 || rel.ValorLiquido != -6.40 {
+		t.Fatalf("unexpected values: %+v", rel)
+	}
+}
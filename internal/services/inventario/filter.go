@@ -0,0 +1,38 @@
+package inventario
+
+import (
+	"edna/internal/util"
+	"net/url"
+)
+
+func NewInventarioFilter(params url.Values) (util.Filter, error) {
+	var filter util.Filter
+	if err := filter.GetOffset(params); err != nil {
+		return filter, err
+	}
+
+	if err := filter.GetLimit(params); err != nil {
+		return filter, err
+	}
+
+	attrs := []string{"data_abertura", "data_fechamento", "data_aprovacao", "id_funcionario", "status"}
+
+	if err := filter.GetSorts(params, attrs); err != nil {
+		return filter, err
+	}
+
+	if err := filter.GetFilterStr(params, "status"); err != nil {
+		return filter, err
+	}
+
+	if err := filter.GetFilterInt(params, "id_funcionario"); err != nil {
+		return filter, err
+	}
+
+	for _, attr := range []string{"data_abertura", "data_fechamento", "data_aprovacao"} {
+		if err := filter.GetFilterTime(params, attr); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,331 @@
+package inventario
+
+import (
+	"context"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type Handler struct {
+	store InventarioStore
+}
+
+type InventarioStore interface {
+	GetAll(ctx context.Context, filter util.Filter) ([]model.Inventario, error)
+	GetByID(ctx context.Context, id int64) (*model.Inventario, error)
+	Abrir(ctx context.Context, inv *model.Inventario) error
+	AddContagem(ctx context.Context, c *model.ContagemInventario) error
+	GetRelatorio(ctx context.Context, id int64) (*model.RelatorioInventario, error)
+	Fechar(ctx context.Context, id int64) (*model.RelatorioInventario, error)
+	Aprovar(ctx context.Context, id, idAprovador int64) (*model.RelatorioInventario, error)
+	Cancelar(ctx context.Context, id int64) (*model.Inventario, error)
+}
+
+func NewHandler(store InventarioStore) *Handler {
+	return &Handler{store}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /inventarios", h.getAll)
+	mux.HandleFunc("POST /inventarios", h.abrir)
+	mux.HandleFunc("GET /inventarios/{id}", h.fetch)
+	mux.HandleFunc("POST /inventarios/{id}/contagens", h.addContagem)
+	mux.HandleFunc("GET /inventarios/{id}/divergencias", h.fetchRelatorio)
+	mux.HandleFunc("POST /inventarios/{id}/fechar", h.fechar)
+	mux.HandleFunc("POST /inventarios/{id}/aprovar", h.aprovar)
+	mux.HandleFunc("POST /inventarios/{id}/cancelar", h.cancelar)
+}
+
+// @Summary List inventory counts
+// @Tags Inventario
+// @Produce json
+// @Param filter-status query string false "Filter by status (aberto, fechado, aprovado, cancelado) using operators: eq, ne"
+// @Param filter-id_funcionario query int false "Filter by id_funcionario using operators: eq, ne, gt, lt"
+// @Param filter-data_abertura query string false "Filter by data_abertura using operators: eq, ne, gt, lt"
+// @Param sort query string false "Sort fields: data_abertura, data_fechamento, data_aprovacao, id_funcionario, status. Prefix with '-' for desc."
+// @Param offset query int false "Pagination offset (default 0)"
+// @Param limit query int false "Pagination limit (default 10)"
+// @Success 200 {array} model.Inventario
+// @Failure 500 {object} types.ErrorResponse
+// @Router /inventarios [get]
+func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	filters, err := NewInventarioFilter(r.URL.Query())
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	inventarios, err := h.store.GetAll(ctx, filters)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := util.WriteJSON(w, http.StatusOK, inventarios); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Open inventory count
+// @Description Opens a physical count session. Only one count can be open or waiting for approval at a time.
+// @Tags Inventario
+// @Accept json
+// @Produce json
+// @Param inventario body model.InventarioCreate true "Inventario payload"
+// @Success 201 {object} model.Inventario
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /inventarios [post]
+func (h *Handler) abrir(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	var payload model.InventarioCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	inventario := payload.ToInventario()
+	if err := h.store.Abrir(ctx, &inventario); err != nil {
+		writeInventarioError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, inventario)
+}
+
+// @Summary Get inventory count by ID
+// @Tags Inventario
+// @Produce json
+// @Param id path int true "Inventario ID"
+// @Success 200 {object} model.Inventario
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /inventarios/{id} [get]
+func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	inventario, err := h.store.GetByID(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Inventario not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, inventario); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Submit counted quantity
+// @Description Records the counted quantity of a whole product or, with id_lote, of a single lote. Counting again replaces the previous count.
+// @Tags Inventario
+// @Accept json
+// @Produce json
+// @Param id path int true "Inventario ID"
+// @Param contagem body model.ContagemInventarioCreate true "Contagem payload"
+// @Success 201 {object} model.ContagemInventario
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /inventarios/{id}/contagens [post]
+func (h *Handler) addContagem(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.ContagemInventarioCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	contagem := payload.ToContagemInventario(id)
+	if err := h.store.AddContagem(ctx, &contagem); err != nil {
+		writeInventarioError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, contagem)
+}
+
+// @Summary Inventory variance report
+// @Description Counted minus expected stock per lote, valued at lot cost. While the count is open it is a preview against the current stock; after closing it uses the stock frozen at closing time.
+// @Tags Inventario
+// @Produce json
+// @Param id path int true "Inventario ID"
+// @Success 200 {object} model.RelatorioInventario
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /inventarios/{id}/divergencias [get]
+func (h *Handler) fetchRelatorio(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	relatorio, err := h.store.GetRelatorio(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Inventario not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, relatorio); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Close inventory count
+// @Description Stops accepting counts and freezes the expected stock of every counted lote
+// @Tags Inventario
+// @Produce json
+// @Param id path int true "Inventario ID"
+// @Success 200 {object} model.RelatorioInventario
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /inventarios/{id}/fechar [post]
+func (h *Handler) fechar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	relatorio, err := h.store.Fechar(ctx, id)
+	if err != nil {
+		writeInventarioError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, relatorio)
+}
+
+// @Summary Approve inventory count
+// @Description A gerente approves a closed count, posting each difference as an ajuste de estoque with motivo inventario
+// @Tags Inventario
+// @Accept json
+// @Produce json
+// @Param id path int true "Inventario ID"
+// @Param aprovacao body model.AprovacaoInventario true "Aprovacao payload"
+// @Success 200 {object} model.RelatorioInventario
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /inventarios/{id}/aprovar [post]
+func (h *Handler) aprovar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.AprovacaoInventario
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	relatorio, err := h.store.Aprovar(ctx, id, payload.IDFuncionario)
+	if err != nil {
+		writeInventarioError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, relatorio)
+}
+
+// @Summary Cancel inventory count
+// @Description Cancels an open or closed count without posting adjustments
+// @Tags Inventario
+// @Produce json
+// @Param id path int true "Inventario ID"
+// @Success 200 {object} model.Inventario
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /inventarios/{id}/cancelar [post]
+func (h *Handler) cancelar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	inventario, err := h.store.Cancelar(ctx, id)
+	if err != nil {
+		writeInventarioError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, inventario)
+}
+
+func writeInventarioError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		util.ErrorJSON(w, "Inventario not found.", http.StatusNotFound)
+	case errors.Is(err, ErrInventarioEmAndamento), errors.Is(err, ErrInventarioNaoAberto),
+		errors.Is(err, ErrTransicaoInvalida), errors.Is(err, types.ErrEstoqueInsuficiente):
+		util.ErrorJSON(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrContagemInvalida), errors.Is(err, ErrSemContagens):
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrAprovadorInvalido):
+		util.ErrorJSON(w, err.Error(), http.StatusForbidden)
+	default:
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+	}
+}
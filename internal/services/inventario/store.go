@@ -0,0 +1,492 @@
+package inventario
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/services/estoque"
+	"edna/internal/types"
+	"edna/internal/util"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrInventarioEmAndamento = errors.New("Já existe um inventário aberto ou aguardando aprovação")
+	ErrInventarioNaoAberto   = errors.New("Inventário não está aberto para contagem")
+	ErrTransicaoInvalida     = errors.New("Transição de status inválida para o inventário")
+	ErrContagemInvalida      = errors.New("Contagem inválida")
+	ErrSemContagens          = errors.New("Inventário sem contagens não pode ser fechado")
+	ErrAprovadorInvalido     = errors.New("Aprovação exige um funcionário do tipo gerente")
+)
+
+// *sql.DB e *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const selectInventario = `
+	SELECT id_inventario, id_funcionario, status::text, observacao, data_abertura, data_fechamento, id_aprovador, data_aprovacao
+	FROM inventario`
+
+func scanInventario(row interface{ Scan(...any) error }) (*model.Inventario, error) {
+	var i model.Inventario
+	err := row.Scan(&i.IDInventario, &i.IDFuncionario, &i.Status, &i.Observacao, &i.DataAbertura, &i.DataFechamento,
+		&i.IDAprovador, &i.DataAprovacao)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	i.Contagens = make([]model.ContagemInventario, 0)
+	return &i, nil
+}
+
+func getInventario(ctx context.Context, q querier, id int64) (*model.Inventario, error) {
+	inv, err := scanInventario(q.QueryRowContext(ctx, selectInventario+" WHERE id_inventario = $1", id))
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id_contagem, id_inventario, id_produto, id_lote, quantidade, id_funcionario, data_hora
+		FROM contagem_inventario
+		WHERE id_inventario = $1
+		ORDER BY id_contagem`
+	rows, err := q.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c model.ContagemInventario
+		if err := rows.Scan(&c.IDContagem, &c.IDInventario, &c.IDProduto, &c.IDLote, &c.Quantidade, &c.IDFuncionario, &c.DataHora); err != nil {
+			return nil, err
+		}
+		inv.Contagens = append(inv.Contagens, c)
+	}
+	return inv, rows.Err()
+}
+
+// Bloqueia o inventário e retorna o status atual
+func travarInventario(ctx context.Context, tx *sql.Tx, id int64) (string, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, "SELECT status::text FROM inventario WHERE id_inventario = $1 FOR UPDATE", id).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", types.ErrNotFound
+		}
+		return "", err
+	}
+	return status, nil
+}
+
+// Lista os inventários sem as contagens
+func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.Inventario, error) {
+	query := "SELECT * FROM (" + selectInventario + ") AS i"
+	rows, err := util.QueryRowsWithFilter(s.db, ctx, query, &filter, "i")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inventarios := make([]model.Inventario, 0)
+	for rows.Next() {
+		inv, err := scanInventario(rows)
+		if err != nil {
+			return nil, err
+		}
+		inventarios = append(inventarios, *inv)
+	}
+	return inventarios, rows.Err()
+}
+
+func (s *Store) GetByID(ctx context.Context, id int64) (*model.Inventario, error) {
+	return getInventario(ctx, s.db, id)
+}
+
+// Abre uma sessão de contagem. Só um inventário pode estar em andamento por vez.
+func (s *Store) Abrir(ctx context.Context, inv *model.Inventario) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var andamento bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM inventario WHERE status IN ('aberto', 'fechado'))").Scan(&andamento)
+	if err != nil {
+		return err
+	}
+	if andamento {
+		return ErrInventarioEmAndamento
+	}
+
+	query := `
+		INSERT INTO inventario (id_funcionario, observacao)
+		VALUES ($1, $2)
+		RETURNING id_inventario, status::text, data_abertura;`
+	err = tx.QueryRowContext(ctx, query, inv.IDFuncionario, inv.Observacao).Scan(&inv.IDInventario, &inv.Status, &inv.DataAbertura)
+	if err != nil {
+		return err
+	}
+	inv.Contagens = make([]model.ContagemInventario, 0)
+	return tx.Commit()
+}
+
+// Registra a contagem de um produto ou lote. Contar de novo substitui a contagem anterior.
+// Um mesmo produto é contado inteiro ou lote a lote, nunca das duas formas.
+func (s *Store) AddContagem(ctx context.Context, c *model.ContagemInventario) error {
+	if c.Quantidade < 0 {
+		return fmt.Errorf("%w: quantidade não pode ser negativa", ErrContagemInvalida)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := travarInventario(ctx, tx, c.IDInventario)
+	if err != nil {
+		return err
+	}
+	if status != model.InventarioAberto {
+		return ErrInventarioNaoAberto
+	}
+
+	if c.IDLote != nil {
+		var idProduto int64
+		err := tx.QueryRowContext(ctx, "SELECT id_produto FROM Lote WHERE id_lote = $1", *c.IDLote).Scan(&idProduto)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: lote %d não encontrado", ErrContagemInvalida, *c.IDLote)
+			}
+			return err
+		}
+		if c.IDProduto != 0 && c.IDProduto != idProduto {
+			return fmt.Errorf("%w: lote %d não é do produto %d", ErrContagemInvalida, *c.IDLote, c.IDProduto)
+		}
+		c.IDProduto = idProduto
+	} else {
+		var temLote bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Lote WHERE id_produto = $1)", c.IDProduto).Scan(&temLote)
+		if err != nil {
+			return err
+		}
+		if !temLote {
+			return fmt.Errorf("%w: produto %d não tem lotes", ErrContagemInvalida, c.IDProduto)
+		}
+	}
+
+	var mista bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM contagem_inventario
+			WHERE id_inventario = $1 AND id_produto = $2 AND (id_lote IS NULL) <> $3
+		)`
+	if err := tx.QueryRowContext(ctx, query, c.IDInventario, c.IDProduto, c.IDLote == nil).Scan(&mista); err != nil {
+		return err
+	}
+	if mista {
+		return fmt.Errorf("%w: produto %d já foi contado de outra forma (inteiro ou por lote)", ErrContagemInvalida, c.IDProduto)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM contagem_inventario
+		WHERE id_inventario = $1 AND id_produto = $2 AND id_lote IS NOT DISTINCT FROM $3`,
+		c.IDInventario, c.IDProduto, c.IDLote)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO contagem_inventario (id_inventario, id_produto, id_lote, quantidade, id_funcionario)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id_contagem, data_hora;`
+	err = tx.QueryRowContext(ctx, query, c.IDInventario, c.IDProduto, c.IDLote, c.Quantidade, c.IDFuncionario).
+		Scan(&c.IDContagem, &c.DataHora)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Compara as contagens com o estoque calculado agora, lote a lote.
+// Contagens de produto inteiro são distribuídas entre os lotes com Distribuir.
+func calcularDivergencias(ctx context.Context, q querier, id int64) ([]model.DivergenciaInventario, error) {
+	itens := make([]model.DivergenciaInventario, 0)
+
+	query := `
+		SELECT c.id_lote, el.id_produto, p.nome, el.disponivel, c.quantidade, l.preco_unitario
+		FROM contagem_inventario c
+		JOIN estoque_lote el ON el.id_lote = c.id_lote
+		JOIN Lote l ON l.id_lote = c.id_lote
+		JOIN Produto p ON p.id_produto = el.id_produto
+		WHERE c.id_inventario = $1 AND c.id_lote IS NOT NULL`
+	rows, err := q.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d model.DivergenciaInventario
+		if err := rows.Scan(&d.IDLote, &d.IDProduto, &d.Nome, &d.Esperado, &d.Contado, &d.CustoUnitario); err != nil {
+			return nil, err
+		}
+		itens = append(itens, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Todos os lotes do produto, inclusive vencidos, na ordem de saída
+	query = `
+		SELECT c.id_produto, c.quantidade, el.id_lote, el.disponivel, l.preco_unitario, p.nome
+		FROM contagem_inventario c
+		JOIN estoque_lote el ON el.id_produto = c.id_produto
+		JOIN Lote l ON l.id_lote = el.id_lote
+		JOIN Produto p ON p.id_produto = c.id_produto
+		WHERE c.id_inventario = $1 AND c.id_lote IS NULL
+		ORDER BY c.id_produto, el.validade ASC NULLS LAST, el.id_lote ASC`
+	rows, err = q.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var produto []model.DivergenciaInventario
+	var saldos []SaldoLote
+	var contado int64
+	distribuir := func() {
+		for i, c := range Distribuir(saldos, contado) {
+			produto[i].Contado = c
+			if produto[i].Esperado != 0 || c != 0 {
+				itens = append(itens, produto[i])
+			}
+		}
+		produto, saldos = produto[:0], saldos[:0]
+	}
+	for rows.Next() {
+		var d model.DivergenciaInventario
+		var quantidade int64
+		if err := rows.Scan(&d.IDProduto, &quantidade, &d.IDLote, &d.Esperado, &d.CustoUnitario, &d.Nome); err != nil {
+			return nil, err
+		}
+		if len(produto) > 0 && produto[0].IDProduto != d.IDProduto {
+			distribuir()
+		}
+		contado = quantidade
+		produto = append(produto, d)
+		saldos = append(saldos, SaldoLote{IDLote: d.IDLote, Disponivel: d.Esperado})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(produto) > 0 {
+		distribuir()
+	}
+
+	sort.SliceStable(itens, func(a, b int) bool {
+		if itens[a].Nome != itens[b].Nome {
+			return itens[a].Nome < itens[b].Nome
+		}
+		return itens[a].IDLote < itens[b].IDLote
+	})
+	return itens, nil
+}
+
+// Divergências gravadas no fechamento
+func divergenciasFechamento(ctx context.Context, q querier, id int64) ([]model.DivergenciaInventario, error) {
+	query := `
+		SELECT d.id_lote, l.id_produto, p.nome, d.esperado, d.contado, l.preco_unitario
+		FROM divergencia_inventario d
+		JOIN Lote l ON l.id_lote = d.id_lote
+		JOIN Produto p ON p.id_produto = l.id_produto
+		WHERE d.id_inventario = $1
+		ORDER BY p.nome, d.id_lote`
+	rows, err := q.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	itens := make([]model.DivergenciaInventario, 0)
+	for rows.Next() {
+		var d model.DivergenciaInventario
+		if err := rows.Scan(&d.IDLote, &d.IDProduto, &d.Nome, &d.Esperado, &d.Contado, &d.CustoUnitario); err != nil {
+			return nil, err
+		}
+		itens = append(itens, d)
+	}
+	return itens, rows.Err()
+}
+
+// Relatório de divergências. Com o inventário aberto é uma prévia contra o estoque
+// atual, depois do fechamento usa o estoque esperado gravado no fechamento.
+func (s *Store) GetRelatorio(ctx context.Context, id int64) (*model.RelatorioInventario, error) {
+	var status string
+	err := s.db.QueryRowContext(ctx, "SELECT status::text FROM inventario WHERE id_inventario = $1", id).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+
+	var itens []model.DivergenciaInventario
+	if status == model.InventarioAberto {
+		itens, err = calcularDivergencias(ctx, s.db, id)
+	} else {
+		itens, err = divergenciasFechamento(ctx, s.db, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rel := Resumir(id, status, itens)
+	return &rel, nil
+}
+
+// Encerra a contagem e grava o estoque esperado de cada lote contado
+func (s *Store) Fechar(ctx context.Context, id int64) (*model.RelatorioInventario, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	status, err := travarInventario(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status != model.InventarioAberto {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrTransicaoInvalida, status, model.InventarioFechado)
+	}
+
+	itens, err := calcularDivergencias(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(itens) == 0 {
+		return nil, ErrSemContagens
+	}
+
+	insert := "INSERT INTO divergencia_inventario (id_inventario, id_lote, esperado, contado) VALUES ($1, $2, $3, $4)"
+	for _, d := range itens {
+		if _, err := tx.ExecContext(ctx, insert, id, d.IDLote, d.Esperado, d.Contado); err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE inventario SET status = 'fechado', data_fechamento = now() WHERE id_inventario = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	rel := Resumir(id, model.InventarioFechado, itens)
+	return &rel, nil
+}
+
+// Aprova um inventário fechado lançando as diferenças como ajustes de estoque
+// com motivo inventario em nome do gerente.
+func (s *Store) Aprovar(ctx context.Context, id, idAprovador int64) (*model.RelatorioInventario, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	status, err := travarInventario(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status != model.InventarioFechado {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrTransicaoInvalida, status, model.InventarioAprovado)
+	}
+
+	var tipo string
+	err = tx.QueryRowContext(ctx, "SELECT tipo::text FROM Funcionario WHERE id_funcionario = $1", idAprovador).Scan(&tipo)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: funcionário %d não encontrado", ErrAprovadorInvalido, idAprovador)
+		}
+		return nil, err
+	}
+	if tipo != model.FuncionarioGerente {
+		return nil, ErrAprovadorInvalido
+	}
+
+	itens, err := divergenciasFechamento(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ajuste positivo retira unidades do estoque, negativo devolve as que sobraram
+	query := `
+		INSERT INTO ajuste_estoque (id_lote, id_funcionario, quantidade, motivo, observacao)
+		VALUES ($1, $2, $3, 'inventario', $4)`
+	observacao := fmt.Sprintf("Inventário %d", id)
+	for _, d := range itens {
+		if d.Contado == d.Esperado {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, query, d.IDLote, idAprovador, d.Esperado-d.Contado, observacao); err != nil {
+			return nil, err
+		}
+		if err := estoque.ConferirLote(ctx, tx, d.IDLote); err != nil {
+			return nil, err
+		}
+	}
+
+	query = "UPDATE inventario SET status = 'aprovado', id_aprovador = $2, data_aprovacao = now() WHERE id_inventario = $1"
+	if _, err := tx.ExecContext(ctx, query, id, idAprovador); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	rel := Resumir(id, model.InventarioAprovado, itens)
+	return &rel, nil
+}
+
+// Cancela um inventário aberto ou fechado sem lançar ajustes
+func (s *Store) Cancelar(ctx context.Context, id int64) (*model.Inventario, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	status, err := travarInventario(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status != model.InventarioAberto && status != model.InventarioFechado {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrTransicaoInvalida, status, model.InventarioCancelado)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE inventario SET status = 'cancelado' WHERE id_inventario = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	inv, err := getInventario(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	return inv, tx.Commit()
+}
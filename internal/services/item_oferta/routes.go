@@ -53,17 +53,17 @@ func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
 
 	filters, err := NewItemOfertaFilter(r.URL.Query())
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	itemOfertas, err := h.store.GetAll(ctx, filters)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	err = util.WriteJSON(w, http.StatusOK, itemOfertas)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
@@ -88,7 +88,7 @@ func (h *Handler) getAllByItemID(w http.ResponseWriter, r *http.Request) {
 
 	itens, err := h.store.GetAllByItemID(ctx, id)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	if itens == nil {
@@ -98,7 +98,7 @@ func (h *Handler) getAllByItemID(w http.ResponseWriter, r *http.Request) {
 
 	err = util.WriteJSON(w, http.StatusOK, itens)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
@@ -123,7 +123,7 @@ func (h *Handler) getAllByOfertaID(w http.ResponseWriter, r *http.Request) {
 
 	itens, err := h.store.GetAllByOfertaID(ctx, id)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	if itens == nil {
@@ -133,7 +133,7 @@ func (h *Handler) getAllByOfertaID(w http.ResponseWriter, r *http.Request) {
 
 	err = util.WriteJSON(w, http.StatusOK, itens)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
@@ -160,10 +160,15 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToItemOferta()
 	err = h.store.Create(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -194,7 +199,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 	// Chame o novo método do store
 	itemOferta, err := h.store.GetByComposedID(ctx, id_produto, id_oferta)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	if itemOferta == nil {
@@ -235,12 +240,17 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToItemOferta()
 	model.IDProduto = id_produto
 	model.IDOferta = id_oferta
 	err = h.store.Update(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -270,7 +280,7 @@ func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
 	// Chame o método Delete com os dois IDs
 	model, err := h.store.Delete(ctx, id_produto, id_oferta)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -0,0 +1,54 @@
+package item_venda
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/types"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var ErrValorInvalido = errors.New("valor_unitario deve ser maior que zero")
+
+// Decide o valor unitário de um item a partir do preço de catálogo.
+// Sem valor informado usa o catálogo. Um valor diferente do catálogo só é
+// aceito com motivo, e nesse caso é devolvido o override a ser registrado.
+func ResolverPreco(precoCatalogo float64, informado *float64, motivo *string) (float64, *model.PrecoOverride, error) {
+	if informado == nil || centavos(*informado) == centavos(precoCatalogo) {
+		return precoCatalogo, nil, nil
+	}
+	if *informado <= 0 {
+		return 0, nil, ErrValorInvalido
+	}
+	if motivo == nil || strings.TrimSpace(*motivo) == "" {
+		return 0, nil, fmt.Errorf("%w: catálogo R$ %.2f, informado R$ %.2f. Envie motivo_preco para confirmar", types.ErrPrecoDivergente, precoCatalogo, *informado)
+	}
+
+	override := model.PrecoOverride{
+		PrecoCatalogo: precoCatalogo,
+		ValorUnitario: *informado,
+		Motivo:        strings.TrimSpace(*motivo),
+	}
+	return *informado, &override, nil
+}
+
+func centavos(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+// Grava o override de preço do item, se houver, na mesma transação do item.
+func registrarOverride(ctx context.Context, tx *sql.Tx, item *model.ItemVenda) error {
+	if item.PrecoOverride == nil {
+		return nil
+	}
+	o := item.PrecoOverride
+	o.IDItemVenda = item.IDItemVenda
+	query := `
+		INSERT INTO preco_override (id_item_venda, preco_catalogo, valor_unitario, motivo)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id_preco_override, data_hora;`
+	return tx.QueryRowContext(ctx, query, o.IDItemVenda, o.PrecoCatalogo, o.ValorUnitario, o.Motivo).Scan(&o.IDPrecoOverride, &o.DataHora)
+}
@@ -0,0 +1,42 @@
+package item_venda
+
+import (
+	"edna/internal/types"
+	"errors"
+	"testing"
+)
+
+func TestResolverPreco(t *testing.T) {
+	motivo := "cortesia da casa"
+	vazio := "  "
+	igual := 12.50
+	menor := 0.01
+
+	valor, override, err := ResolverPreco(12.50, nil, nil)
+	if err != nil || valor != 12.50 || override != nil {
+		t.Fatalf("expected catalog price without override, got %v %v %v", valor, override, err)
+	}
+
+	valor, override, err = ResolverPreco(12.50, &igual, nil)
+	if err != nil || valor != 12.50 || override != nil {
+		t.Fatalf("expected equal price to be accepted, got %v %v %v", valor, override, err)
+	}
+
+	_, _, err = ResolverPreco(12.50, &menor, nil)
+	if !errors.Is(err, types.ErrPrecoDivergente) {
+		t.Fatalf("expected ErrPrecoDivergente without motivo, got %v", err)
+	}
+
+	_, _, err = ResolverPreco(12.50, &menor, &vazio)
+	if !errors.Is(err, types.ErrPrecoDivergente) {
+		t.Fatalf("expected ErrPrecoDivergente with blank motivo, got %v", err)
+	}
+
+	valor, override, err = ResolverPreco(12.50, &menor, &motivo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if valor != 0.01 || override == nil || override.PrecoCatalogo != 12.50 || override.Motivo != motivo {
+		t.Fatalf("expected override to be recorded, got %v %+v", valor, override)
+	}
+}
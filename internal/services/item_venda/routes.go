@@ -3,14 +3,23 @@ package item_venda
 import (
 	"context"
 	"edna/internal/model"
+	"edna/internal/services/desconto"
 	"edna/internal/types"
 	"edna/internal/util"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
 type Handler struct {
-	store ItemVendaStore
+	store  ItemVendaStore
+	precos PrecoStore
+}
+
+// Fonte dos preços de catálogo, implementada pelo store de produto.
+type PrecoStore interface {
+	GetPrecoVenda(ctx context.Context, idProduto int64) (float64, error)
+	GetPrecoVendaByLote(ctx context.Context, idLote int64) (float64, error)
 }
 
 type ItemVendaStore interface {
@@ -19,15 +28,17 @@ type ItemVendaStore interface {
 	GetByID(ctx context.Context, id int64) (*model.ItemVenda, error)
 	Update(ctx context.Context, props *model.ItemVenda) error
 	Delete(ctx context.Context, id int64) (*model.ItemVenda, error)
+	CreateByProduto(ctx context.Context, props *model.ItemVendaProdutoCreate, valorUnitario float64, override *model.PrecoOverride) ([]model.ItemVenda, error)
 }
 
-func NewHandler(store ItemVendaStore) *Handler {
-	return &Handler{store}
+func NewHandler(store ItemVendaStore, precos PrecoStore) *Handler {
+	return &Handler{store, precos}
 }
 
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /item_venda", h.getAll)
 	mux.HandleFunc("POST /item_venda", h.create)
+	mux.HandleFunc("POST /item_venda/produto", h.createByProduto)
 	mux.HandleFunc("GET /item_venda/{id}", h.fetch)
 	mux.HandleFunc("PUT /item_venda/{id}", h.update)
 	mux.HandleFunc("DELETE /item_venda/{id}", h.delete)
@@ -39,17 +50,17 @@ func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
 
 	filters, err := NewItemVendaFilter(r.URL.Query())
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	itensVenda, err := h.store.GetAll(ctx, filters)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	err = util.WriteJSON(w, http.StatusOK, itensVenda)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
@@ -69,16 +80,126 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	model := payload.ToItemVenda()
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	model, ok := h.resolverItem(ctx, w, payload)
+	if !ok {
+		return
+	}
 	err = h.store.Create(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		if errors.Is(err, types.ErrNotFound) {
+			util.ErrorJSON(w, "Venda not found.", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, types.ErrEstoqueInsuficiente) || errors.Is(err, types.ErrLimiteCredito) || errors.Is(err, desconto.ErrVendaFechada) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
 	util.WriteJSON(w, http.StatusCreated, model)
 }
 
+// @Summary Create ItemVenda by Produto
+// @Description Allocates the quantity across the product's lots in validity order (FIFO), one item_venda per lot used.
+// @Description Items of a sale that is paid, has payments or has redeemed points cannot change (409).
+// @Tags ItemVenda
+// @Accept json
+// @Produce json
+// @Param item body model.ItemVendaProdutoCreate true "ItemVenda by produto payload"
+// @Success 201 {array} model.ItemVenda
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /item_venda/produto [post]
+func (h *Handler) createByProduto(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	if r.Body == nil {
+		util.ErrorJSON(w, "No body in the request", http.StatusBadRequest)
+		return
+	}
+
+	var payload model.ItemVendaProdutoCreate
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	catalogo, err := h.precos.GetPrecoVenda(ctx, payload.IDProduto)
+	if err != nil {
+		WritePrecoError(w, err)
+		return
+	}
+	valor, override, err := ResolverPreco(catalogo, payload.ValorUnitario, payload.MotivoPreco)
+	if err != nil {
+		WritePrecoError(w, err)
+		return
+	}
+
+	itens, err := h.store.CreateByProduto(ctx, &payload, valor, override)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			util.ErrorJSON(w, "Venda not found.", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, types.ErrEstoqueInsuficiente) || errors.Is(err, types.ErrLimiteCredito) || errors.Is(err, desconto.ErrVendaFechada) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, itens)
+}
+
+// Resolve o preço do item pelo catálogo do produto do lote.
+// Em caso de erro escreve a resposta e retorna false.
+func (h *Handler) resolverItem(ctx context.Context, w http.ResponseWriter, payload model.ItemVendaCreate) (model.ItemVenda, bool) {
+	catalogo, err := h.precos.GetPrecoVendaByLote(ctx, payload.IDLote)
+	if err != nil {
+		WritePrecoError(w, err)
+		return model.ItemVenda{}, false
+	}
+	valor, override, err := ResolverPreco(catalogo, payload.ValorUnitario, payload.MotivoPreco)
+	if err != nil {
+		WritePrecoError(w, err)
+		return model.ItemVenda{}, false
+	}
+	item := payload.ToItemVenda(valor)
+	item.PrecoOverride = override
+	return item, true
+}
+
+// Escreve a resposta de erro para falhas na resolução de preço.
+func WritePrecoError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		util.ErrorJSON(w, "Produto sem preço de venda no catálogo.", http.StatusUnprocessableEntity)
+	case errors.Is(err, types.ErrPrecoDivergente):
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+	case errors.Is(err, ErrValorInvalido):
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+	default:
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
 func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
 	defer cancel()
@@ -95,7 +216,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "ItemVenda not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -122,7 +243,15 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	model := payload.ToItemVenda()
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	model, ok := h.resolverItem(ctx, w, payload)
+	if !ok {
+		return
+	}
 	model.IDItemVenda = id
 	err = h.store.Update(ctx, &model)
 	if err != nil {
@@ -130,7 +259,11 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "ItemVenda not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		if errors.Is(err, types.ErrEstoqueInsuficiente) || errors.Is(err, types.ErrLimiteCredito) || errors.Is(err, desconto.ErrVendaFechada) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -153,7 +286,11 @@ func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "ItemVenda not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		if errors.Is(err, desconto.ErrVendaFechada) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
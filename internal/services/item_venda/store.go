@@ -4,11 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"edna/internal/model"
+	"edna/internal/services/cliente"
+	"edna/internal/services/desconto"
+	"edna/internal/services/estoque"
+	"edna/internal/services/receita"
 	"edna/internal/types"
 	"edna/internal/util"
+	"errors"
+	"fmt"
+	"slices"
 	"time"
 )
 
+var ErrItemReceita = errors.New("Item de produto com receita não tem lote, remova e lance o item novamente")
+
 type Store struct {
 	db *sql.DB
 }
@@ -20,23 +29,10 @@ func NewStore(db *sql.DB) *Store {
 // Encontra um ID de Lote adequado para um produto.
 func (s *Store) FindAvailableLote(ctx context.Context, idProduto int64, quantidade int64) (int64, error) {
 	query := `
-		SELECT
-			l.id_lote
-		FROM
-			Lote l
-		LEFT JOIN (
-			-- Subconsulta para calcular a quantidade já vendida por lote
-			SELECT id_lote, SUM(quantidade) as total_vendido
-			FROM item_venda
-			GROUP BY id_lote
-		) iv ON l.id_lote = iv.id_lote
-		WHERE
-			l.id_produto = $1
-			AND (l.validade IS NULL OR l.validade > CURRENT_DATE)
-			-- Calcula o estoque restante e verifica se é suficiente
-			AND (l.quantidade_inicial - l.estragados - COALESCE(iv.total_vendido, 0)) >= $2
-		ORDER BY
-			l.validade ASC -- Estratégia FIFO: Pega o lote que vence primeiro
+		SELECT id_lote
+		FROM estoque_lote
+		WHERE id_produto = $1 AND NOT vencido AND disponivel >= $2
+		ORDER BY validade ASC, id_lote ASC -- Estratégia FIFO: Pega o lote que vence primeiro
 		LIMIT 1;
 	`
 	var idLote int64
@@ -50,6 +46,121 @@ func (s *Store) FindAvailableLote(ctx context.Context, idProduto int64, quantida
 	return idLote, nil
 }
 
+const insertItem = "INSERT INTO item_venda (id_venda, id_lote, id_produto, quantidade, valor_unitario) VALUES ($1, $2, $3, $4, $5) RETURNING id_item_venda;"
+
+// Distribui a quantidade entre os lotes válidos do produto, do que vence primeiro
+// ao último (FIFO), criando um item_venda para cada lote usado.
+// Produtos com receita geram um único item sem lote e consomem os lotes dos ingredientes.
+// Deve ser chamada dentro de uma transação, os lotes ficam bloqueados até o commit
+// para que duas vendas simultâneas não consumam o mesmo estoque.
+func AlocarLotes(ctx context.Context, tx *sql.Tx, idVenda, idProduto, quantidade int64, valorUnitario float64, override *model.PrecoOverride) ([]model.ItemVenda, error) {
+	if quantidade <= 0 {
+		return nil, errors.New("quantidade deve ser maior que zero")
+	}
+
+	ingredientes, err := receita.Ingredientes(ctx, tx, idProduto)
+	if err != nil {
+		return nil, err
+	}
+	if len(ingredientes) > 0 {
+		item, err := alocarReceita(ctx, tx, idVenda, idProduto, quantidade, valorUnitario, override, ingredientes)
+		if err != nil {
+			return nil, err
+		}
+		return []model.ItemVenda{*item}, nil
+	}
+
+	lotes, err := estoque.LotesDisponiveis(ctx, tx, idProduto)
+	if err != nil {
+		return nil, err
+	}
+
+	itens := make([]model.ItemVenda, 0, 1)
+	restante := quantidade
+	for _, l := range lotes {
+		if restante == 0 {
+			break
+		}
+		idLote := l.IDLote
+		item := model.ItemVenda{
+			IDVenda:       idVenda,
+			IDLote:        &idLote,
+			IDProduto:     idProduto,
+			Quantidade:    min(restante, l.Disponivel),
+			ValorUnitario: valorUnitario,
+		}
+		err := tx.QueryRowContext(ctx, insertItem, item.IDVenda, item.IDLote, item.IDProduto, item.Quantidade, item.ValorUnitario).Scan(&item.IDItemVenda)
+		if err != nil {
+			return nil, err
+		}
+		if override != nil {
+			o := *override
+			item.PrecoOverride = &o
+			if err := registrarOverride(ctx, tx, &item); err != nil {
+				return nil, err
+			}
+		}
+		itens = append(itens, item)
+		restante -= item.Quantidade
+	}
+
+	if restante > 0 {
+		return nil, fmt.Errorf("%w: produto %d, faltam %d unidades", types.ErrEstoqueInsuficiente, idProduto, restante)
+	}
+	return itens, nil
+}
+
+// Item sem lote de um produto com receita, com os ingredientes retirados dos lotes.
+func alocarReceita(ctx context.Context, tx *sql.Tx, idVenda, idProduto, quantidade int64, valorUnitario float64, override *model.PrecoOverride, ingredientes []model.ItemReceita) (*model.ItemVenda, error) {
+	item := model.ItemVenda{
+		IDVenda:       idVenda,
+		IDProduto:     idProduto,
+		Quantidade:    quantidade,
+		ValorUnitario: valorUnitario,
+	}
+	err := tx.QueryRowContext(ctx, insertItem, item.IDVenda, nil, item.IDProduto, item.Quantidade, item.ValorUnitario).Scan(&item.IDItemVenda)
+	if err != nil {
+		return nil, err
+	}
+	item.Consumos, err = receita.Consumir(ctx, tx, item.IDItemVenda, ingredientes, quantidade)
+	if err != nil {
+		return nil, err
+	}
+	if override != nil {
+		o := *override
+		item.PrecoOverride = &o
+		if err := registrarOverride(ctx, tx, &item); err != nil {
+			return nil, err
+		}
+	}
+	return &item, nil
+}
+
+// Cria os itens de venda a partir do produto, dividindo entre lotes se necessário.
+func (s *Store) CreateByProduto(ctx context.Context, props *model.ItemVendaProdutoCreate, valorUnitario float64, override *model.PrecoOverride) ([]model.ItemVenda, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := desconto.TravarVendaAberta(ctx, tx, props.IDVenda); err != nil {
+		return nil, err
+	}
+	itens, err := AlocarLotes(ctx, tx, props.IDVenda, props.IDProduto, props.Quantidade, valorUnitario, override)
+	if err != nil {
+		return nil, err
+	}
+	if err := cliente.ConferirLimiteVenda(ctx, tx, props.IDVenda); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return itens, nil
+}
+
 // NOVO TIPO: Para o resultado da consulta com JOIN.
 type ItemVendaDetail struct {
 	model.ItemVenda
@@ -62,14 +173,14 @@ type ItemVendaDetail struct {
 func (s *Store) GetItemsByVendaID(ctx context.Context, idVenda int64) ([]ItemVendaDetail, error) {
 	query := `
 		SELECT
-			iv.id_item_venda, iv.id_venda, iv.id_lote, iv.quantidade, iv.valor_unitario,
+			iv.id_item_venda, iv.id_venda, iv.id_lote, iv.id_produto, iv.quantidade, iv.valor_unitario,
 			p.nome, p.marca, l.validade
 		FROM
 			item_venda iv
-		JOIN
+		LEFT JOIN
 			Lote l ON iv.id_lote = l.id_lote
 		JOIN
-			Produto p ON l.id_produto = p.id_produto
+			Produto p ON iv.id_produto = p.id_produto
 		WHERE
 			iv.id_venda = $1;
 	`
@@ -83,7 +194,7 @@ func (s *Store) GetItemsByVendaID(ctx context.Context, idVenda int64) ([]ItemVen
 	for rows.Next() {
 		var i ItemVendaDetail
 		err := rows.Scan(
-			&i.IDItemVenda, &i.IDVenda, &i.IDLote, &i.Quantidade, &i.ValorUnitario,
+			&i.IDItemVenda, &i.IDVenda, &i.IDLote, &i.IDProduto, &i.Quantidade, &i.ValorUnitario,
 			&i.NomeProduto, &i.Marca, &i.Validade,
 		)
 		if err != nil {
@@ -95,7 +206,7 @@ func (s *Store) GetItemsByVendaID(ctx context.Context, idVenda int64) ([]ItemVen
 }
 
 func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.ItemVenda, error) {
-	query := "SELECT id_item_venda, id_venda, id_lote, quantidade, valor_unitario FROM item_venda AS IV"
+	query := "SELECT id_item_venda, id_venda, id_lote, id_produto, quantidade, valor_unitario FROM item_venda AS IV"
 
 	rows, err := util.QueryRowsWithFilter(s.db, ctx, query, &filter, "IV")
 	if err != nil {
@@ -105,7 +216,7 @@ func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.ItemVen
 	itensVenda := make([]model.ItemVenda, 0)
 	for rows.Next() {
 		var iv model.ItemVenda
-		err = rows.Scan(&iv.IDItemVenda, &iv.IDVenda, &iv.IDLote, &iv.Quantidade, &iv.ValorUnitario)
+		err = rows.Scan(&iv.IDItemVenda, &iv.IDVenda, &iv.IDLote, &iv.IDProduto, &iv.Quantidade, &iv.ValorUnitario)
 		if err != nil {
 			return nil, err
 		}
@@ -115,11 +226,11 @@ func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.ItemVen
 }
 
 func (s *Store) GetByID(ctx context.Context, id int64) (*model.ItemVenda, error) {
-	query := "SELECT id_item_venda, id_venda, id_lote, quantidade, valor_unitario FROM item_venda WHERE id_item_venda = $1;"
+	query := "SELECT id_item_venda, id_venda, id_lote, id_produto, quantidade, valor_unitario FROM item_venda WHERE id_item_venda = $1;"
 	row := s.db.QueryRowContext(ctx, query, id)
 
 	var iv model.ItemVenda
-	err := row.Scan(&iv.IDItemVenda, &iv.IDVenda, &iv.IDLote, &iv.Quantidade, &iv.ValorUnitario)
+	err := row.Scan(&iv.IDItemVenda, &iv.IDVenda, &iv.IDLote, &iv.IDProduto, &iv.Quantidade, &iv.ValorUnitario)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, types.ErrNotFound
@@ -130,37 +241,131 @@ func (s *Store) GetByID(ctx context.Context, id int64) (*model.ItemVenda, error)
 }
 
 func (s *Store) Create(ctx context.Context, props *model.ItemVenda) error {
-	query := "INSERT INTO item_venda (id_venda, id_lote, quantidade, valor_unitario) VALUES ($1, $2, $3, $4) RETURNING id_item_venda;"
-	res := s.db.QueryRowContext(ctx, query, props.IDVenda, props.IDLote, props.Quantidade, props.ValorUnitario)
-	return res.Scan(&props.IDItemVenda)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if props.IDLote == nil {
+		return ErrItemReceita
+	}
+	if _, err := desconto.TravarVendaAberta(ctx, tx, props.IDVenda); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO item_venda (id_venda, id_lote, id_produto, quantidade, valor_unitario)
+		SELECT $1, id_lote, id_produto, $3, $4 FROM Lote WHERE id_lote = $2
+		RETURNING id_item_venda, id_produto;`
+	res := tx.QueryRowContext(ctx, query, props.IDVenda, *props.IDLote, props.Quantidade, props.ValorUnitario)
+	if err := res.Scan(&props.IDItemVenda, &props.IDProduto); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("lote %d não encontrado", *props.IDLote)
+		}
+		return err
+	}
+	if err := estoque.ConferirLote(ctx, tx, *props.IDLote); err != nil {
+		return err
+	}
+	if err := cliente.ConferirLimiteVenda(ctx, tx, props.IDVenda); err != nil {
+		return err
+	}
+	if err := registrarOverride(ctx, tx, props); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *Store) Update(ctx context.Context, props *model.ItemVenda) error {
-	query := "UPDATE item_venda SET id_venda = $1, id_lote = $2, quantidade = $3, valor_unitario = $4 WHERE id_item_venda = $5;"
-	res, err := s.db.ExecContext(ctx, query, props.IDVenda, props.IDLote, props.Quantidade, props.ValorUnitario, props.IDItemVenda)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	rowsAffected, err := res.RowsAffected()
+	defer tx.Rollback()
+
+	if props.IDLote == nil {
+		return ErrItemReceita
+	}
+	// Itens de receita não podem virar itens de lote, os ingredientes já foram consumidos
+	var atual sql.NullInt64
+	var idVendaAtual int64
+	err = tx.QueryRowContext(ctx, "SELECT id_lote, id_venda FROM item_venda WHERE id_item_venda = $1 FOR UPDATE", props.IDItemVenda).Scan(&atual, &idVendaAtual)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.ErrNotFound
+		}
 		return err
 	}
-	if rowsAffected == 0 {
-		return types.ErrNotFound
+	if !atual.Valid {
+		return ErrItemReceita
 	}
-	return nil
+	if err := travarVendas(ctx, tx, idVendaAtual, props.IDVenda); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE item_venda SET id_venda = $1, id_lote = l.id_lote, id_produto = l.id_produto, quantidade = $3, valor_unitario = $4
+		FROM Lote l
+		WHERE l.id_lote = $2 AND id_item_venda = $5
+		RETURNING item_venda.id_produto;`
+	err = tx.QueryRowContext(ctx, query, props.IDVenda, *props.IDLote, props.Quantidade, props.ValorUnitario, props.IDItemVenda).Scan(&props.IDProduto)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("lote %d não encontrado", *props.IDLote)
+		}
+		return err
+	}
+	if err := estoque.ConferirLote(ctx, tx, *props.IDLote); err != nil {
+		return err
+	}
+	if err := cliente.ConferirLimiteVenda(ctx, tx, props.IDVenda); err != nil {
+		return err
+	}
+	if err := registrarOverride(ctx, tx, props); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *Store) Delete(ctx context.Context, id int64) (*model.ItemVenda, error) {
-	query := "DELETE FROM item_venda WHERE id_item_venda = $1 RETURNING id_item_venda, id_venda, id_lote, quantidade, valor_unitario;"
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var idVenda int64
+	if err := tx.QueryRowContext(ctx, "SELECT id_venda FROM item_venda WHERE id_item_venda = $1", id).Scan(&idVenda); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	if _, err := desconto.TravarVendaAberta(ctx, tx, idVenda); err != nil {
+		return nil, err
+	}
+
+	// Os ingredientes consumidos por itens de receita voltam ao estoque (consumo_receita em cascata)
+	query := "DELETE FROM item_venda WHERE id_item_venda = $1 RETURNING id_item_venda, id_venda, id_lote, id_produto, quantidade, valor_unitario;"
 	var iv model.ItemVenda
-	row := s.db.QueryRowContext(ctx, query, id)
-	err := row.Scan(&iv.IDItemVenda, &iv.IDVenda, &iv.IDLote, &iv.Quantidade, &iv.ValorUnitario)
+	row := tx.QueryRowContext(ctx, query, id)
+	err = row.Scan(&iv.IDItemVenda, &iv.IDVenda, &iv.IDLote, &iv.IDProduto, &iv.Quantidade, &iv.ValorUnitario)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, types.ErrNotFound
 		}
 		return nil, err
 	}
-	return &iv, nil
+	return &iv, tx.Commit()
+}
+
+// Trava as vendas de origem e destino de um item, na ordem do id para não
+// travar em ciclo. Venda quitada ou com pagamentos não muda de itens.
+func travarVendas(ctx context.Context, tx *sql.Tx, idVendaAtual, idVenda int64) error {
+	for _, id := range slices.Compact([]int64{min(idVendaAtual, idVenda), max(idVendaAtual, idVenda)}) {
+		if _, err := desconto.TravarVendaAberta(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
 }
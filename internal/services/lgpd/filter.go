@@ -0,0 +1,40 @@
+package lgpd
+
+import (
+	"edna/internal/util"
+	"net/url"
+)
+
+func NewSolicitacaoFilter(params url.Values) (util.Filter, error) {
+	var filter util.Filter
+	if err := filter.GetOffset(params); err != nil {
+		return filter, err
+	}
+
+	if err := filter.GetLimit(params); err != nil {
+		return filter, err
+	}
+
+	attrs := []string{"data_hora", "titular", "acao", "id_titular", "id_funcionario"}
+
+	if err := filter.GetSorts(params, attrs); err != nil {
+		return filter, err
+	}
+
+	for _, attr := range []string{"titular", "acao"} {
+		if err := filter.GetFilterStr(params, attr); err != nil {
+			return filter, err
+		}
+	}
+
+	for _, attr := range []string{"id_titular", "id_funcionario"} {
+		if err := filter.GetFilterInt(params, attr); err != nil {
+			return filter, err
+		}
+	}
+
+	if err := filter.GetFilterTime(params, "data_hora"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
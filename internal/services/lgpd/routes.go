@@ -0,0 +1,269 @@
+package lgpd
+
+import (
+	"context"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	store LGPDStore
+}
+
+type LGPDStore interface {
+	DadosCliente(ctx context.Context, id int64, idFuncionario *int64) (*model.DadosPessoaisCliente, error)
+	AnonimizarCliente(ctx context.Context, id int64, a model.AnonimizacaoCreate) (*model.SolicitacaoLGPD, error)
+	DadosFuncionario(ctx context.Context, id int64, idFuncionario *int64) (*model.DadosPessoaisFuncionario, error)
+	AnonimizarFuncionario(ctx context.Context, id int64, a model.AnonimizacaoCreate) (*model.SolicitacaoLGPD, error)
+	GetSolicitacoes(ctx context.Context, filter util.Filter) ([]model.SolicitacaoLGPD, error)
+}
+
+func NewHandler(store LGPDStore) *Handler {
+	return &Handler{store}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /clientes/{id}/dados-pessoais", h.fetchDadosCliente)
+	mux.HandleFunc("POST /clientes/{id}/anonimizar", h.anonimizarCliente)
+	mux.HandleFunc("GET /funcionarios/{id}/dados-pessoais", h.fetchDadosFuncionario)
+	mux.HandleFunc("POST /funcionarios/{id}/anonimizar", h.anonimizarFuncionario)
+	mux.HandleFunc("GET /lgpd/solicitacoes", h.getSolicitacoes)
+}
+
+// Funcionário opcional que atendeu a exportação, informado em `id_funcionario`
+func idFuncionarioParam(r *http.Request) (*int64, bool) {
+	v := r.URL.Query().Get("id_funcionario")
+	if v == "" {
+		return nil, true
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, false
+	}
+	return &id, true
+}
+
+// @Summary Export client personal data
+// @Description Everything held about the client: registration, sales with items and payments, loyalty points and previous data requests. The export itself is recorded.
+// @Tags LGPD
+// @Produce json
+// @Param id path int true "Cliente ID"
+// @Param id_funcionario query int false "Funcionario handling the request"
+// @Success 200 {object} model.DadosPessoaisCliente
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /clientes/{id}/dados-pessoais [get]
+func (h *Handler) fetchDadosCliente(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	idFuncionario, ok := idFuncionarioParam(r)
+	if !ok {
+		util.ErrorJSON(w, "id_funcionario must be a positive integer", http.StatusBadRequest)
+		return
+	}
+
+	dados, err := h.store.DadosCliente(ctx, id, idFuncionario)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Cliente not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, dados); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Anonymize client
+// @Description Scrubs name, CPF and birth date. Sales, payments and points stay linked to the client id for the financial reports. Requires a gerente and a reason, and is recorded.
+// @Tags LGPD
+// @Accept json
+// @Produce json
+// @Param id path int true "Cliente ID"
+// @Param anonimizacao body model.AnonimizacaoCreate true "Anonimizacao payload"
+// @Success 200 {object} model.SolicitacaoLGPD
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /clientes/{id}/anonimizar [post]
+func (h *Handler) anonimizarCliente(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.AnonimizacaoCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	solicitacao, err := h.store.AnonimizarCliente(ctx, id, payload)
+	if err != nil {
+		writeLGPDError(w, err, "Cliente not found.")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, solicitacao)
+}
+
+// @Summary Export employee personal data
+// @Description Everything held about the employee: registration, sales registered, cash register sessions and previous data requests. The export itself is recorded.
+// @Tags LGPD
+// @Produce json
+// @Param id path int true "Funcionario ID"
+// @Param id_funcionario query int false "Funcionario handling the request"
+// @Success 200 {object} model.DadosPessoaisFuncionario
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /funcionarios/{id}/dados-pessoais [get]
+func (h *Handler) fetchDadosFuncionario(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	idFuncionario, ok := idFuncionarioParam(r)
+	if !ok {
+		util.ErrorJSON(w, "id_funcionario must be a positive integer", http.StatusBadRequest)
+		return
+	}
+
+	dados, err := h.store.DadosFuncionario(ctx, id, idFuncionario)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Funcionario not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, dados); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Anonymize employee
+// @Description Scrubs name and CPF. Role, shift, salary and hiring date stay for payroll and for the sales and cash register records. Requires a gerente and a reason, and is recorded.
+// @Tags LGPD
+// @Accept json
+// @Produce json
+// @Param id path int true "Funcionario ID"
+// @Param anonimizacao body model.AnonimizacaoCreate true "Anonimizacao payload"
+// @Success 200 {object} model.SolicitacaoLGPD
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /funcionarios/{id}/anonimizar [post]
+func (h *Handler) anonimizarFuncionario(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.AnonimizacaoCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	solicitacao, err := h.store.AnonimizarFuncionario(ctx, id, payload)
+	if err != nil {
+		writeLGPDError(w, err, "Funcionario not found.")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, solicitacao)
+}
+
+// @Summary List personal data requests
+// @Description Audit trail of every personal data export and anonymization.
+// @Tags LGPD
+// @Produce json
+// @Param filter-titular query string false "Filter by titular (cliente, funcionario) using operators: eq, ne"
+// @Param filter-acao query string false "Filter by acao (exportacao, anonimizacao) using operators: eq, ne"
+// @Param filter-id_titular query int false "Filter by id_titular using operators: eq, ne, gt, lt"
+// @Param filter-id_funcionario query int false "Filter by id_funcionario using operators: eq, ne, gt, lt"
+// @Param filter-data_hora query string false "Filter by data_hora using operators: eq, ne, gt, lt"
+// @Param sort query string false "Sort fields: data_hora, titular, acao, id_titular, id_funcionario. Prefix with '-' for desc."
+// @Param offset query int false "Pagination offset (default 0)"
+// @Param limit query int false "Pagination limit"
+// @Success 200 {array} model.SolicitacaoLGPD
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /lgpd/solicitacoes [get]
+func (h *Handler) getSolicitacoes(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	filter, err := NewSolicitacaoFilter(r.URL.Query())
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	solicitacoes, err := h.store.GetSolicitacoes(ctx, filter)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := util.WriteJSON(w, http.StatusOK, solicitacoes); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeLGPDError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		util.ErrorJSON(w, notFound, http.StatusNotFound)
+	case errors.Is(err, ErrJaAnonimizado):
+		util.ErrorJSON(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrMotivoObrigatorio):
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrFuncionarioGerente):
+		util.ErrorJSON(w, err.Error(), http.StatusForbidden)
+	default:
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+	}
+}
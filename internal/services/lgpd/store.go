@@ -0,0 +1,386 @@
+package lgpd
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/services/totais"
+	"edna/internal/types"
+	"edna/internal/util"
+	"errors"
+	"time"
+)
+
+// Nome gravado no lugar do nome do titular anonimizado
+const NomeAnonimizado = "Anonimizado"
+
+var (
+	ErrJaAnonimizado      = errors.New("Titular já foi anonimizado")
+	ErrMotivoObrigatorio  = errors.New("Anonimização precisa de motivo")
+	ErrFuncionarioGerente = errors.New("Anonimização exige um funcionário do tipo gerente")
+)
+
+// *sql.DB e *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func registrar(ctx context.Context, tx *sql.Tx, s *model.SolicitacaoLGPD) error {
+	query := `
+		INSERT INTO solicitacao_lgpd (titular, id_titular, acao, id_funcionario, motivo)
+		VALUES ($1::titular_lgpd, $2, $3::acao_lgpd, $4, $5)
+		RETURNING id_solicitacao, data_hora`
+	return tx.QueryRowContext(ctx, query, s.Titular, s.IDTitular, s.Acao, s.IDFuncionario, s.Motivo).
+		Scan(&s.IDSolicitacao, &s.DataHora)
+}
+
+func solicitacoes(ctx context.Context, q querier, titular string, idTitular int64) ([]model.SolicitacaoLGPD, error) {
+	query := `
+		SELECT id_solicitacao, titular::text, id_titular, acao::text, id_funcionario, motivo, data_hora
+		FROM solicitacao_lgpd
+		WHERE titular = $1::titular_lgpd AND id_titular = $2
+		ORDER BY data_hora, id_solicitacao`
+	rows, err := q.QueryContext(ctx, query, titular, idTitular)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]model.SolicitacaoLGPD, 0)
+	for rows.Next() {
+		var s model.SolicitacaoLGPD
+		if err := rows.Scan(&s.IDSolicitacao, &s.Titular, &s.IDTitular, &s.Acao, &s.IDFuncionario, &s.Motivo, &s.DataHora); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+func conferirGerente(ctx context.Context, tx *sql.Tx, idFuncionario int64) error {
+	var tipo string
+	err := tx.QueryRowContext(ctx, "SELECT tipo::text FROM Funcionario WHERE id_funcionario = $1", idFuncionario).Scan(&tipo)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if tipo != model.FuncionarioGerente {
+		return ErrFuncionarioGerente
+	}
+	return nil
+}
+
+// Exporta o cadastro, as vendas com itens e pagamentos, o extrato de pontos e as
+// solicitações anteriores do cliente. A própria exportação fica registrada.
+func (s *Store) DadosCliente(ctx context.Context, id int64, idFuncionario *int64) (*model.DadosPessoaisCliente, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var d model.DadosPessoaisCliente
+	query := "SELECT id_cliente, nome, cpf, data_nascimento, limite_credito, anonimizado_em FROM Cliente WHERE id_cliente = $1"
+	err = tx.QueryRowContext(ctx, query, id).Scan(&d.Id, &d.Nome, &d.CPF, &d.DataNascimento, &d.LimiteCredito, &d.AnonimizadoEm)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+
+	if d.Vendas, err = vendasCliente(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	if d.Pontos, err = pontosCliente(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	exportacao := model.SolicitacaoLGPD{
+		Titular:       model.TitularCliente,
+		IDTitular:     id,
+		Acao:          model.AcaoExportacao,
+		IDFuncionario: idFuncionario,
+	}
+	if err := registrar(ctx, tx, &exportacao); err != nil {
+		return nil, err
+	}
+	if d.Solicitacoes, err = solicitacoes(ctx, tx, model.TitularCliente, id); err != nil {
+		return nil, err
+	}
+	return &d, tx.Commit()
+}
+
+func vendasCliente(ctx context.Context, tx *sql.Tx, idCliente int64) ([]model.VendaTitular, error) {
+	query := `
+		SELECT v.id_venda, v.id_cliente, v.id_funcionario, v.data_hora_venda, v.data_hora_pagamento,
+			COALESCE(v.tipo_pagamento::text, ''),
+			` + totais.Colunas + `
+		FROM Venda v
+		JOIN venda_totais vt ON vt.id_venda = v.id_venda
+		WHERE v.id_cliente = $1
+		ORDER BY v.data_hora_venda, v.id_venda`
+	rows, err := tx.QueryContext(ctx, query, idCliente)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vendas := make([]model.VendaTitular, 0)
+	pos := make(map[int64]int)
+	for rows.Next() {
+		v := model.VendaTitular{Itens: make([]model.ItemVenda, 0), Pagamentos: make([]model.Pagamento, 0)}
+		err := rows.Scan(&v.Id, &v.IdCliente, &v.IdFuncionario, &v.DataHoraVenda, &v.DataHoraPagamento, &v.TipoPagamento,
+			&v.TotalBruto, &v.TotalDesconto, &v.TotalLiquido, &v.TotalPago, &v.Restante, &v.StatusPagamento)
+		if err != nil {
+			return nil, err
+		}
+		pos[v.Id] = len(vendas)
+		vendas = append(vendas, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	query = `
+		SELECT iv.id_item_venda, iv.id_venda, iv.id_lote, iv.id_produto, iv.quantidade, iv.valor_unitario
+		FROM item_venda iv
+		JOIN Venda v ON v.id_venda = iv.id_venda
+		WHERE v.id_cliente = $1
+		ORDER BY iv.id_item_venda`
+	itens, err := tx.QueryContext(ctx, query, idCliente)
+	if err != nil {
+		return nil, err
+	}
+	defer itens.Close()
+	for itens.Next() {
+		var iv model.ItemVenda
+		if err := itens.Scan(&iv.IDItemVenda, &iv.IDVenda, &iv.IDLote, &iv.IDProduto, &iv.Quantidade, &iv.ValorUnitario); err != nil {
+			return nil, err
+		}
+		i := pos[iv.IDVenda]
+		vendas[i].Itens = append(vendas[i].Itens, iv)
+	}
+	if err := itens.Err(); err != nil {
+		return nil, err
+	}
+	itens.Close()
+
+	query = `
+		SELECT p.id_pagamento, p.id_venda, p.tipo_pagamento::text, p.valor, p.data_hora
+		FROM pagamento p
+		JOIN Venda v ON v.id_venda = p.id_venda
+		WHERE v.id_cliente = $1
+		ORDER BY p.data_hora, p.id_pagamento`
+	pagamentos, err := tx.QueryContext(ctx, query, idCliente)
+	if err != nil {
+		return nil, err
+	}
+	defer pagamentos.Close()
+	for pagamentos.Next() {
+		var p model.Pagamento
+		if err := pagamentos.Scan(&p.IDPagamento, &p.IDVenda, &p.TipoPagamento, &p.Valor, &p.DataHora); err != nil {
+			return nil, err
+		}
+		i := pos[p.IDVenda]
+		vendas[i].Pagamentos = append(vendas[i].Pagamentos, p)
+	}
+	return vendas, pagamentos.Err()
+}
+
+func pontosCliente(ctx context.Context, tx *sql.Tx, idCliente int64) ([]model.MovimentoPontos, error) {
+	query := `
+		SELECT id_movimento, id_cliente, id_venda, tipo::text, pontos, data_hora, validade, motivo, id_funcionario
+		FROM movimento_pontos
+		WHERE id_cliente = $1
+		ORDER BY data_hora, id_movimento`
+	rows, err := tx.QueryContext(ctx, query, idCliente)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movs := make([]model.MovimentoPontos, 0)
+	for rows.Next() {
+		var m model.MovimentoPontos
+		if err := rows.Scan(&m.IDMovimento, &m.IDCliente, &m.IDVenda, &m.Tipo, &m.Pontos, &m.DataHora, &m.Validade, &m.Motivo, &m.IDFuncionario); err != nil {
+			return nil, err
+		}
+		movs = append(movs, m)
+	}
+	return movs, rows.Err()
+}
+
+// Apaga nome, CPF e data de nascimento do cliente. Vendas, pagamentos e pontos
+// continuam ligados ao id para os relatórios financeiros.
+func (s *Store) AnonimizarCliente(ctx context.Context, id int64, a model.AnonimizacaoCreate) (*model.SolicitacaoLGPD, error) {
+	return s.anonimizar(ctx, model.TitularCliente, id, a, `
+		UPDATE Cliente SET nome = $2, cpf = NULL, data_nascimento = NULL, anonimizado_em = now()
+		WHERE id_cliente = $1`)
+}
+
+// Apaga nome e CPF do funcionário. Tipo, expediente, salário e contratação
+// continuam para a folha e para os registros de caixa e vendas.
+func (s *Store) AnonimizarFuncionario(ctx context.Context, id int64, a model.AnonimizacaoCreate) (*model.SolicitacaoLGPD, error) {
+	return s.anonimizar(ctx, model.TitularFuncionario, id, a, `
+		UPDATE Funcionario SET nome = $2, CPF = NULL, anonimizado_em = now()
+		WHERE id_funcionario = $1`)
+}
+
+func (s *Store) anonimizar(ctx context.Context, titular string, id int64, a model.AnonimizacaoCreate, update string) (*model.SolicitacaoLGPD, error) {
+	if a.Motivo == "" {
+		return nil, ErrMotivoObrigatorio
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := conferirGerente(ctx, tx, a.IDFuncionario); err != nil {
+		return nil, err
+	}
+
+	var anonimizadoEm *time.Time
+	query := "SELECT anonimizado_em FROM Cliente WHERE id_cliente = $1 FOR UPDATE"
+	if titular == model.TitularFuncionario {
+		query = "SELECT anonimizado_em FROM Funcionario WHERE id_funcionario = $1 FOR UPDATE"
+	}
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&anonimizadoEm); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	if anonimizadoEm != nil {
+		return nil, ErrJaAnonimizado
+	}
+
+	if _, err := tx.ExecContext(ctx, update, id, NomeAnonimizado); err != nil {
+		return nil, err
+	}
+
+	solicitacao := model.SolicitacaoLGPD{
+		Titular:       titular,
+		IDTitular:     id,
+		Acao:          model.AcaoAnonimizacao,
+		IDFuncionario: &a.IDFuncionario,
+		Motivo:        &a.Motivo,
+	}
+	if err := registrar(ctx, tx, &solicitacao); err != nil {
+		return nil, err
+	}
+	return &solicitacao, tx.Commit()
+}
+
+// Exporta o cadastro, as vendas registradas, as sessões de caixa e as
+// solicitações anteriores do funcionário. A própria exportação fica registrada.
+func (s *Store) DadosFuncionario(ctx context.Context, id int64, idFuncionario *int64) (*model.DadosPessoaisFuncionario, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var d model.DadosPessoaisFuncionario
+	query := `
+		SELECT id_funcionario, nome, COALESCE(CPF, ''), tipo::text, expediente::text, salario, data_contratacao, anonimizado_em
+		FROM Funcionario
+		WHERE id_funcionario = $1`
+	err = tx.QueryRowContext(ctx, query, id).
+		Scan(&d.Id, &d.Nome, &d.CPF, &d.Tipo, &d.Expediente, &d.Salario, &d.DataContratacao, &d.AnonimizadoEm)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+
+	d.VendasAtendidas = make([]int64, 0)
+	rows, err := tx.QueryContext(ctx, "SELECT id_venda FROM Venda WHERE id_funcionario = $1 ORDER BY data_hora_venda, id_venda", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var idVenda int64
+		if err := rows.Scan(&idVenda); err != nil {
+			return nil, err
+		}
+		d.VendasAtendidas = append(d.VendasAtendidas, idVenda)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	d.SessoesCaixa = make([]model.CaixaSessao, 0)
+	query = `
+		SELECT id_sessao, id_funcionario, expediente::text, valor_abertura, data_hora_abertura, data_hora_fechamento, valor_contado
+		FROM caixa_sessao
+		WHERE id_funcionario = $1
+		ORDER BY data_hora_abertura`
+	sessoes, err := tx.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer sessoes.Close()
+	for sessoes.Next() {
+		var c model.CaixaSessao
+		if err := sessoes.Scan(&c.IDSessao, &c.IDFuncionario, &c.Expediente, &c.ValorAbertura, &c.DataHoraAbertura, &c.DataHoraFechamento, &c.ValorContado); err != nil {
+			return nil, err
+		}
+		d.SessoesCaixa = append(d.SessoesCaixa, c)
+	}
+	if err := sessoes.Err(); err != nil {
+		return nil, err
+	}
+	sessoes.Close()
+
+	exportacao := model.SolicitacaoLGPD{
+		Titular:       model.TitularFuncionario,
+		IDTitular:     id,
+		Acao:          model.AcaoExportacao,
+		IDFuncionario: idFuncionario,
+	}
+	if err := registrar(ctx, tx, &exportacao); err != nil {
+		return nil, err
+	}
+	if d.Solicitacoes, err = solicitacoes(ctx, tx, model.TitularFuncionario, id); err != nil {
+		return nil, err
+	}
+	return &d, tx.Commit()
+}
+
+func (s *Store) GetSolicitacoes(ctx context.Context, filter util.Filter) ([]model.SolicitacaoLGPD, error) {
+	query := `
+		SELECT * FROM (
+			SELECT id_solicitacao, titular::text AS titular, id_titular, acao::text AS acao, id_funcionario, motivo, data_hora
+			FROM solicitacao_lgpd
+		) AS x`
+	rows, err := util.QueryRowsWithFilter(s.db, ctx, query, &filter, "x")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]model.SolicitacaoLGPD, 0)
+	for rows.Next() {
+		var s model.SolicitacaoLGPD
+		if err := rows.Scan(&s.IDSolicitacao, &s.Titular, &s.IDTitular, &s.Acao, &s.IDFuncionario, &s.Motivo, &s.DataHora); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
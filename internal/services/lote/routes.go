@@ -6,7 +6,9 @@ import (
 	"edna/internal/types"
 	"edna/internal/util"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 )
 
 type Handler struct {
@@ -27,6 +29,9 @@ type LoteStore interface {
 	GetByID(ctx context.Context, id int64) (*model.Lote, error)
 	Update(ctx context.Context, props *model.Lote) error
 	Delete(ctx context.Context, id int64) (*model.Lote, error)
+	GetVencendo(ctx context.Context, dias int) ([]model.LoteVencendo, error)
+	GetAjustes(ctx context.Context, idLote int64) ([]model.AjusteEstoque, error)
+	CreateAjuste(ctx context.Context, props *model.AjusteEstoque) error
 }
 
 func NewHandler(store LoteStore) *Handler {
@@ -37,10 +42,15 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /lotes", h.getAll)
 	mux.HandleFunc("GET /lotes/produtos/{id}", h.getAllByIDProduto)
 	mux.HandleFunc("GET /lotes/relatorio", h.getRelatorio)
+	mux.HandleFunc("GET /lotes/vencendo", h.getVencendo)
 	mux.HandleFunc("POST /lotes", h.create)
 	mux.HandleFunc("GET /lotes/{id}", h.fetch)
 	mux.HandleFunc("PUT /lotes/{id}", h.update)
 	mux.HandleFunc("DELETE /lotes/{id}", h.delete)
+	// GET /lotes/{id}/ajustes conflitaria com GET /lotes/produtos/{id} no ServeMux,
+	// então os sub-recursos de um lote passam por getSubrecurso
+	mux.HandleFunc("GET /lotes/{id}/{recurso}", h.getSubrecurso)
+	mux.HandleFunc("POST /lotes/{id}/ajustes", h.createAjuste)
 }
 
 // @Summary List Lotes
@@ -60,17 +70,17 @@ func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
 
 	filters, err := NewLoteFilter(r.URL.Query())
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	lotes, err := h.store.GetAll(ctx, filters)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	err = util.WriteJSON(w, http.StatusOK, lotes)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
@@ -99,10 +109,15 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToLote()
 	err = h.store.Create(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -134,7 +149,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "Lote not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -145,6 +160,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Update Lote
+// @Description Losses are not edited here, they are recorded with POST /lotes/{id}/ajustes.
 // @Tags Lote
 // @Accept json
 // @Produce json
@@ -152,6 +168,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 // @Param fornecedor body model.LoteCreate true "Lote payload"
 // @Success 200 {object} model.Lote
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
 // @Failure 422 {object} types.ErrorResponse
 // @Router /lotes/{id} [put]
 func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
@@ -171,6 +188,11 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToLote()
 	model.Id = id
 	err = h.store.Update(ctx, &model)
@@ -179,7 +201,11 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "Lote not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		if errors.Is(err, types.ErrEstoqueInsuficiente) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -210,7 +236,7 @@ func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "Lote not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -230,7 +256,7 @@ func (h *Handler) getRelatorio(w http.ResponseWriter, r *http.Request) {
 
 	model, err := h.store.GetRelatorio(ctx)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -261,9 +287,141 @@ func (h *Handler) getAllByIDProduto(w http.ResponseWriter, r *http.Request) {
 			util.ErrorJSON(w, "Lote not found.", http.StatusNotFound)
 			return
 		}
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
 	util.WriteJSON(w, http.StatusOK, model)
 }
+
+func (h *Handler) getSubrecurso(w http.ResponseWriter, r *http.Request) {
+	switch r.PathValue("recurso") {
+	case "ajustes":
+		h.getAjustes(w, r)
+	default:
+		util.ErrorJSON(w, "Not found.", http.StatusNotFound)
+	}
+}
+
+// @Summary List stock adjustments of a Lote
+// @Tags Lote
+// @Produce json
+// @Param id path int true "Lote ID"
+// @Success 200 {array} model.AjusteEstoque
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /lotes/{id}/ajustes [get]
+func (h *Handler) getAjustes(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ajustes, err := h.store.GetAjustes(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Lote not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, ajustes); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Record a stock adjustment for a Lote
+// @Description Quantity is the number of units removed from stock. Reasons: quebra, vencimento, furto, degustacao, inventario. Only inventario corrections may be negative (units found).
+// @Tags Lote
+// @Accept json
+// @Produce json
+// @Param id path int true "Lote ID"
+// @Param ajuste body model.AjusteEstoqueCreate true "Ajuste payload"
+// @Success 201 {object} model.AjusteEstoque
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /lotes/{id}/ajustes [post]
+func (h *Handler) createAjuste(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.AjusteEstoqueCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	ajuste := payload.ToAjusteEstoque(id)
+	if err := h.store.CreateAjuste(ctx, &ajuste); err != nil {
+		switch {
+		case err == types.ErrNotFound:
+			util.ErrorJSON(w, "Lote not found.", http.StatusNotFound)
+		case errors.Is(err, ErrAjusteInvalido):
+			util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, types.ErrEstoqueInsuficiente):
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+		default:
+			util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+		}
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, ajuste)
+}
+
+// Padrão de dias para GET /lotes/vencendo
+const DiasVencendoPadrao = 7
+
+// @Summary List Lotes close to expiry
+// @Description Lots with remaining stock that expire within the next N days, with product, supplier and remaining quantity.
+// @Tags Lote
+// @Produce json
+// @Param dias query int false "Days ahead (default 7)"
+// @Success 200 {array} model.LoteVencendo
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /lotes/vencendo [get]
+func (h *Handler) getVencendo(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	dias := DiasVencendoPadrao
+	if v := r.URL.Query().Get("dias"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 0 {
+			util.ErrorJSON(w, "dias must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		dias = d
+	}
+
+	lotes, err := h.store.GetVencendo(ctx, dias)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, lotes); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
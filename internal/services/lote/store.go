@@ -4,10 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"edna/internal/model"
+	"edna/internal/services/estoque"
 	"edna/internal/types"
 	"edna/internal/util"
+	"errors"
+	"fmt"
 )
 
+var ErrAjusteInvalido = errors.New("Ajuste inválido")
+
+var motivosAjuste = map[string]bool{
+	model.MotivoQuebra:     true,
+	model.MotivoVencimento: true,
+	model.MotivoFurto:      true,
+	model.MotivoDegustacao: true,
+	model.MotivoInventario: true,
+}
+
 type Store struct {
 	db *sql.DB
 }
@@ -16,8 +29,15 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db}
 }
 
+// Lote com estragados derivado dos ajustes de estoque
+const selectLote = `
+	SELECT l.id_lote, l.id_fornecedor, l.id_produto, l.data_fornecimento, l.validade, l.preco_unitario,
+		el.estragados, l.quantidade_inicial
+	FROM Lote l
+	JOIN estoque_lote el ON el.id_lote = l.id_lote`
+
 func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.Lote, error) {
-	query := "SELECT id_lote, id_fornecedor, id_produto, data_fornecimento, validade, preco_unitario, estragados, quantidade_inicial FROM Lote AS l"
+	query := "SELECT * FROM (" + selectLote + ") AS l"
 	rows, err := util.QueryRowsWithFilter(s.db, ctx, query, &filter, "l")
 	if err != nil {
 		return nil, err
@@ -36,7 +56,7 @@ func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.Lote, e
 }
 
 func (s *Store) GetByID(ctx context.Context, id int64) (*model.Lote, error) {
-	query := "SELECT id_lote, id_fornecedor, id_produto, data_fornecimento, validade, preco_unitario, estragados, quantidade_inicial FROM Lote WHERE id_lote = $1;"
+	query := selectLote + " WHERE l.id_lote = $1;"
 	row := s.db.QueryRowContext(ctx, query, id)
 
 	var l model.Lote
@@ -51,7 +71,7 @@ func (s *Store) GetByID(ctx context.Context, id int64) (*model.Lote, error) {
 }
 
 func (s *Store) GetAllByIDProduto(ctx context.Context, id int64) ([]model.Lote, error) {
-	query := "SELECT * FROM Lote WHERE id_produto = $1"
+	query := selectLote + " WHERE l.id_produto = $1"
 	row, err := s.db.QueryContext(ctx, query, id)
 	if err != nil {
 		return nil, err
@@ -72,21 +92,36 @@ func (s *Store) GetAllByIDProduto(ctx context.Context, id int64) ([]model.Lote,
 }
 
 func (s *Store) Create(ctx context.Context, props *model.Lote) error {
+	return Inserir(ctx, s.db, props)
+}
+
+// Insere o lote com *sql.DB ou dentro de uma transação (ex.: recebimento de pedido de compra)
+func Inserir(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}, props *model.Lote) error {
 	query := `
-		INSERT INTO Lote (id_fornecedor, id_produto, data_fornecimento, validade, preco_unitario, estragados, quantidade_inicial)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO Lote (id_fornecedor, id_produto, data_fornecimento, validade, preco_unitario, quantidade_inicial)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id_lote;`
-	res := s.db.QueryRowContext(ctx, query, props.IdFornecedor, props.IdProduto, props.DataFornecimento, props.Validade, props.PrecoUnitario, props.Estragados, props.QuantidadeInicial)
+	res := q.QueryRowContext(ctx, query, props.IdFornecedor, props.IdProduto, props.DataFornecimento, props.Validade, props.PrecoUnitario, props.QuantidadeInicial)
+	estragados := 0
+	props.Estragados = &estragados
 	return res.Scan(&props.Id)
 }
 
 func (s *Store) Update(ctx context.Context, props *model.Lote) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
 		UPDATE Lote SET
 		id_fornecedor = $1, id_produto = $2, data_fornecimento = $3, validade = $4,
-		preco_unitario = $5, estragados = $6, quantidade_inicial = $7
-		WHERE id_lote = $8;`
-	res, err := s.db.ExecContext(ctx, query, props.IdFornecedor, props.IdProduto, props.DataFornecimento, props.Validade, props.PrecoUnitario, props.Estragados, props.QuantidadeInicial, props.Id)
+		preco_unitario = $5, quantidade_inicial = $6
+		WHERE id_lote = $7;`
+	res, err := tx.ExecContext(ctx, query, props.IdFornecedor, props.IdProduto, props.DataFornecimento, props.Validade, props.PrecoUnitario, props.QuantidadeInicial, props.Id)
 	if err != nil {
 		return err
 	}
@@ -97,11 +132,24 @@ func (s *Store) Update(ctx context.Context, props *model.Lote) error {
 	if rowsAffected == 0 {
 		return types.ErrNotFound
 	}
-	return nil
+
+	// A quantidade inicial não pode ficar abaixo do que já saiu do lote
+	if err := estoque.ConferirLote(ctx, tx, props.Id); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, "SELECT estragados FROM estoque_lote WHERE id_lote = $1", props.Id).Scan(&props.Estragados)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *Store) Delete(ctx context.Context, id int64) (*model.Lote, error) {
-	query := "DELETE FROM Lote WHERE id_lote = $1 RETURNING id_lote, id_fornecedor, id_produto, data_fornecimento, validade, preco_unitario, estragados, quantidade_inicial;"
+	// A subconsulta enxerga os ajustes de antes da remoção em cascata
+	query := `
+		DELETE FROM Lote WHERE id_lote = $1
+		RETURNING id_lote, id_fornecedor, id_produto, data_fornecimento, validade, preco_unitario,
+			(SELECT estragados FROM estoque_lote WHERE id_lote = $1), quantidade_inicial;`
 	var l model.Lote
 	row := s.db.QueryRowContext(ctx, query, id)
 	err := row.Scan(&l.Id, &l.IdFornecedor, &l.IdProduto, &l.DataFornecimento, &l.Validade, &l.PrecoUnitario, &l.Estragados, &l.QuantidadeInicial)
@@ -150,3 +198,108 @@ func (s *Store) GetRelatorio(ctx context.Context) (map[uint]GastoMensal, error)
 	// Return an empty relatorio for now; populate its fields above as required by your relatorio type.
 	return gastos, nil
 }
+
+func (s *Store) GetAjustes(ctx context.Context, idLote int64) ([]model.AjusteEstoque, error) {
+	var existe bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Lote WHERE id_lote = $1)", idLote).Scan(&existe); err != nil {
+		return nil, err
+	}
+	if !existe {
+		return nil, types.ErrNotFound
+	}
+
+	query := `
+		SELECT id_ajuste, id_lote, id_funcionario, quantidade, motivo::text, observacao, data_hora
+		FROM ajuste_estoque
+		WHERE id_lote = $1
+		ORDER BY data_hora, id_ajuste;`
+	rows, err := s.db.QueryContext(ctx, query, idLote)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ajustes := make([]model.AjusteEstoque, 0)
+	for rows.Next() {
+		var a model.AjusteEstoque
+		if err := rows.Scan(&a.IDAjuste, &a.IDLote, &a.IDFuncionario, &a.Quantidade, &a.Motivo, &a.Observacao, &a.DataHora); err != nil {
+			return nil, err
+		}
+		ajustes = append(ajustes, a)
+	}
+	return ajustes, rows.Err()
+}
+
+// Registra um ajuste no lote sem deixar o estoque negativo.
+func (s *Store) CreateAjuste(ctx context.Context, props *model.AjusteEstoque) error {
+	if !motivosAjuste[props.Motivo] {
+		return fmt.Errorf("%w: motivo %q", ErrAjusteInvalido, props.Motivo)
+	}
+	if props.Quantidade == 0 || (props.Quantidade < 0 && props.Motivo != model.MotivoInventario) {
+		return fmt.Errorf("%w: quantidade deve ser positiva, só inventário aceita valores negativos", ErrAjusteInvalido)
+	}
+	if props.IDFuncionario == nil || *props.IDFuncionario == 0 {
+		return fmt.Errorf("%w: id_funcionario é obrigatório", ErrAjusteInvalido)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, "SELECT id_lote FROM Lote WHERE id_lote = $1 FOR UPDATE", props.IDLote).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.ErrNotFound
+		}
+		return err
+	}
+
+	query := `
+		INSERT INTO ajuste_estoque (id_lote, id_funcionario, quantidade, motivo, observacao)
+		VALUES ($1, $2, $3, $4::motivo_ajuste, $5)
+		RETURNING id_ajuste, data_hora;`
+	err = tx.QueryRowContext(ctx, query, props.IDLote, props.IDFuncionario, props.Quantidade, props.Motivo, props.Observacao).
+		Scan(&props.IDAjuste, &props.DataHora)
+	if err != nil {
+		return err
+	}
+
+	if err := estoque.ConferirLote(ctx, tx, props.IDLote); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Lotes válidos com estoque que vencem nos próximos dias.
+// Vencer hoje já conta como vencido, como na alocação de vendas.
+func (s *Store) GetVencendo(ctx context.Context, dias int) ([]model.LoteVencendo, error) {
+	query := `
+		SELECT el.id_lote, el.id_produto, p.nome, el.id_fornecedor, f.nome,
+			el.validade, (el.validade - CURRENT_DATE) AS dias_restantes, el.disponivel
+		FROM estoque_lote el
+		JOIN Produto p ON p.id_produto = el.id_produto
+		JOIN Fornecedor f ON f.id_fornecedor = el.id_fornecedor
+		WHERE NOT el.vencido AND el.disponivel > 0
+			AND el.validade <= CURRENT_DATE + $1::int
+		ORDER BY el.validade, el.id_lote;`
+	rows, err := s.db.QueryContext(ctx, query, dias)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lotes := make([]model.LoteVencendo, 0)
+	for rows.Next() {
+		var l model.LoteVencendo
+		err := rows.Scan(&l.IDLote, &l.IDProduto, &l.NomeProduto, &l.IDFornecedor, &l.NomeFornecedor,
+			&l.Validade, &l.DiasRestantes, &l.Disponivel)
+		if err != nil {
+			return nil, err
+		}
+		lotes = append(lotes, l)
+	}
+	return lotes, rows.Err()
+}
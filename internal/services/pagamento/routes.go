@@ -0,0 +1,167 @@
+package pagamento
+
+import (
+	"context"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	store PagamentoStore
+}
+
+type PagamentoStore interface {
+	GetByVendaID(ctx context.Context, idVenda int64) (*model.PagamentosVenda, error)
+	Create(ctx context.Context, p *model.Pagamento) error
+	Delete(ctx context.Context, idVenda, idPagamento int64) (*model.Pagamento, error)
+}
+
+func NewHandler(store PagamentoStore) *Handler {
+	return &Handler{store}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /vendas/{id}/pagamentos", h.getByVenda)
+	mux.HandleFunc("POST /vendas/{id}/pagamentos", h.create)
+	mux.HandleFunc("DELETE /vendas/{id}/pagamentos/{id_pagamento}", h.delete)
+}
+
+// @Summary List Pagamentos of a Venda
+// @Tags Pagamento
+// @Produce json
+// @Param id path int true "Venda ID"
+// @Success 200 {object} model.PagamentosVenda
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /vendas/{id}/pagamentos [get]
+func (h *Handler) getByVenda(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pagamentos, err := h.store.GetByVendaID(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Venda not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, pagamentos); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Register a Pagamento for a Venda
+// @Description A sale may be paid in several parts and methods. It is settled once the payments cover its net total.
+// @Tags Pagamento
+// @Accept json
+// @Produce json
+// @Param id path int true "Venda ID"
+// @Param pagamento body model.PagamentoCreate true "Pagamento payload"
+// @Success 201 {object} model.PagamentosVenda
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /vendas/{id}/pagamentos [post]
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.PagamentoCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	pagamento := payload.ToPagamento(id)
+	if err := h.store.Create(ctx, &pagamento); err != nil {
+		WritePagamentoError(w, err)
+		return
+	}
+
+	pagamentos, err := h.store.GetByVendaID(ctx, id)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	util.WriteJSON(w, http.StatusCreated, pagamentos)
+}
+
+// @Summary Refund a Pagamento
+// @Description Removes the payment. A settled sale that is no longer covered goes back to open.
+// @Tags Pagamento
+// @Produce json
+// @Param id path int true "Venda ID"
+// @Param id_pagamento path int true "Pagamento ID"
+// @Success 200 {object} model.Pagamento
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /vendas/{id}/pagamentos/{id_pagamento} [delete]
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	idPagamento, err := strconv.ParseInt(r.PathValue("id_pagamento"), 10, 64)
+	if err != nil {
+		util.ErrorJSON(w, util.ErrInvalidID.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pagamento, err := h.store.Delete(ctx, id, idPagamento)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Pagamento not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, pagamento)
+}
+
+// Traduz os erros de registro de pagamento para o status HTTP.
+func WritePagamentoError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		util.ErrorJSON(w, "Venda not found.", http.StatusNotFound)
+	case errors.Is(err, ErrVendaQuitada):
+		util.ErrorJSON(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrTipoPagamentoInvalido), errors.Is(err, ErrValorInvalido):
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+	default:
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+	}
+}
@@ -0,0 +1,192 @@
+package pagamento
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/services/fidelidade"
+	"edna/internal/services/totais"
+	"edna/internal/types"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+var (
+	ErrVendaQuitada          = errors.New("Venda já quitada")
+	ErrValorExcedeRestante   = errors.New("Valor do pagamento excede o restante da venda")
+	ErrValorInvalido         = errors.New("Valor do pagamento deve ser positivo")
+	ErrTipoPagamentoInvalido = errors.New("Tipo de pagamento inválido")
+)
+
+// Fiado não é pagamento, é a venda em aberto
+func TipoAceito(tipo string) bool {
+	return slices.Contains(model.TiposPagamento, tipo)
+}
+
+// *sql.DB e *sql.Tx
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db}
+}
+
+// Registra um pagamento dentro da transação e quita a venda quando os
+// pagamentos cobrem o total líquido. Retorna os totais atualizados.
+func Registrar(ctx context.Context, tx *sql.Tx, p *model.Pagamento) (*model.VendaTotais, error) {
+	if !TipoAceito(p.TipoPagamento) {
+		return nil, fmt.Errorf("%w: %q", ErrTipoPagamentoInvalido, p.TipoPagamento)
+	}
+	if centavos(p.Valor) <= 0 {
+		return nil, ErrValorInvalido
+	}
+
+	if err := travarVenda(ctx, tx, p.IDVenda); err != nil {
+		return nil, err
+	}
+	t, err := totais.Venda(ctx, tx, p.IDVenda)
+	if err != nil {
+		return nil, err
+	}
+	if t.StatusPagamento == model.StatusPagamentoPago {
+		return nil, ErrVendaQuitada
+	}
+	if centavos(p.Valor) > centavos(t.Restante) {
+		return nil, fmt.Errorf("%w (restante %.2f)", ErrValorExcedeRestante, t.Restante)
+	}
+
+	var dataHora *time.Time
+	if !p.DataHora.IsZero() {
+		dataHora = &p.DataHora
+	}
+	query := `
+		INSERT INTO pagamento (id_venda, tipo_pagamento, valor, data_hora)
+		VALUES ($1, $2::tipo_de_pagamento, $3, COALESCE($4::timestamp, now()))
+		RETURNING id_pagamento, data_hora;`
+	err = tx.QueryRowContext(ctx, query, p.IDVenda, p.TipoPagamento, p.Valor, dataHora).Scan(&p.IDPagamento, &p.DataHora)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := fidelidade.Quitar(ctx, tx, p.IDVenda, p.DataHora); err != nil {
+		return nil, err
+	}
+	return totais.Venda(ctx, tx, p.IDVenda)
+}
+
+// Bloqueia a venda para serializar pagamentos concorrentes.
+func travarVenda(ctx context.Context, tx *sql.Tx, idVenda int64) error {
+	var id int64
+	err := tx.QueryRowContext(ctx, "SELECT id_venda FROM Venda WHERE id_venda = $1 FOR UPDATE", idVenda).Scan(&id)
+	if err == sql.ErrNoRows {
+		return types.ErrNotFound
+	}
+	return err
+}
+
+func (s *Store) GetByVendaID(ctx context.Context, idVenda int64) (*model.PagamentosVenda, error) {
+	t, err := totais.Venda(ctx, s.db, idVenda)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id_pagamento, id_venda, tipo_pagamento::text, valor, data_hora
+		FROM pagamento
+		WHERE id_venda = $1
+		ORDER BY data_hora, id_pagamento;`
+	rows, err := s.db.QueryContext(ctx, query, idVenda)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := model.PagamentosVenda{
+		IDVenda:     idVenda,
+		Pagamentos:  make([]model.Pagamento, 0),
+		VendaTotais: *t,
+	}
+	for rows.Next() {
+		var p model.Pagamento
+		if err := rows.Scan(&p.IDPagamento, &p.IDVenda, &p.TipoPagamento, &p.Valor, &p.DataHora); err != nil {
+			return nil, err
+		}
+		res.Pagamentos = append(res.Pagamentos, p)
+	}
+	return &res, rows.Err()
+}
+
+func (s *Store) Create(ctx context.Context, p *model.Pagamento) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := Registrar(ctx, tx, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Estorna um pagamento. Se a venda deixar de estar coberta ela volta a ficar em aberto
+// e perde os pontos de fidelidade que tinha gerado.
+func (s *Store) Delete(ctx context.Context, idVenda, idPagamento int64) (*model.Pagamento, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := travarVenda(ctx, tx, idVenda); err != nil {
+		return nil, err
+	}
+
+	query := `
+		DELETE FROM pagamento
+		WHERE id_pagamento = $1 AND id_venda = $2
+		RETURNING id_pagamento, id_venda, tipo_pagamento::text, valor, data_hora;`
+	var p model.Pagamento
+	err = tx.QueryRowContext(ctx, query, idPagamento, idVenda).Scan(&p.IDPagamento, &p.IDVenda, &p.TipoPagamento, &p.Valor, &p.DataHora)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+
+	reabrir := `
+		UPDATE Venda v SET data_hora_pagamento = NULL
+		FROM venda_totais vt
+		WHERE v.id_venda = $1 AND vt.id_venda = v.id_venda AND vt.total_pago < vt.total_liquido;`
+	res, err := tx.ExecContext(ctx, reabrir, idVenda)
+	if err != nil {
+		return nil, err
+	}
+	reaberta, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if reaberta > 0 {
+		if err := fidelidade.Estornar(ctx, tx, idVenda); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func centavos(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
@@ -3,13 +3,15 @@ package produto
 import (
 	"context"
 	"edna/internal/model"
+	"edna/internal/services/fornecedor"
 	"edna/internal/types"
 	"edna/internal/util"
 	"net/http"
 )
 
 type Handler struct {
-	store ProdutoStore
+	store  ProdutoStore
+	precos PrecosStore
 }
 
 type ProdutoStore interface {
@@ -24,10 +26,19 @@ type ProdutoStore interface {
 	GetByID(ctx context.Context, id int64) (*model.Produto, error)
 	GetQntByID(ctx context.Context, id int64) (*model.ProdutoWithQnt, error)
 	Delete(ctx context.Context, id int64) error
+	GetAllReposicao(ctx context.Context) ([]model.ReposicaoProduto, error)
+	GetReposicao(ctx context.Context, idProduto int64) (*model.ReposicaoProduto, error)
+	SetReposicao(ctx context.Context, props *model.ReposicaoProduto) error
+	DeleteReposicao(ctx context.Context, idProduto int64) error
 }
 
-func NewHandler(store ProdutoStore) Handler {
-	return Handler{store}
+// Histórico de preços dos fornecedores, implementado por fornecedor.Store
+type PrecosStore interface {
+	GetPrecosProduto(ctx context.Context, idProduto int64, limiteAlta float64) (*model.PrecosProduto, error)
+}
+
+func NewHandler(store ProdutoStore, precos PrecosStore) Handler {
+	return Handler{store, precos}
 }
 
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
@@ -36,7 +47,6 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /produtos/{id}", h.getEstruturalHandler)
 	mux.HandleFunc("PUT /produtos/{id}", h.updateEstruturalHandler)
 	mux.HandleFunc("DELETE /produtos/{id}", h.deleteProdutoHandler)
-
 	mux.HandleFunc("GET /produtos/estrutural", h.getAllEstruturalHandler)
 	mux.HandleFunc("GET /produtos/comercial", h.getAllComercialHandler)
 	mux.HandleFunc("POST /produtos/comercial", h.createComercialHandler)
@@ -44,6 +54,12 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("PUT /produtos/comercial/{id}", h.updateComercialHandler)
 
 	mux.HandleFunc("GET /produtos/quantidade/{id}", h.getQuantidadeHandler)
+	mux.HandleFunc("GET /produtos/precos-fornecedores/{id}", h.getPrecosFornecedoresHandler)
+
+	mux.HandleFunc("GET /produtos/reposicao", h.getAllReposicaoHandler)
+	mux.HandleFunc("GET /produtos/reposicao/{id}", h.getReposicaoHandler)
+	mux.HandleFunc("PUT /produtos/reposicao/{id}", h.setReposicaoHandler)
+	mux.HandleFunc("DELETE /produtos/reposicao/{id}", h.deleteReposicaoHandler)
 }
 
  // @Summary List Produtos (all types)
@@ -72,7 +88,7 @@ func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
 
 	produtos, err := h.store.GetAll(ctx, &filter)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -98,12 +114,12 @@ func (h *Handler) getAllComercialHandler(w http.ResponseWriter, r *http.Request)
 
 	filter, err := NewComercialFilter(r.URL.Query())
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	produtos, err := h.store.GetAllComercial(ctx, &filter)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -130,13 +146,13 @@ func (h *Handler) getAllEstruturalHandler(w http.ResponseWriter, r *http.Request
 
 	filter, err := NewProdutoFilter(r.URL.Query())
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	produtos, err := h.store.GetAllEstrutural(ctx, &filter)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -164,13 +180,18 @@ func (h *Handler) createComercialHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	produto := payload.ToComercial()
 	if err := h.store.CreateComercial(ctx, &produto); err != nil {
 		status := http.StatusInternalServerError
 		if err == types.ErrNotFound {
 			status = http.StatusNotFound
 		}
-		util.ErrorJSON(w, err.Error(), status)
+		util.ErrorBancoJSON(w, err, status)
 		return
 	}
 
@@ -198,9 +219,14 @@ func (h *Handler) createEstruturalHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	produto := payload.ToProduto()
 	if err := h.store.Create(ctx, &produto); err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -235,10 +261,15 @@ func (h *Handler) updateComercialHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	produto := payload.ToComercial()
 	produto.Id = id
 	if err := h.store.UpdateComercial(ctx, &produto); err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -273,10 +304,15 @@ func (h *Handler) updateEstruturalHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	produto := payload.ToProduto()
 	produto.Id = id
 	if err := h.store.Update(ctx, &produto); err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -305,7 +341,7 @@ func (h *Handler) getComercialHandler(w http.ResponseWriter, r *http.Request) {
 
 	produto, err := h.store.GetComercialByID(ctx, id)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -334,7 +370,7 @@ func (h *Handler) getEstruturalHandler(w http.ResponseWriter, r *http.Request) {
 
 	produto, err := h.store.GetByID(ctx, id)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -361,7 +397,7 @@ func (h *Handler) deleteProdutoHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.store.Delete(ctx, id); err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -388,7 +424,7 @@ func (h *Handler) getQuantidadeHandler(w http.ResponseWriter, r *http.Request) {
 
 	model, err := h.store.GetQntByID(ctx, id)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -396,3 +432,180 @@ func (h *Handler) getQuantidadeHandler(w http.ResponseWriter, r *http.Request) {
 		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
 	}
 }
+
+// @Summary List Reorder Points
+// @Tags Produtos
+// @Produce json
+// @Success 200 {array} model.ReposicaoProduto
+// @Failure 500 {object} types.ErrorResponse
+// @Router /produtos/reposicao [get]
+func (h *Handler) getAllReposicaoHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	reposicoes, err := h.store.GetAllReposicao(ctx)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, reposicoes); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Get Produto Reorder Point
+// @Tags Produtos
+// @Produce json
+// @Param id path int true "Produto ID"
+// @Success 200 {object} model.ReposicaoProduto
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /produtos/reposicao/{id} [get]
+func (h *Handler) getReposicaoHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+
+	reposicao, err := h.store.GetReposicao(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Reposicao not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, reposicao); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Set Produto Reorder Point
+// @Description Creates or replaces the minimum stock and reorder quantity of the produto
+// @Tags Produtos
+// @Accept json
+// @Produce json
+// @Param id path int true "Produto ID"
+// @Param reposicao body model.ReposicaoProdutoCreate true "Reorder point payload"
+// @Success 200 {object} model.ReposicaoProduto
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /produtos/reposicao/{id} [put]
+func (h *Handler) setReposicaoHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+
+	payload := model.ReposicaoProdutoCreate{}
+	if err := util.ReadJSON(r, &payload); err != nil {
+		util.ErrorJSON(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	reposicao := payload.ToReposicaoProduto(id)
+	if err := h.store.SetReposicao(ctx, &reposicao); err != nil {
+		switch err {
+		case types.ErrNotFound:
+			util.ErrorJSON(w, "Produto not found.", http.StatusNotFound)
+		case ErrReposicaoInvalida:
+			util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		default:
+			util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+		}
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, reposicao); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Delete Produto Reorder Point
+// @Tags Produtos
+// @Param id path int true "Produto ID"
+// @Success 204 {string} string
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /produtos/reposicao/{id} [delete]
+func (h *Handler) deleteReposicaoHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.DeleteReposicao(ctx, id); err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Reposicao not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Produto price history by supplier
+// @Description Price-over-time series of each supplier of the product with latest, average, min and max price. Suppliers whose last price rose more than limite_alta percent over the previous delivery are flagged with alta.
+// @Tags Produtos
+// @Produce json
+// @Param id path int true "Produto ID"
+// @Param limite_alta query number false "Price rise (%) over the previous delivery that flags the supplier (default 10)"
+// @Success 200 {object} model.PrecosProduto
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /produtos/precos-fornecedores/{id} [get]
+func (h *Handler) getPrecosFornecedoresHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+	limite, ok := fornecedor.LimiteAltaParam(r)
+	if !ok {
+		util.ErrorJSON(w, "limite_alta must be a non-negative number", http.StatusBadRequest)
+		return
+	}
+
+	precos, err := h.precos.GetPrecosProduto(ctx, id, limite)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Produto not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, precos); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
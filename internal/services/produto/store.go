@@ -6,6 +6,7 @@ import (
 	"edna/internal/model"
 	"edna/internal/types"
 	"edna/internal/util"
+	"errors"
 	"log"
 )
 
@@ -211,6 +212,38 @@ func (s *Store) GetComercialByID(ctx context.Context, id int64) (*model.Comercia
 	return &c, nil
 }
 
+// Preço de venda do catálogo de um produto comercial.
+func (s *Store) GetPrecoVenda(ctx context.Context, idProduto int64) (float64, error) {
+	query := "SELECT preco_venda FROM ProdutoComercial WHERE id_produto = $1"
+	var preco float64
+	err := s.db.QueryRowContext(ctx, query, idProduto).Scan(&preco)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, types.ErrNotFound
+		}
+		return 0, err
+	}
+	return preco, nil
+}
+
+// Preço de venda do catálogo do produto de um lote.
+func (s *Store) GetPrecoVendaByLote(ctx context.Context, idLote int64) (float64, error) {
+	query := `
+		SELECT c.preco_venda
+		FROM Lote l
+		INNER JOIN ProdutoComercial c ON c.id_produto = l.id_produto
+		WHERE l.id_lote = $1`
+	var preco float64
+	err := s.db.QueryRowContext(ctx, query, idLote).Scan(&preco)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, types.ErrNotFound
+		}
+		return 0, err
+	}
+	return preco, nil
+}
+
 func (s *Store) GetByID(ctx context.Context, id int64) (*model.Produto, error) {
 	query := "SELECT id_produto, nome, categoria, marca FROM Produto WHERE id_produto = $1"
 	row := s.db.QueryRowContext(ctx, query, id)
@@ -224,18 +257,11 @@ func (s *Store) GetByID(ctx context.Context, id int64) (*model.Produto, error) {
 
 func (s *Store) GetQntByID(ctx context.Context, id int64) (*model.ProdutoWithQnt, error) {
 
-	// Quantidade de produtos disponiveis
-	// Resultado = inicias - estrados - vendidos
-	// coalesce converte o null da soma em zero.
-	// Assim possiveis valores nulos resultam em zero
+	// Quantidade de produtos disponiveis nos lotes válidos, calculada pela view estoque_produto
 	query := `
-	SELECT p.id_produto, p.nome, p.categoria, p.marca,
-		COALESCE(SUM(quantidade_inicial) - SUM(estragados), 0) - COALESCE(SUM(quantidade), 0) AS quantidade_disponivel
-		FROM Produto p
-		LEFT JOIN lote USING (id_produto)
-	 	LEFT JOIN item_venda USING (id_lote)
-		WHERE p.id_produto = $1
-		GROUP BY p.id_produto;`
+	SELECT id_produto, nome, categoria, marca, disponivel AS quantidade_disponivel
+		FROM estoque_produto
+		WHERE id_produto = $1;`
 
 	row := s.db.QueryRowContext(ctx, query, id)
 
@@ -253,3 +279,76 @@ func (s *Store) Delete(ctx context.Context, id int64) error {
 	_, err := s.db.ExecContext(ctx, query, id)
 	return err
 }
+
+var ErrReposicaoInvalida = errors.New("estoque_minimo must be >= 0 and quantidade_reposicao > 0")
+
+func (s *Store) GetAllReposicao(ctx context.Context) ([]model.ReposicaoProduto, error) {
+	query := `
+	SELECT id_produto, estoque_minimo, quantidade_reposicao
+		FROM reposicao_produto
+		ORDER BY id_produto`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]model.ReposicaoProduto, 0)
+	for rows.Next() {
+		var rp model.ReposicaoProduto
+		if err := rows.Scan(&rp.IDProduto, &rp.EstoqueMinimo, &rp.QuantidadeReposicao); err != nil {
+			return nil, err
+		}
+		res = append(res, rp)
+	}
+	return res, rows.Err()
+}
+
+func (s *Store) GetReposicao(ctx context.Context, idProduto int64) (*model.ReposicaoProduto, error) {
+	query := `
+	SELECT id_produto, estoque_minimo, quantidade_reposicao
+		FROM reposicao_produto
+		WHERE id_produto = $1`
+	var rp model.ReposicaoProduto
+	err := s.db.QueryRowContext(ctx, query, idProduto).Scan(&rp.IDProduto, &rp.EstoqueMinimo, &rp.QuantidadeReposicao)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	return &rp, nil
+}
+
+// Cria ou substitui o ponto de reposição do produto
+func (s *Store) SetReposicao(ctx context.Context, props *model.ReposicaoProduto) error {
+	if props.EstoqueMinimo < 0 || props.QuantidadeReposicao <= 0 {
+		return ErrReposicaoInvalida
+	}
+	if _, err := s.GetByID(ctx, props.IDProduto); err != nil {
+		if err == sql.ErrNoRows {
+			return types.ErrNotFound
+		}
+		return err
+	}
+
+	query := `
+	INSERT INTO reposicao_produto (id_produto, estoque_minimo, quantidade_reposicao)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id_produto) DO UPDATE
+		SET estoque_minimo = EXCLUDED.estoque_minimo,
+			quantidade_reposicao = EXCLUDED.quantidade_reposicao`
+	_, err := s.db.ExecContext(ctx, query, props.IDProduto, props.EstoqueMinimo, props.QuantidadeReposicao)
+	return err
+}
+
+func (s *Store) DeleteReposicao(ctx context.Context, idProduto int64) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM reposicao_produto WHERE id_produto = $1", idProduto)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return types.ErrNotFound
+	}
+	return nil
+}
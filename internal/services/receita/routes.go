@@ -0,0 +1,166 @@
+package receita
+
+import (
+	"context"
+	"edna/internal/model"
+	"edna/internal/types"
+	"edna/internal/util"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type Handler struct {
+	store ReceitaStore
+}
+
+type ReceitaStore interface {
+	GetAll(ctx context.Context) ([]model.Receita, error)
+	GetByID(ctx context.Context, idProduto int64) (*model.Receita, error)
+	Set(ctx context.Context, r *model.Receita) error
+	Delete(ctx context.Context, idProduto int64) error
+}
+
+func NewHandler(store ReceitaStore) *Handler {
+	return &Handler{store}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /receitas", h.getAll)
+	mux.HandleFunc("GET /receitas/{id}", h.fetch)
+	mux.HandleFunc("PUT /receitas/{id}", h.set)
+	mux.HandleFunc("DELETE /receitas/{id}", h.delete)
+}
+
+// @Summary List Receitas
+// @Tags Receita
+// @Produce json
+// @Success 200 {array} model.Receita
+// @Failure 500 {object} types.ErrorResponse
+// @Router /receitas [get]
+func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	receitas, err := h.store.GetAll(ctx)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := util.WriteJSON(w, http.StatusOK, receitas); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Get Receita of a comercial Produto
+// @Tags Receita
+// @Produce json
+// @Param id path int true "Produto ID"
+// @Success 200 {object} model.Receita
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /receitas/{id} [get]
+func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	receita, err := h.store.GetByID(ctx, id)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Receita not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, receita); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Set Receita of a comercial Produto
+// @Description Replaces the estrutural ingredients and quantities used per unit sold. Selling the product then draws the ingredients from their lots (FIFO) instead of lots of the product itself.
+// @Tags Receita
+// @Accept json
+// @Produce json
+// @Param id path int true "Produto ID"
+// @Param receita body model.ReceitaCreate true "Receita payload"
+// @Success 200 {object} model.Receita
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /receitas/{id} [put]
+func (h *Handler) set(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.ReceitaCreate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	receita := payload.ToReceita(id)
+	if err := h.store.Set(ctx, &receita); err != nil {
+		switch {
+		case errors.Is(err, types.ErrNotFound):
+			util.ErrorJSON(w, "Produto not found.", http.StatusNotFound)
+		case errors.Is(err, ErrReceitaVazia), errors.Is(err, ErrQuantidadeInvalida), errors.Is(err, ErrIngredienteInvalido):
+			util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		default:
+			util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
+		}
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, receita)
+}
+
+// @Summary Delete Receita of a comercial Produto
+// @Tags Receita
+// @Param id path int true "Produto ID"
+// @Success 204 {string} string
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /receitas/{id} [delete]
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.Delete(ctx, id); err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Receita not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,222 @@
+package receita
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/services/estoque"
+	"edna/internal/types"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrProdutoNaoComercial = errors.New("Receitas são apenas para produtos comerciais")
+	ErrIngredienteInvalido = errors.New("Ingrediente deve ser um produto estrutural diferente do produto")
+	ErrQuantidadeInvalida  = errors.New("Quantidade do ingrediente deve ser positiva")
+	ErrReceitaVazia        = errors.New("Receita precisa de ao menos um ingrediente")
+)
+
+// *sql.DB e *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db}
+}
+
+// Ingredientes da receita do produto. Vazio quando o produto não tem receita.
+func Ingredientes(ctx context.Context, q querier, idProduto int64) ([]model.ItemReceita, error) {
+	query := `
+		SELECT r.id_ingrediente, p.nome, r.quantidade
+		FROM receita r
+		JOIN Produto p ON p.id_produto = r.id_ingrediente
+		WHERE r.id_produto = $1
+		ORDER BY r.id_ingrediente;`
+	rows, err := q.QueryContext(ctx, query, idProduto)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ingredientes := make([]model.ItemReceita, 0)
+	for rows.Next() {
+		var i model.ItemReceita
+		if err := rows.Scan(&i.IDIngrediente, &i.Nome, &i.Quantidade); err != nil {
+			return nil, err
+		}
+		ingredientes = append(ingredientes, i)
+	}
+	return ingredientes, rows.Err()
+}
+
+// Retira dos lotes os ingredientes de `quantidade` unidades vendidas do item,
+// do lote que vence primeiro ao último (FIFO). Deve rodar na transação da venda.
+func Consumir(ctx context.Context, tx *sql.Tx, idItemVenda int64, ingredientes []model.ItemReceita, quantidade int64) ([]model.ConsumoReceita, error) {
+	insert := "INSERT INTO consumo_receita (id_item_venda, id_lote, quantidade) VALUES ($1, $2, $3) RETURNING id_consumo;"
+	consumos := make([]model.ConsumoReceita, 0, len(ingredientes))
+	for _, ing := range ingredientes {
+		lotes, err := estoque.LotesDisponiveis(ctx, tx, ing.IDIngrediente)
+		if err != nil {
+			return nil, err
+		}
+		restante := ing.Quantidade * quantidade
+		for _, l := range lotes {
+			if restante == 0 {
+				break
+			}
+			c := model.ConsumoReceita{
+				IDItemVenda:   idItemVenda,
+				IDLote:        l.IDLote,
+				IDIngrediente: ing.IDIngrediente,
+				Quantidade:    min(restante, l.Disponivel),
+			}
+			if err := tx.QueryRowContext(ctx, insert, c.IDItemVenda, c.IDLote, c.Quantidade).Scan(&c.IDConsumo); err != nil {
+				return nil, err
+			}
+			consumos = append(consumos, c)
+			restante -= c.Quantidade
+		}
+		if restante > 0 {
+			return nil, fmt.Errorf("%w: ingrediente %s (%d), faltam %d unidades", types.ErrEstoqueInsuficiente, ing.Nome, ing.IDIngrediente, restante)
+		}
+	}
+	return consumos, nil
+}
+
+func (s *Store) GetAll(ctx context.Context) ([]model.Receita, error) {
+	query := `
+		SELECT r.id_produto, p.nome, r.id_ingrediente, i.nome, r.quantidade
+		FROM receita r
+		JOIN Produto p ON p.id_produto = r.id_produto
+		JOIN Produto i ON i.id_produto = r.id_ingrediente
+		ORDER BY p.nome, r.id_produto, r.id_ingrediente;`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receitas := make([]model.Receita, 0)
+	for rows.Next() {
+		var idProduto int64
+		var nome string
+		var i model.ItemReceita
+		if err := rows.Scan(&idProduto, &nome, &i.IDIngrediente, &i.Nome, &i.Quantidade); err != nil {
+			return nil, err
+		}
+		n := len(receitas)
+		if n == 0 || receitas[n-1].IDProduto != idProduto {
+			receitas = append(receitas, model.Receita{IDProduto: idProduto, Nome: nome})
+			n++
+		}
+		receitas[n-1].Ingredientes = append(receitas[n-1].Ingredientes, i)
+	}
+	return receitas, rows.Err()
+}
+
+func (s *Store) GetByID(ctx context.Context, idProduto int64) (*model.Receita, error) {
+	r := model.Receita{IDProduto: idProduto}
+	err := s.db.QueryRowContext(ctx, "SELECT nome FROM Produto WHERE id_produto = $1", idProduto).Scan(&r.Nome)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	r.Ingredientes, err = Ingredientes(ctx, s.db, idProduto)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Ingredientes) == 0 {
+		return nil, types.ErrNotFound
+	}
+	return &r, nil
+}
+
+// Substitui os ingredientes da receita do produto comercial
+func (s *Store) Set(ctx context.Context, r *model.Receita) error {
+	if len(r.Ingredientes) == 0 {
+		return ErrReceitaVazia
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var comercial bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT p.nome, pc.id_produto IS NOT NULL
+		FROM Produto p
+		LEFT JOIN ProdutoComercial pc ON pc.id_produto = p.id_produto
+		WHERE p.id_produto = $1`, r.IDProduto).Scan(&r.Nome, &comercial)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.ErrNotFound
+		}
+		return err
+	}
+	if !comercial {
+		return ErrProdutoNaoComercial
+	}
+
+	vistos := make(map[int64]bool, len(r.Ingredientes))
+	for i := range r.Ingredientes {
+		ing := &r.Ingredientes[i]
+		if ing.Quantidade <= 0 {
+			return ErrQuantidadeInvalida
+		}
+		if ing.IDIngrediente == r.IDProduto || vistos[ing.IDIngrediente] {
+			return fmt.Errorf("%w (%d)", ErrIngredienteInvalido, ing.IDIngrediente)
+		}
+		vistos[ing.IDIngrediente] = true
+
+		var estrutural bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT p.nome, pc.id_produto IS NULL
+			FROM Produto p
+			LEFT JOIN ProdutoComercial pc ON pc.id_produto = p.id_produto
+			WHERE p.id_produto = $1`, ing.IDIngrediente).Scan(&ing.Nome, &estrutural)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: produto %d não encontrado", ErrIngredienteInvalido, ing.IDIngrediente)
+			}
+			return err
+		}
+		if !estrutural {
+			return fmt.Errorf("%w (%d)", ErrIngredienteInvalido, ing.IDIngrediente)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM receita WHERE id_produto = $1", r.IDProduto); err != nil {
+		return err
+	}
+	for _, ing := range r.Ingredientes {
+		_, err := tx.ExecContext(ctx, "INSERT INTO receita (id_produto, id_ingrediente, quantidade) VALUES ($1, $2, $3)",
+			r.IDProduto, ing.IDIngrediente, ing.Quantidade)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Remove a receita. Vendas já feitas mantêm os ingredientes consumidos.
+func (s *Store) Delete(ctx context.Context, idProduto int64) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM receita WHERE id_produto = $1", idProduto)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return types.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package relatorio
+
+import (
+	"edna/internal/model"
+	"strings"
+)
+
+// Documento gravado em um cadastro
+type registroDocumento struct {
+	Id        int64
+	Nome      string
+	Documento string
+}
+
+// Aponta os documentos inválidos e os repetidos de um cadastro. validar é
+// documento.CPF ou documento.CNPJ; um documento gravado com pontuação também
+// é inválido, já que as buscas comparam só os dígitos.
+func conferirDocumentos(cadastro string, registros []registroDocumento, validar func(string) (string, error)) []model.ProblemaDocumento {
+	problemas := make([]model.ProblemaDocumento, 0)
+	porDocumento := make(map[string][]registroDocumento)
+	for _, r := range registros {
+		doc := strings.TrimSpace(r.Documento)
+		normalizado, err := validar(doc)
+		if err != nil || normalizado != doc {
+			problemas = append(problemas, model.ProblemaDocumento{
+				Cadastro: cadastro, Id: r.Id, Nome: r.Nome, Documento: r.Documento, Motivo: model.DocumentoInvalido,
+			})
+			continue
+		}
+		porDocumento[normalizado] = append(porDocumento[normalizado], r)
+	}
+
+	for _, r := range registros {
+		doc := strings.TrimSpace(r.Documento)
+		if len(porDocumento[doc]) > 1 {
+			problemas = append(problemas, model.ProblemaDocumento{
+				Cadastro: cadastro, Id: r.Id, Nome: r.Nome, Documento: doc, Motivo: model.DocumentoDuplicado,
+			})
+		}
+	}
+	return problemas
+}
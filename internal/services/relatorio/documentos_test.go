@@ -0,0 +1,36 @@
+package relatorio
+
+import (
+	"edna/internal/documento"
+	"edna/internal/model"
+	"testing"
+)
+
+func TestConferirDocumentos(t *testing.T) {
+	registros := []registroDocumento{
+		{1, "Ana", "52998224725"},
+		{2, "Bruno", "529.982.247-25"},
+		{3, "Carla", "11111111111"},
+		{4, "Davi", "52998224725"},
+		{5, "Eva", "11144477735"},
+	}
+
+	problemas := conferirDocumentos("cliente", registros, documento.CPF)
+	esperado := []struct {
+		id     int64
+		motivo string
+	}{
+		{2, model.DocumentoInvalido},
+		{3, model.DocumentoInvalido},
+		{1, model.DocumentoDuplicado},
+		{4, model.DocumentoDuplicado},
+	}
+	if len(problemas) != len(esperado) {
+		t.Fatalf("expected %d problems, got %+v", len(esperado), problemas)
+	}
+	for i, e := range esperado {
+		if problemas[i].Id != e.id || problemas[i].Motivo != e.motivo {
+			t.Fatalf("problem %d: expected %d %s, got %+v", i, e.id, e.motivo, problemas[i])
+		}
+	}
+}
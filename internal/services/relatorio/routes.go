@@ -16,6 +16,9 @@ type Handler struct {
 type RelatorioStore interface {
 	GetFinancialReport(ctx context.Context, start, end, granularity string, projectionPeriods int) (model.RelatorioFinanceiro, error)
 	GetPayrollReport(ctx context.Context, start, end, tipoFuncionario string) (model.RelatorioFolhaPagamento, error)
+	GetLossReport(ctx context.Context, start, end string) (model.RelatorioPerdas, error)
+	GetMarginReport(ctx context.Context, start, end, metodo string) (model.RelatorioMargem, error)
+	GetDocumentosReport(ctx context.Context) (model.RelatorioDocumentos, error)
 }
 
 func NewHandler(store RelatorioStore) *Handler {
@@ -25,6 +28,9 @@ func NewHandler(store RelatorioStore) *Handler {
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /relatorios/financeiro", h.getFinancialReport)
 	mux.HandleFunc("GET /relatorios/folha-pagamento", h.getPayrollReport)
+	mux.HandleFunc("GET /relatorios/perdas", h.getLossReport)
+	mux.HandleFunc("GET /relatorios/margem", h.getMarginReport)
+	mux.HandleFunc("GET /relatorios/documentos", h.getDocumentosReport)
 }
 
 // @Summary Get Financial Report
@@ -70,7 +76,7 @@ func (h *Handler) getFinancialReport(w http.ResponseWriter, r *http.Request) {
 	report, err := h.store.GetFinancialReport(ctx, start, end, granularity, projection)
 	if err != nil {
 		// Return internal server error with the error message
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -88,7 +94,7 @@ func (h *Handler) getFinancialReport(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param start query string true "Period start date (YYYY-MM-DD)"
 // @Param end query string true "Period end date (YYYY-MM-DD)"
-// @Param tipo query string false "Employee type filter (garcom|seguranca|caixa|faxineiro|balconista)"
+// @Param tipo query string false "Employee type filter (garcom|seguranca|caixa|faxineiro|balconista|gerente)"
 // @Success 200 {object} model.RelatorioFolhaPagamento
 // @Failure 400 {object} types.ErrorResponse
 // @Failure 500 {object} types.ErrorResponse
@@ -111,7 +117,7 @@ func (h *Handler) getPayrollReport(w http.ResponseWriter, r *http.Request) {
 	// Chamar store para gerar o relatório
 	report, err := h.store.GetPayrollReport(ctx, start, end, tipoFuncionario)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -121,3 +127,94 @@ func (h *Handler) getPayrollReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// @Summary Get Loss Report
+// @Description Stock losses recorded as lot adjustments within the period, broken down by reason and product. Cost uses the lot unit price.
+// @Tags Relatórios
+// @Produce json
+// @Param start query string true "Period start date (YYYY-MM-DD)"
+// @Param end query string true "Period end date (YYYY-MM-DD)"
+// @Success 200 {object} model.RelatorioPerdas
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /relatorios/perdas [get]
+func (h *Handler) getLossReport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	q := r.URL.Query()
+	start := q.Get("start")
+	end := q.Get("end")
+
+	if start == "" || end == "" {
+		util.ErrorJSON(w, "start and end query parameters are required (YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.store.GetLossReport(ctx, start, end)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, report); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Get Margin Report
+// @Description Revenue (items net of offer discounts), cost of goods sold, gross margin and margin % per product, categoria and marca for the sales within the period.
+// @Tags Relatórios
+// @Produce json
+// @Param start query string true "Period start date (YYYY-MM-DD)"
+// @Param end query string true "Period end date (YYYY-MM-DD)"
+// @Param custo query string false "Cost method: lote (actual lot cost, default) or medio (weighted average of the product's lots up to end)"
+// @Success 200 {object} model.RelatorioMargem
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /relatorios/margem [get]
+func (h *Handler) getMarginReport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	q := r.URL.Query()
+	start := q.Get("start")
+	end := q.Get("end")
+
+	if start == "" || end == "" {
+		util.ErrorJSON(w, "start and end query parameters are required (YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.store.GetMarginReport(ctx, start, end, q.Get("custo"))
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, report); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Get Document Validation Report
+// @Description Checks the CPF of every client and employee and the CNPJ of every supplier already stored, listing documents with wrong check digits, punctuation or duplicates.
+// @Tags Relatórios
+// @Produce json
+// @Success 200 {object} model.RelatorioDocumentos
+// @Failure 500 {object} types.ErrorResponse
+// @Router /relatorios/documentos [get]
+func (h *Handler) getDocumentosReport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	report, err := h.store.GetDocumentosReport(ctx)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, report); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
+	"edna/internal/documento"
 	"edna/internal/model"
 )
 
@@ -21,7 +23,7 @@ func NewStore(db *sql.DB) *Store {
 
 // GetPayrollReport gera um relatório de folha de pagamento mensal para o período especificado
 // - start/end são esperados no formato "YYYY-MM-DD" (período do relatório)
-// - tipoFuncionario: filtro opcional por tipo de funcionário (garcom, seguranca, caixa, faxineiro, balconista)
+// - tipoFuncionario: filtro opcional por tipo de funcionário (garcom, seguranca, caixa, faxineiro, balconista, gerente)
 // - retorna folhas de pagamento mensais para cada mês dentro do período
 func (s *Store) GetPayrollReport(ctx context.Context, start, end, tipoFuncionario string) (model.RelatorioFolhaPagamento, error) {
 	var report model.RelatorioFolhaPagamento
@@ -77,6 +79,237 @@ func (s *Store) GetPayrollReport(ctx context.Context, start, end, tipoFuncionari
 	return report, nil
 }
 
+// GetLossReport agrupa os ajustes de estoque do período por motivo e por produto
+// - start/end são esperados no formato "YYYY-MM-DD" (inclusivos)
+// - correções de inventário com sobra entram negativas e abatem as perdas
+func (s *Store) GetLossReport(ctx context.Context, start, end string) (model.RelatorioPerdas, error) {
+	report := model.RelatorioPerdas{
+		PorMotivo:  make([]model.PerdaMotivo, 0),
+		PorProduto: make([]model.PerdaProduto, 0),
+	}
+
+	startT, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return report, fmt.Errorf("data de início inválida: %w", err)
+	}
+	endT, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return report, fmt.Errorf("data de fim inválida: %w", err)
+	}
+	if endT.Before(startT) {
+		return report, errors.New("data de fim deve ser >= data de início")
+	}
+	report.PeriodStart = startT.Format("2006-01-02")
+	report.PeriodEnd = endT.Format("2006-01-02")
+
+	query := `
+	SELECT p.id_produto, p.nome, a.motivo::text,
+	       SUM(a.quantidade)::bigint AS unidades,
+	       COALESCE(SUM(a.quantidade * l.preco_unitario), 0) AS custo
+	FROM ajuste_estoque a
+	JOIN Lote l ON l.id_lote = a.id_lote
+	JOIN Produto p ON p.id_produto = l.id_produto
+	WHERE a.data_hora::date BETWEEN $1::date AND $2::date
+	GROUP BY p.id_produto, p.nome, a.motivo
+	ORDER BY p.nome, p.id_produto, a.motivo;`
+
+	rows, err := s.db.QueryContext(ctx, query, report.PeriodStart, report.PeriodEnd)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	porMotivo := make(map[string]*model.PerdaMotivo)
+	var motivos []string
+	for rows.Next() {
+		var idProduto int64
+		var nome string
+		var m model.PerdaMotivo
+		if err := rows.Scan(&idProduto, &nome, &m.Motivo, &m.Unidades, &m.Custo); err != nil {
+			return report, err
+		}
+
+		n := len(report.PorProduto)
+		if n == 0 || report.PorProduto[n-1].IdProduto != idProduto {
+			report.PorProduto = append(report.PorProduto, model.PerdaProduto{IdProduto: idProduto, Nome: nome})
+			n++
+		}
+		produto := &report.PorProduto[n-1]
+		produto.Unidades += m.Unidades
+		produto.Custo += m.Custo
+		produto.PorMotivo = append(produto.PorMotivo, m)
+
+		total, ok := porMotivo[m.Motivo]
+		if !ok {
+			total = &model.PerdaMotivo{Motivo: m.Motivo}
+			porMotivo[m.Motivo] = total
+			motivos = append(motivos, m.Motivo)
+		}
+		total.Unidades += m.Unidades
+		total.Custo += m.Custo
+
+		report.TotalUnidades += m.Unidades
+		report.TotalCusto += m.Custo
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	for _, motivo := range motivos {
+		report.PorMotivo = append(report.PorMotivo, *porMotivo[motivo])
+	}
+	return report, nil
+}
+
+// Métodos de custo do relatório de margem
+const (
+	CustoLote  = "lote"  // preço unitário do lote de onde saiu o item
+	CustoMedio = "medio" // média dos lotes do produto ponderada pela quantidade, até o fim do período
+)
+
+// GetMarginReport calcula receita, CMV e margem bruta por produto, categoria e marca
+// das vendas feitas entre start e end (YYYY-MM-DD).
+// O custo de produtos com receita é o dos lotes de ingredientes consumidos.
+func (s *Store) GetMarginReport(ctx context.Context, start, end, metodo string) (model.RelatorioMargem, error) {
+	report := model.RelatorioMargem{
+		PorProduto:   make([]model.MargemProduto, 0),
+		PorCategoria: make([]model.MargemGrupo, 0),
+		PorMarca:     make([]model.MargemGrupo, 0),
+	}
+
+	if metodo == "" {
+		metodo = CustoLote
+	}
+	if metodo != CustoLote && metodo != CustoMedio {
+		return report, fmt.Errorf("método de custo inválido: %s (use lote ou medio)", metodo)
+	}
+	startT, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return report, fmt.Errorf("data de início inválida: %w", err)
+	}
+	endT, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return report, fmt.Errorf("data de fim inválida: %w", err)
+	}
+	if endT.Before(startT) {
+		return report, errors.New("data de fim deve ser >= data de início")
+	}
+	report.PeriodStart = startT.Format("2006-01-02")
+	report.PeriodEnd = endT.Format("2006-01-02")
+	report.MetodoCusto = metodo
+
+	// Sem lotes até o fim do período o custo médio cai no custo do próprio lote.
+	// O desconto dos pontos resgatados é repartido entre os itens pelo valor de
+	// cada um depois das ofertas, e a receita fecha com o total_liquido da venda.
+	query := `
+	WITH itens AS (
+		SELECT iv.id_item_venda, iv.id_produto, iv.quantidade,
+		       (iv.quantidade * iv.valor_unitario - COALESCE(ao.desconto, 0))
+		       * (1 - COALESCE(ap.desconto / NULLIF(vt.total_liquido + ap.desconto, 0), 0)) AS receita
+		FROM item_venda iv
+		JOIN venda_totais vt ON vt.id_venda = iv.id_venda
+		LEFT JOIN aplica_pontos ap ON ap.id_venda = iv.id_venda
+		LEFT JOIN (
+			SELECT id_item_venda, SUM(desconto) AS desconto
+			FROM aplica_oferta
+			GROUP BY id_item_venda
+		) ao ON ao.id_item_venda = iv.id_item_venda
+		WHERE vt.data_hora_venda::date BETWEEN $1::date AND $2::date
+	), custo_medio AS (
+		SELECT id_produto, SUM(preco_unitario * quantidade_inicial) / NULLIF(SUM(quantidade_inicial), 0) AS custo
+		FROM Lote
+		WHERE data_fornecimento <= $2::date AND quantidade_inicial > 0
+		GROUP BY id_produto
+	), saidas AS (
+		SELECT i.id_item_venda, iv.id_lote, iv.quantidade
+		FROM itens i
+		JOIN item_venda iv ON iv.id_item_venda = i.id_item_venda
+		WHERE iv.id_lote IS NOT NULL
+		UNION ALL
+		SELECT cr.id_item_venda, cr.id_lote, cr.quantidade
+		FROM itens i
+		JOIN consumo_receita cr ON cr.id_item_venda = i.id_item_venda
+	), custo_itens AS (
+		SELECT s.id_item_venda,
+		       SUM(s.quantidade * CASE WHEN $3::text = 'medio' THEN COALESCE(cm.custo, l.preco_unitario) ELSE l.preco_unitario END) AS custo
+		FROM saidas s
+		JOIN Lote l ON l.id_lote = s.id_lote
+		LEFT JOIN custo_medio cm ON cm.id_produto = l.id_produto
+		GROUP BY s.id_item_venda
+	)
+	SELECT p.id_produto, p.nome, COALESCE(p.categoria, ''), COALESCE(p.marca, ''),
+	       SUM(i.quantidade)::bigint,
+	       COALESCE(SUM(i.receita), 0)::float8,
+	       COALESCE(SUM(ci.custo), 0)::float8
+	FROM itens i
+	JOIN Produto p ON p.id_produto = i.id_produto
+	LEFT JOIN custo_itens ci ON ci.id_item_venda = i.id_item_venda
+	GROUP BY p.id_produto, p.nome, p.categoria, p.marca
+	ORDER BY p.nome, p.id_produto;`
+
+	rows, err := s.db.QueryContext(ctx, query, report.PeriodStart, report.PeriodEnd, metodo)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	categorias := make(map[string]*model.MargemGrupo)
+	marcas := make(map[string]*model.MargemGrupo)
+	var ordemCategorias, ordemMarcas []string
+	for rows.Next() {
+		var mp model.MargemProduto
+		err := rows.Scan(&mp.IdProduto, &mp.Nome, &mp.Categoria, &mp.Marca, &mp.Unidades, &mp.Receita, &mp.Custo)
+		if err != nil {
+			return report, err
+		}
+		calcularMargem(&mp.Margem)
+		report.PorProduto = append(report.PorProduto, mp)
+
+		somarMargem(&report.Total, mp.Margem)
+		if _, ok := categorias[mp.Categoria]; !ok {
+			categorias[mp.Categoria] = &model.MargemGrupo{Nome: mp.Categoria}
+			ordemCategorias = append(ordemCategorias, mp.Categoria)
+		}
+		somarMargem(&categorias[mp.Categoria].Margem, mp.Margem)
+		if _, ok := marcas[mp.Marca]; !ok {
+			marcas[mp.Marca] = &model.MargemGrupo{Nome: mp.Marca}
+			ordemMarcas = append(ordemMarcas, mp.Marca)
+		}
+		somarMargem(&marcas[mp.Marca].Margem, mp.Margem)
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	calcularMargem(&report.Total)
+	for _, c := range ordemCategorias {
+		calcularMargem(&categorias[c].Margem)
+		report.PorCategoria = append(report.PorCategoria, *categorias[c])
+	}
+	for _, m := range ordemMarcas {
+		calcularMargem(&marcas[m].Margem)
+		report.PorMarca = append(report.PorMarca, *marcas[m])
+	}
+	return report, nil
+}
+
+func somarMargem(total *model.Margem, m model.Margem) {
+	total.Unidades += m.Unidades
+	total.Receita += m.Receita
+	total.Custo += m.Custo
+}
+
+// Arredonda receita e custo e calcula a margem bruta e o percentual sobre a receita
+func calcularMargem(m *model.Margem) {
+	m.Receita = math.Round(m.Receita*100) / 100
+	m.Custo = math.Round(m.Custo*100) / 100
+	m.MargemBruta = math.Round((m.Receita-m.Custo)*100) / 100
+	m.MargemPercentual = 0
+	if m.Receita != 0 {
+		m.MargemPercentual = math.Round(m.MargemBruta/m.Receita*10000) / 100
+	}
+}
+
 // generateMonthlyPayroll gera a folha de pagamento para um mês específico
 func (s *Store) generateMonthlyPayroll(ctx context.Context, month time.Time, tipoFuncionario string) (model.FolhaPagamentoMensal, error) {
 	var folha model.FolhaPagamentoMensal
@@ -259,6 +492,7 @@ func (s *Store) GetFinancialReport(ctx context.Context, start, end, granularity
 }
 
 // fetchReceita retorna a receita de vendas agragado com base em periodo de tempo.
+// A receita é o valor líquido (com descontos) da view venda_totais.
 func (s *Store) fetchReceita(ctx context.Context, start, end, granularity string) (map[time.Time]float64, error) {
 	agg := make(map[time.Time]float64)
 
@@ -268,9 +502,8 @@ func (s *Store) fetchReceita(ctx context.Context, start, end, granularity string
 	// Build query
 	query := fmt.Sprintf(`
 	SELECT date_trunc('%s', v.data_hora_venda) AS period,
-	       COALESCE(SUM(iv.quantidade * iv.valor_unitario), 0) AS receita
-	FROM Venda v
-	JOIN item_venda iv ON iv.id_venda = v.id_venda
+	       COALESCE(SUM(v.total_liquido), 0) AS receita
+	FROM venda_totais v
 	WHERE v.data_hora_venda::date BETWEEN $1::date AND $2::date
 	GROUP BY period
 	ORDER BY period;
@@ -447,3 +680,43 @@ func dateFormatForGranularity(granularity string) string {
 		return "2006-01-02"
 	}
 }
+
+// GetDocumentosReport confere os CPFs de clientes e funcionários e os CNPJs de fornecedores
+// já gravados, apontando os inválidos e os repetidos. Documentos nulos não são conferidos.
+func (s *Store) GetDocumentosReport(ctx context.Context) (model.RelatorioDocumentos, error) {
+	report := model.RelatorioDocumentos{Problemas: make([]model.ProblemaDocumento, 0)}
+	cadastros := []struct {
+		nome    string
+		query   string
+		validar func(string) (string, error)
+	}{
+		{"cliente", "SELECT id_cliente, nome, cpf FROM Cliente WHERE cpf IS NOT NULL ORDER BY id_cliente", documento.CPF},
+		{"funcionario", "SELECT id_funcionario, nome, CPF FROM Funcionario WHERE CPF IS NOT NULL ORDER BY id_funcionario", documento.CPF},
+		{"fornecedor", "SELECT id_fornecedor, nome, CNPJ FROM Fornecedor WHERE CNPJ IS NOT NULL ORDER BY id_fornecedor", documento.CNPJ},
+	}
+
+	for _, c := range cadastros {
+		rows, err := s.db.QueryContext(ctx, c.query)
+		if err != nil {
+			return report, err
+		}
+		registros := make([]registroDocumento, 0)
+		for rows.Next() {
+			var r registroDocumento
+			if err := rows.Scan(&r.Id, &r.Nome, &r.Documento); err != nil {
+				rows.Close()
+				return report, err
+			}
+			registros = append(registros, r)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return report, err
+		}
+
+		report.Conferidos += len(registros)
+		report.Problemas = append(report.Problemas, conferirDocumentos(c.nome, registros, c.validar)...)
+	}
+	return report, nil
+}
@@ -0,0 +1,36 @@
+// Totais das vendas lidos da view venda_totais. Fica abaixo de pagamento,
+// fidelidade, venda e lgpd para que todos usem o mesmo cálculo.
+package totais
+
+import (
+	"context"
+	"database/sql"
+	"edna/internal/model"
+	"edna/internal/types"
+)
+
+// Colunas de model.VendaTotais na view (alias vt), na ordem de Campos
+const Colunas = "vt.total_bruto, vt.total_desconto, vt.total_liquido, vt.total_pago, vt.restante, vt.status_pagamento"
+
+// *sql.DB e *sql.Tx
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Destinos do Scan para as Colunas
+func Campos(t *model.VendaTotais) []any {
+	return []any{&t.TotalBruto, &t.TotalDesconto, &t.TotalLiquido, &t.TotalPago, &t.Restante, &t.StatusPagamento}
+}
+
+// Totais de uma venda com o restante e o status de pagamento
+func Venda(ctx context.Context, q querier, idVenda int64) (*model.VendaTotais, error) {
+	var t model.VendaTotais
+	err := q.QueryRowContext(ctx, "SELECT "+Colunas+" FROM venda_totais vt WHERE vt.id_venda = $1", idVenda).Scan(Campos(&t)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, types.ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
@@ -38,3 +38,31 @@ func NewVendaFilter(params url.Values) (util.Filter, error) {
 	}
 	return filter, nil
 }
+
+// Filtro do histórico de um cliente, por data e valor da venda
+func NewHistoricoFilter(params url.Values) (util.Filter, error) {
+	var filter util.Filter
+	if err := filter.GetOffset(params); err != nil {
+		return filter, err
+	}
+
+	if err := filter.GetLimit(params); err != nil {
+		return filter, err
+	}
+
+	attrs := []string{"data_hora_venda", "data_hora_pagamento", "total_liquido"}
+	if err := filter.GetSorts(params, attrs); err != nil {
+		return filter, err
+	}
+
+	for _, attr := range []string{"data_hora_venda", "data_hora_pagamento"} {
+		if err := filter.GetFilterTime(params, attr); err != nil {
+			return filter, err
+		}
+	}
+
+	if err := filter.GetFilterFloat(params, "total_liquido"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
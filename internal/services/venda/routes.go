@@ -3,13 +3,41 @@ package venda
 import (
 	"context"
 	"edna/internal/model"
+	"edna/internal/services/aplica_oferta"
+	"edna/internal/services/desconto"
+	"edna/internal/services/item_venda"
+	"edna/internal/services/pagamento"
+	"edna/internal/types"
 	"edna/internal/util"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 )
 
+// Quantidade de produtos favoritos no histórico quando `top` não é informado
+const TopFavoritosPadrao = 5
+
 type Handler struct {
-	store VendaStore
+	store   VendaStore
+	precos  PrecoStore
+	itens   ItensStore
+	ofertas OfertasStore
+}
+
+// Itens da venda com detalhes do produto, implementado pelo store de item_venda.
+type ItensStore interface {
+	GetItemsByVendaID(ctx context.Context, idVenda int64) ([]item_venda.ItemVendaDetail, error)
+}
+
+// Ofertas aplicadas na venda, implementado pelo store de aplica_oferta.
+type OfertasStore interface {
+	GetByVendaID(ctx context.Context, idVenda int64) ([]aplica_oferta.AplicaOfertaDetail, error)
+}
+
+// Fonte dos preços de catálogo, implementada pelo store de produto.
+type PrecoStore interface {
+	GetPrecoVenda(ctx context.Context, idProduto int64) (float64, error)
 }
 
 type VendaStore interface {
@@ -18,18 +46,24 @@ type VendaStore interface {
 	GetByID(ctx context.Context, id int64) (*model.Venda, error)
 	Update(ctx context.Context, props *model.Venda) error
 	Delete(ctx context.Context, id int64) (*model.Venda, error)
+	CreateCompleta(ctx context.Context, props *model.VendaCompletaCreate) (*model.VendaCompleta, error)
+	GetTotais(ctx context.Context, id int64) (*model.VendaTotais, error)
+	GetHistoricoCliente(ctx context.Context, idCliente int64, filter util.Filter, top int) (*HistoricoCliente, error)
 }
 
-func NewHandler(store VendaStore) *Handler {
-	return &Handler{store}
+func NewHandler(store VendaStore, precos PrecoStore, itens ItensStore, ofertas OfertasStore) *Handler {
+	return &Handler{store, precos, itens, ofertas}
 }
 
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /vendas", h.getAll)
 	mux.HandleFunc("POST /vendas", h.create)
+	mux.HandleFunc("POST /vendas/completa", h.createCompleta)
 	mux.HandleFunc("GET /vendas/{id}", h.fetch)
+	mux.HandleFunc("GET /vendas/{id}/resumo", h.fetchResumo)
 	mux.HandleFunc("PUT /vendas/{id}", h.update)
 	mux.HandleFunc("DELETE /vendas/{id}", h.delete)
+	mux.HandleFunc("GET /clientes/{id}/historico", h.fetchHistorico)
 }
 
 // @Summary List Vendas
@@ -51,21 +85,22 @@ func (h *Handler) getAll(w http.ResponseWriter, r *http.Request) {
 
 	filters, err := NewVendaFilter(r.URL.Query())
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	vendas, err := h.store.GetAll(ctx, filters)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	err = util.WriteJSON(w, http.StatusOK, vendas)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
 // @Summary Create Venda
+// @Description The sale is created open. data_hora_pagamento is rejected with 400, sales are settled by POST /vendas/{id}/pagamentos.
 // @Tags Venda
 // @Accept json
 // @Produce json
@@ -90,10 +125,15 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToVenda()
 	err = h.store.Create(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -121,7 +161,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 
 	venda, err := h.store.GetByID(ctx, id)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 	if venda == nil {
@@ -136,6 +176,7 @@ func (h *Handler) fetch(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Update Venda
+// @Description Does not change data_hora_pagamento, which follows the registered payments. Sending it is rejected with 400.
 // @Tags Venda
 // @Accept json
 // @Produce json
@@ -162,11 +203,16 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
 	model := payload.ToVenda()
 	model.Id = id
 	err = h.store.Update(ctx, &model)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -193,9 +239,199 @@ func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
 
 	model, err := h.store.Delete(ctx, id)
 	if err != nil {
-		util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+		util.ErrorBancoJSON(w, err, http.StatusUnprocessableEntity)
 		return
 	}
 
 	util.WriteJSON(w, http.StatusOK, model)
 }
+
+// @Summary Create Venda with items and offers
+// @Description Creates the Venda, its item_venda rows, aplica_oferta rows and pagamentos in a single transaction.
+// @Description A sale left open (fiado) that would put the client over its credit limit is rejected with 409.
+// @Description The discount of each id_oferta comes from the offer engine; an offer that does not reach the item is rejected with 422.
+// @Description data_hora_pagamento without pagamentos settles the sale with one payment of tipo_pagamento; with fiado or no tipo_pagamento it is rejected with 400.
+// @Tags Venda
+// @Accept json
+// @Produce json
+// @Param venda body model.VendaCompletaCreate true "Venda completa payload"
+// @Success 201 {object} model.VendaCompleta
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /vendas/completa [post]
+func (h *Handler) createCompleta(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	if r.Body == nil {
+		util.ErrorJSON(w, "No body in the request", http.StatusBadRequest)
+		return
+	}
+
+	var payload model.VendaCompletaCreate
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := payload.Validar(); err != nil {
+		util.ErrorValidacaoJSON(w, err)
+		return
+	}
+
+	// Preços vêm do catálogo, valores diferentes só com motivo
+	for i := range payload.Itens {
+		it := &payload.Itens[i]
+		catalogo, err := h.precos.GetPrecoVenda(ctx, it.IdProduto)
+		if err != nil {
+			item_venda.WritePrecoError(w, err)
+			return
+		}
+		valor, override, err := item_venda.ResolverPreco(catalogo, it.ValorUnitario, it.MotivoPreco)
+		if err != nil {
+			item_venda.WritePrecoError(w, err)
+			return
+		}
+		it.ValorUnitario = &valor
+		it.PrecoOverride = override
+	}
+
+	venda, err := h.store.CreateCompleta(ctx, &payload)
+	if err != nil {
+		if errors.Is(err, types.ErrEstoqueInsuficiente) || errors.Is(err, types.ErrLimiteCredito) {
+			util.ErrorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, desconto.ErrOfertaNaoAplicavel) {
+			util.ErrorJSON(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		pagamento.WritePagamentoError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, venda)
+}
+
+// @Summary Client purchase history
+// @Description Paginated sales of the client with items and totals, plus total spend, visit count, average ticket, last visit and the most bought products. The indicators and favourites cover every sale matching the filters, not just the current page.
+// @Tags Venda
+// @Produce json
+// @Param id path int true "Cliente ID"
+// @Param top query int false "Number of favourite products (default 5)"
+// @Param filter-data_hora_venda query string false "Filter by data_hora_venda using operators: eq, ne, gt, lt, ge, le"
+// @Param filter-data_hora_pagamento query string false "Filter by data_hora_pagamento using operators: eq, ne, gt, lt, ge, le"
+// @Param filter-total_liquido query number false "Filter by total_liquido using operators: eq, ne, gt, lt, ge, le"
+// @Param sort query string false "Sort fields: data_hora_venda, data_hora_pagamento, total_liquido. Prefix with '-' for desc. Default -data_hora_venda."
+// @Param offset query int false "Pagination offset (default 0)"
+// @Param limit query int false "Pagination limit"
+// @Success 200 {object} HistoricoCliente
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /clientes/{id}/historico [get]
+func (h *Handler) fetchHistorico(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	top := TopFavoritosPadrao
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			util.ErrorJSON(w, "top must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	filter, err := NewHistoricoFilter(r.URL.Query())
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	historico, err := h.store.GetHistoricoCliente(ctx, id, filter, top)
+	if err != nil {
+		if err == types.ErrNotFound {
+			util.ErrorJSON(w, "Cliente not found.", http.StatusNotFound)
+			return
+		}
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	for i := range historico.Vendas {
+		itens, err := h.itens.GetItemsByVendaID(ctx, historico.Vendas[i].Id)
+		if err != nil {
+			util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+		if itens == nil {
+			itens = make([]item_venda.ItemVendaDetail, 0)
+		}
+		historico.Vendas[i].Itens = itens
+	}
+
+	if err := util.WriteJSON(w, http.StatusOK, historico); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Get Venda summary
+// @Description Returns the sale items, applied offers, gross, discount and net totals and payment status.
+// @Tags Venda
+// @Produce json
+// @Param id path int true "Venda ID"
+// @Success 200 {object} VendaResumo
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /vendas/{id}/resumo [get]
+func (h *Handler) fetchResumo(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), util.RequestTimeout)
+	defer cancel()
+
+	id, err := util.GetIDParam(r)
+	if err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	venda, err := h.store.GetByID(ctx, id)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if venda == nil {
+		util.ErrorJSON(w, "Venda not found.", http.StatusNotFound)
+		return
+	}
+
+	resumo := VendaResumo{Venda: *venda}
+	if resumo.Itens, err = h.itens.GetItemsByVendaID(ctx, id); err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if resumo.Ofertas, err = h.ofertas.GetByVendaID(ctx, id); err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	totais, err := h.store.GetTotais(ctx, id)
+	if err != nil {
+		util.ErrorBancoJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	resumo.VendaTotais = *totais
+
+	if err = util.WriteJSON(w, http.StatusOK, resumo); err != nil {
+		util.ErrorJSON(w, err.Error(), http.StatusInternalServerError)
+	}
+}
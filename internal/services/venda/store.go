@@ -4,10 +4,58 @@ import (
 	"context"
 	"database/sql"
 	"edna/internal/model"
+	"edna/internal/services/aplica_oferta"
+	"edna/internal/services/cliente"
+	"edna/internal/services/desconto"
+	"edna/internal/services/fidelidade"
+	"edna/internal/services/item_venda"
+	"edna/internal/services/pagamento"
+	"edna/internal/services/totais"
 	"edna/internal/types"
 	"edna/internal/util"
+	"errors"
+	"fmt"
+	"math"
+	"time"
 )
 
+// Resumo de uma venda com itens, ofertas aplicadas e totais.
+type VendaResumo struct {
+	model.Venda
+	Itens   []item_venda.ItemVendaDetail       `json:"itens"`
+	Ofertas []aplica_oferta.AplicaOfertaDetail `json:"ofertas"`
+	model.VendaTotais
+}
+
+// Venda no histórico do cliente, com itens e totais.
+type VendaHistorico struct {
+	model.Venda
+	Itens []item_venda.ItemVendaDetail `json:"itens"`
+	model.VendaTotais
+}
+
+// Produto mais comprado pelo cliente no período
+type ProdutoFavorito struct {
+	IDProduto  int64   `json:"id_produto"`
+	Nome       string  `json:"nome"`
+	Marca      string  `json:"marca"`
+	Quantidade int64   `json:"quantidade"`
+	Valor      float64 `json:"valor"` // Líquido das ofertas
+	Vendas     int64   `json:"vendas"`
+}
+
+// Histórico de compras do cliente. Os indicadores e favoritos cobrem todo o
+// período filtrado, a lista de vendas é paginada.
+type HistoricoCliente struct {
+	IDCliente    int64             `json:"id_cliente"`
+	TotalGasto   float64           `json:"total_gasto"`
+	Visitas      int64             `json:"visitas"`
+	TicketMedio  float64           `json:"ticket_medio"`
+	UltimaVisita *time.Time        `json:"ultima_visita"`
+	Favoritos    []ProdutoFavorito `json:"favoritos"`
+	Vendas       []VendaHistorico  `json:"vendas"`
+}
+
 type Store struct {
 	db *sql.DB
 }
@@ -18,7 +66,7 @@ func NewStore(db *sql.DB) *Store {
 
 func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.Venda, error) {
 
-	query := "SELECT id_venda, id_cliente, id_funcionario, data_hora_venda, data_hora_pagamento, tipo_pagamento FROM Venda AS v"
+	query := "SELECT id_venda, id_cliente, id_funcionario, data_hora_venda, data_hora_pagamento, COALESCE(tipo_pagamento::text, '') AS tipo_pagamento FROM Venda AS v"
 	rows, err := util.QueryRowsWithFilter(s.db, ctx, query, &filter, "v")
 	if err != nil {
 		return nil, err
@@ -35,14 +83,15 @@ func (s *Store) GetAll(ctx context.Context, filter util.Filter) ([]model.Venda,
 	return vendas, nil
 }
 
+// A venda nasce em aberto, só é quitada pelos pagamentos
 func (s *Store) Create(ctx context.Context, venda *model.Venda) error {
-	query := "INSERT INTO Venda (id_cliente, id_funcionario, data_hora_venda, data_hora_pagamento, tipo_pagamento) VALUES ($1, $2, $3, $4, $5) RETURNING id_venda"
-	res := s.db.QueryRowContext(ctx, query, venda.IdCliente, venda.IdFuncionario, venda.DataHoraVenda, venda.DataHoraPagamento, venda.TipoPagamento)
+	query := "INSERT INTO Venda (id_cliente, id_funcionario, data_hora_venda, tipo_pagamento) VALUES ($1, $2, $3, NULLIF($4, '')::tipo_de_pagamento) RETURNING id_venda"
+	res := s.db.QueryRowContext(ctx, query, venda.IdCliente, venda.IdFuncionario, venda.DataHoraVenda, venda.TipoPagamento)
 	return res.Scan(&venda.Id)
 }
 
 func (s *Store) GetByID(ctx context.Context, id int64) (*model.Venda, error) {
-	query := "SELECT id_venda, id_cliente, id_funcionario, data_hora_venda, data_hora_pagamento, tipo_pagamento FROM Venda WHERE id_venda = $1"
+	query := "SELECT id_venda, id_cliente, id_funcionario, data_hora_venda, data_hora_pagamento, COALESCE(tipo_pagamento::text, '') FROM Venda WHERE id_venda = $1"
 	row := s.db.QueryRowContext(ctx, query, id)
 	var venda model.Venda
 	err := row.Scan(&venda.Id, &venda.IdCliente, &venda.IdFuncionario, &venda.DataHoraVenda, &venda.DataHoraPagamento, &venda.TipoPagamento)
@@ -55,24 +104,21 @@ func (s *Store) GetByID(ctx context.Context, id int64) (*model.Venda, error) {
 	return &venda, nil
 }
 
+// Não altera a data de pagamento, que segue os pagamentos registrados
 func (s *Store) Update(ctx context.Context, props *model.Venda) error {
-	query := "UPDATE Venda SET id_cliente = $1, id_funcionario = $2, data_hora_venda = $3, data_hora_pagamento = $4, tipo_pagamento = $5 WHERE id_venda = $6;"
-	res, err := s.db.ExecContext(ctx, query, props.IdCliente, props.IdFuncionario, props.DataHoraVenda, props.DataHoraPagamento, props.TipoPagamento, props.Id)
-	if err != nil {
-		return err
-	}
-	rowsAffected, err := res.RowsAffected()
+	query := "UPDATE Venda SET id_cliente = $1, id_funcionario = $2, data_hora_venda = $3, tipo_pagamento = NULLIF($4, '')::tipo_de_pagamento WHERE id_venda = $5 RETURNING data_hora_pagamento;"
+	err := s.db.QueryRowContext(ctx, query, props.IdCliente, props.IdFuncionario, props.DataHoraVenda, props.TipoPagamento, props.Id).Scan(&props.DataHoraPagamento)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.ErrNotFound
+		}
 		return err
 	}
-	if rowsAffected == 0 {
-		return types.ErrNotFound
-	}
 	return nil
 }
 
 func (s *Store) Delete(ctx context.Context, id int64) (*model.Venda, error) {
-	query := "DELETE FROM Venda WHERE id_venda = $1 RETURNING id_venda, id_cliente, id_funcionario, data_hora_venda, data_hora_pagamento, tipo_pagamento;"
+	query := "DELETE FROM Venda WHERE id_venda = $1 RETURNING id_venda, id_cliente, id_funcionario, data_hora_venda, data_hora_pagamento, COALESCE(tipo_pagamento::text, '');"
 
 	var venda model.Venda
 	row := s.db.QueryRowContext(ctx, query, id)
@@ -85,3 +131,240 @@ func (s *Store) Delete(ctx context.Context, id int64) (*model.Venda, error) {
 	}
 	return &venda, nil
 }
+
+// Cria a venda, seus itens, as ofertas aplicadas e os pagamentos em uma única transação.
+// Qualquer falha desfaz todas as inserções.
+// Sem pagamentos no payload, data_hora_pagamento com tipo_pagamento quita a venda
+// com um único pagamento do total líquido. Fiado não quita a venda.
+func (s *Store) CreateCompleta(ctx context.Context, props *model.VendaCompletaCreate) (*model.VendaCompleta, error) {
+	if len(props.Itens) == 0 {
+		return nil, errors.New("a venda precisa de pelo menos um item")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	venda := model.VendaCompleta{
+		Venda:      props.ToVenda(),
+		Itens:      make([]model.ItemVenda, 0, len(props.Itens)),
+		Ofertas:    make([]model.AplicaOferta, 0),
+		Pagamentos: make([]model.Pagamento, 0, len(props.Pagamentos)),
+	}
+
+	pagamentos := make([]model.Pagamento, 0, len(props.Pagamentos))
+	for i := range props.Pagamentos {
+		pagamentos = append(pagamentos, props.Pagamentos[i].ToPagamento(0))
+	}
+	// A quitação é feita pelos pagamentos
+	quitarTotal := len(pagamentos) == 0 && venda.DataHoraPagamento != nil
+	if quitarTotal && !pagamento.TipoAceito(venda.TipoPagamento) {
+		return nil, pagamento.ErrTipoPagamentoInvalido
+	}
+	venda.DataHoraPagamento = nil
+
+	queryVenda := `
+		INSERT INTO Venda (id_cliente, id_funcionario, tipo_pagamento)
+		VALUES ($1, $2, NULLIF($3, '')::tipo_de_pagamento)
+		RETURNING id_venda, data_hora_venda;`
+	row := tx.QueryRowContext(ctx, queryVenda, venda.IdCliente, venda.IdFuncionario, venda.TipoPagamento)
+	if err := row.Scan(&venda.Id, &venda.DataHoraVenda); err != nil {
+		return nil, err
+	}
+
+	// Linhas de cada oferta pedida, na ordem em que aparecem
+	ofertas := make([]int64, 0)
+	linhasOferta := make(map[int64][]int64)
+	for _, it := range props.Itens {
+		if it.Quantidade <= 0 {
+			return nil, fmt.Errorf("quantidade inválida para o produto %d", it.IdProduto)
+		}
+
+		// Um produto pode ser dividido em vários lotes (FIFO)
+		if it.ValorUnitario == nil {
+			return nil, fmt.Errorf("preço não resolvido para o produto %d", it.IdProduto)
+		}
+		itens, err := item_venda.AlocarLotes(ctx, tx, venda.Id, it.IdProduto, it.Quantidade, *it.ValorUnitario, it.PrecoOverride)
+		if err != nil {
+			return nil, err
+		}
+		venda.Itens = append(venda.Itens, itens...)
+
+		if it.IdOferta == nil {
+			continue
+		}
+		if _, ok := linhasOferta[*it.IdOferta]; !ok {
+			ofertas = append(ofertas, *it.IdOferta)
+		}
+		for _, item := range itens {
+			linhasOferta[*it.IdOferta] = append(linhasOferta[*it.IdOferta], item.IDItemVenda)
+		}
+	}
+
+	// O desconto de cada linha vem do motor de ofertas, já com todos os itens na venda
+	queryOferta := "INSERT INTO aplica_oferta (id_oferta, id_venda, id_item_venda, desconto) VALUES ($1, $2, $3, $4) RETURNING id_aplica_oferta;"
+	for _, idOferta := range ofertas {
+		descontos, err := desconto.DescontosOferta(ctx, tx, venda.Id, idOferta)
+		if err != nil {
+			return nil, err
+		}
+		for _, idItem := range linhasOferta[idOferta] {
+			d, ok := descontos[idItem]
+			if !ok {
+				return nil, fmt.Errorf("%w (oferta %d)", desconto.ErrOfertaNaoAplicavel, idOferta)
+			}
+			oferta := model.AplicaOferta{
+				IDOferta:    idOferta,
+				IDVenda:     venda.Id,
+				IDItemVenda: idItem,
+				Desconto:    d,
+			}
+			err = tx.QueryRowContext(ctx, queryOferta, oferta.IDOferta, oferta.IDVenda, oferta.IDItemVenda, oferta.Desconto).Scan(&oferta.IDAplicaOferta)
+			if err != nil {
+				return nil, err
+			}
+			venda.Ofertas = append(venda.Ofertas, oferta)
+		}
+	}
+
+	if quitarTotal {
+		t, err := totais.Venda(ctx, tx, venda.Id)
+		if err != nil {
+			return nil, err
+		}
+		// Venda de total zero não tem o que pagar, só é quitada
+		if t.Restante > 0 {
+			pagamentos = append(pagamentos, model.Pagamento{
+				TipoPagamento: props.TipoPagamento,
+				Valor:         t.Restante,
+				DataHora:      *props.DataHoraPagamento,
+			})
+		} else if quitada, err := fidelidade.Quitar(ctx, tx, venda.Id, *props.DataHoraPagamento); err != nil {
+			return nil, err
+		} else if quitada {
+			venda.DataHoraPagamento = props.DataHoraPagamento
+		}
+	}
+	for _, p := range pagamentos {
+		p.IDVenda = venda.Id
+		t, err := pagamento.Registrar(ctx, tx, &p)
+		if err != nil {
+			return nil, err
+		}
+		if t.StatusPagamento == model.StatusPagamentoPago {
+			venda.DataHoraPagamento = &p.DataHora
+		}
+		venda.Pagamentos = append(venda.Pagamentos, p)
+	}
+	// Só o que ficou em aberto conta para o limite de crédito
+	if err := cliente.ConferirLimiteVenda(ctx, tx, venda.Id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &venda, nil
+}
+
+// Totais da venda a partir da view venda_totais.
+func (s *Store) GetTotais(ctx context.Context, id int64) (*model.VendaTotais, error) {
+	return totais.Venda(ctx, s.db, id)
+}
+
+// Vendas do cliente com totais, no formato filtrado pelo util.Filter
+const selectHistorico = `
+	SELECT v.id_venda, v.id_cliente, v.id_funcionario, v.data_hora_venda, v.data_hora_pagamento,
+		COALESCE(v.tipo_pagamento::text, '') AS tipo_pagamento,
+		` + totais.Colunas + `
+	FROM Venda v
+	JOIN venda_totais vt ON vt.id_venda = v.id_venda
+	WHERE v.id_cliente = $1`
+
+// Histórico do cliente sem os itens, que o handler busca por venda.
+// top limita a quantidade de produtos favoritos.
+func (s *Store) GetHistoricoCliente(ctx context.Context, idCliente int64, filter util.Filter, top int) (*HistoricoCliente, error) {
+	var existe bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Cliente WHERE id_cliente = $1)", idCliente).Scan(&existe); err != nil {
+		return nil, err
+	}
+	if !existe {
+		return nil, types.ErrNotFound
+	}
+
+	h := HistoricoCliente{
+		IDCliente: idCliente,
+		Favoritos: make([]ProdutoFavorito, 0),
+		Vendas:    make([]VendaHistorico, 0),
+	}
+
+	// Indicadores e favoritos usam só as condições do filtro, sem ordenação nem paginação
+	periodo := util.Filter{Filters: filter.Filters}
+	values := []any{idCliente}
+	query := `
+		SELECT COALESCE(SUM(x.total_liquido), 0)::float8, COUNT(*), MAX(x.data_hora_venda)
+		FROM (` + selectHistorico + `) AS x` + periodo.ToQuery(&values, "x")
+	if err := s.db.QueryRowContext(ctx, query, values...).Scan(&h.TotalGasto, &h.Visitas, &h.UltimaVisita); err != nil {
+		return nil, err
+	}
+	if h.Visitas > 0 {
+		h.TicketMedio = math.Round(h.TotalGasto/float64(h.Visitas)*100) / 100
+	}
+
+	values = []any{idCliente}
+	query = `
+		SELECT p.id_produto, p.nome, p.marca, SUM(iv.quantidade),
+			SUM(iv.quantidade * iv.valor_unitario - COALESCE(ao.desconto, 0))::float8,
+			COUNT(DISTINCT iv.id_venda)
+		FROM (` + selectHistorico + `) AS x
+		JOIN item_venda iv ON iv.id_venda = x.id_venda
+		JOIN Produto p ON p.id_produto = iv.id_produto
+		LEFT JOIN (
+			SELECT id_item_venda, SUM(desconto) AS desconto
+			FROM aplica_oferta
+			GROUP BY id_item_venda
+		) ao ON ao.id_item_venda = iv.id_item_venda` + periodo.ToQuery(&values, "x")
+	values = append(values, top)
+	query += fmt.Sprintf(`
+		GROUP BY p.id_produto, p.nome, p.marca
+		ORDER BY SUM(iv.quantidade) DESC, 5 DESC, p.nome
+		LIMIT $%d`, len(values))
+	rows, err := s.db.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f ProdutoFavorito
+		if err := rows.Scan(&f.IDProduto, &f.Nome, &f.Marca, &f.Quantidade, &f.Valor, &f.Vendas); err != nil {
+			return nil, err
+		}
+		h.Favoritos = append(h.Favoritos, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(filter.Sorts) == 0 {
+		filter.Sorts = []string{"-data_hora_venda"}
+	}
+	values = []any{idCliente}
+	query = "SELECT * FROM (" + selectHistorico + ") AS x" + filter.ToQuery(&values, "x")
+	rows, err = s.db.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v VendaHistorico
+		err := rows.Scan(&v.Id, &v.IdCliente, &v.IdFuncionario, &v.DataHoraVenda, &v.DataHoraPagamento, &v.TipoPagamento,
+			&v.TotalBruto, &v.TotalDesconto, &v.TotalLiquido, &v.TotalPago, &v.Restante, &v.StatusPagamento)
+		if err != nil {
+			return nil, err
+		}
+		h.Vendas = append(h.Vendas, v)
+	}
+	return &h, rows.Err()
+}
@@ -5,10 +5,36 @@ import "errors"
 var (
 	ErrNotFound = errors.New("Not found")
 	ErrInternalServer = errors.New("Internal error")
+	ErrEstoqueInsuficiente = errors.New("Estoque insuficiente")
+	ErrPrecoDivergente = errors.New("Preço diferente do catálogo")
+	ErrLimiteCredito = errors.New("Limite de crédito do cliente excedido")
+	ErrDocumentoDuplicado = errors.New("Documento já cadastrado")
+	ErrAnonimizado = errors.New("Registro anonimizado pela LGPD não pode ser alterado")
+)
+
+// Problema em um campo do corpo da requisição
+type ErroCampo struct {
+	Campo    string `json:"campo"`
+	Mensagem string `json:"mensagem"`
+}
+
+// Códigos estáveis do campo `code`, para os clientes tratarem o erro sem depender do texto
+const (
+	CodigoDadosInvalidos = "dados_invalidos"
+	CodigoReferenciaInexistente = "referencia_inexistente"
+	CodigoRegistroEmUso = "registro_em_uso"
+	CodigoRegistroDuplicado = "registro_duplicado"
+	CodigoRestricaoViolada = "restricao_violada"
+	CodigoCampoObrigatorio = "campo_obrigatorio"
+	CodigoFormatoInvalido = "formato_invalido"
+	CodigoValorForaDoLimite = "valor_fora_do_limite"
+	CodigoConflitoConcorrencia = "conflito_concorrencia"
 )
 
 type ErrorResponse struct {
 	Message string `json:"detail"`
+	Code string `json:"code,omitempty"`
+	Erros []ErroCampo `json:"erros,omitempty"`
 }
 
 func NewErrorResponse(msg string) ErrorResponse {
@@ -0,0 +1,113 @@
+package util
+
+import (
+	"edna/internal/types"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Erro do Postgres traduzido para a resposta da API
+type ErroBanco struct {
+	Status   int
+	Codigo   string
+	Mensagem string
+}
+
+var (
+	// Key (id_fornecedor)=(99) is not present in table "fornecedor".
+	reChave  = regexp.MustCompile(`Key \((.+?)\)=\((.*?)\)`)
+	reTabela = regexp.MustCompile(`table "(\w+)"`)
+	// invalid input value for enum tipo_de_pagamento: "cheque"
+	reEnum = regexp.MustCompile(`for enum (\w+): "(.*)"`)
+	// invalid input syntax for type integer: "abc"
+	reSintaxe = regexp.MustCompile(`for type ([\w ]+): "(.*)"`)
+)
+
+// Traduz as violações de restrição e os erros de formato do Postgres.
+// Outros erros (conexão, timeout, bugs de SQL) não são traduzidos.
+func TraduzirErroBanco(err error) (*ErroBanco, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil, false
+	}
+
+	switch pgErr.Code {
+	case "23503": // foreign_key_violation
+		coluna, valor := chave(pgErr.Detail)
+		tabela := nomeTabela(pgErr.Detail)
+		// Na exclusão o registro ainda é referenciado; na inclusão a referência não existe
+		if strings.Contains(pgErr.Detail, "still referenced") {
+			return &ErroBanco{http.StatusConflict, types.CodigoRegistroEmUso,
+				fmt.Sprintf("Registro ainda é usado em %s (%s=%s)", tabela, coluna, valor)}, true
+		}
+		return &ErroBanco{http.StatusNotFound, types.CodigoReferenciaInexistente,
+			fmt.Sprintf("Não existe %s com %s=%s", tabela, coluna, valor)}, true
+	case "23505": // unique_violation
+		coluna, valor := chave(pgErr.Detail)
+		return &ErroBanco{http.StatusConflict, types.CodigoRegistroDuplicado,
+			fmt.Sprintf("Já existe um registro com %s=%s", coluna, valor)}, true
+	case "23P01": // exclusion_violation
+		return &ErroBanco{http.StatusConflict, types.CodigoRegistroDuplicado,
+			fmt.Sprintf("Conflito com um registro existente (%s)", pgErr.ConstraintName)}, true
+	case "23514": // check_violation
+		return &ErroBanco{http.StatusBadRequest, types.CodigoRestricaoViolada,
+			fmt.Sprintf("Valor não atende à restrição %s", pgErr.ConstraintName)}, true
+	case "23502": // not_null_violation
+		return &ErroBanco{http.StatusBadRequest, types.CodigoCampoObrigatorio,
+			fmt.Sprintf("Campo %s é obrigatório", pgErr.ColumnName)}, true
+	case "22P02": // invalid_text_representation
+		if m := reEnum.FindStringSubmatch(pgErr.Message); m != nil {
+			return &ErroBanco{http.StatusBadRequest, types.CodigoFormatoInvalido,
+				fmt.Sprintf("Valor %q inválido para %s", m[2], m[1])}, true
+		}
+		if m := reSintaxe.FindStringSubmatch(pgErr.Message); m != nil {
+			return &ErroBanco{http.StatusBadRequest, types.CodigoFormatoInvalido,
+				fmt.Sprintf("Valor %q não é um %s válido", m[2], m[1])}, true
+		}
+		return &ErroBanco{http.StatusBadRequest, types.CodigoFormatoInvalido, "Valor em formato inválido"}, true
+	case "22007", "22008": // invalid_datetime_format, datetime_field_overflow
+		return &ErroBanco{http.StatusBadRequest, types.CodigoFormatoInvalido, "Data ou hora inválida"}, true
+	case "22001": // string_data_right_truncation
+		return &ErroBanco{http.StatusBadRequest, types.CodigoValorForaDoLimite, "Texto maior que o tamanho do campo"}, true
+	case "22003": // numeric_value_out_of_range
+		return &ErroBanco{http.StatusBadRequest, types.CodigoValorForaDoLimite, "Valor numérico fora do limite do campo"}, true
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return &ErroBanco{http.StatusConflict, types.CodigoConflitoConcorrencia,
+			"Conflito com outra operação em andamento, tente novamente"}, true
+	}
+	return nil, false
+}
+
+func chave(detail string) (string, string) {
+	m := reChave.FindStringSubmatch(detail)
+	if m == nil {
+		return "", ""
+	}
+	return m[1], m[2]
+}
+
+func nomeTabela(detail string) string {
+	m := reTabela.FindStringSubmatch(detail)
+	if m == nil {
+		return "registro"
+	}
+	return m[1]
+}
+
+// / Escreve o erro do banco traduzido, com o `code` correspondente. Erros que não
+// / são do Postgres (ou não são traduzidos) saem com a mensagem e o status passado.
+func ErrorBancoJSON(w http.ResponseWriter, err error, status int) {
+	eb, ok := TraduzirErroBanco(err)
+	if !ok {
+		ErrorJSON(w, err.Error(), status)
+		return
+	}
+	res := types.NewErrorResponse(eb.Mensagem)
+	res.Code = eb.Codigo
+	writeErrorResponse(w, res, eb.Status)
+}
@@ -0,0 +1,57 @@
+package util
+
+import (
+	"edna/internal/types"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTraduzirErroBanco(t *testing.T) {
+	cases := []struct {
+		err      *pgconn.PgError
+		status   int
+		codigo   string
+		mensagem string
+	}{
+		{
+			&pgconn.PgError{Code: "23503", Detail: `Key (id_fornecedor)=(99) is not present in table "fornecedor".`},
+			http.StatusNotFound, types.CodigoReferenciaInexistente, "Não existe fornecedor com id_fornecedor=99",
+		},
+		{
+			&pgconn.PgError{Code: "23503", Detail: `Key (id_cliente)=(1) is still referenced from table "venda".`},
+			http.StatusConflict, types.CodigoRegistroEmUso, "Registro ainda é usado em venda (id_cliente=1)",
+		},
+		{
+			&pgconn.PgError{Code: "23505", Detail: `Key (cnpj)=(11222333000181) already exists.`},
+			http.StatusConflict, types.CodigoRegistroDuplicado, "Já existe um registro com cnpj=11222333000181",
+		},
+		{
+			&pgconn.PgError{Code: "23514", ConstraintName: "lote_preco_unitario_check"},
+			http.StatusBadRequest, types.CodigoRestricaoViolada, "Valor não atende à restrição lote_preco_unitario_check",
+		},
+		{
+			&pgconn.PgError{Code: "22P02", Message: `invalid input value for enum tipo_de_pagamento: "cheque"`},
+			http.StatusBadRequest, types.CodigoFormatoInvalido, `Valor "cheque" inválido para tipo_de_pagamento`,
+		},
+		{
+			&pgconn.PgError{Code: "22P02", Message: `invalid input syntax for type integer: "abc"`},
+			http.StatusBadRequest, types.CodigoFormatoInvalido, `Valor "abc" não é um integer válido`,
+		},
+	}
+	for _, c := range cases {
+		eb, ok := TraduzirErroBanco(fmt.Errorf("contexto: %w", c.err))
+		if !ok || eb.Status != c.status || eb.Codigo != c.codigo || eb.Mensagem != c.mensagem {
+			t.Fatalf("%s: expected %d %s %q, got %+v", c.err.Code, c.status, c.codigo, c.mensagem, eb)
+		}
+	}
+
+	if _, ok := TraduzirErroBanco(&pgconn.PgError{Code: "42601"}); ok {
+		t.Fatalf("syntax errors must not be translated")
+	}
+	if _, ok := TraduzirErroBanco(types.ErrNotFound); ok {
+		t.Fatalf("non Postgres errors must not be translated")
+	}
+}
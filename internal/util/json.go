@@ -2,6 +2,7 @@ package util
 
 import (
 	"edna/internal/types"
+	"edna/internal/validacao"
 	"encoding/json"
 	"errors"
 	"log"
@@ -64,11 +65,15 @@ func GetComposedID(r *http.Request) (int64, int64, error) {
 
 // / Escreve uma mensagem de error com o status passado, o corpo da mensagem será em JSON
 func ErrorJSON(w http.ResponseWriter, msg string, status int) {
+	writeErrorResponse(w, types.NewErrorResponse(msg), status)
+}
+
+func writeErrorResponse(w http.ResponseWriter, body types.ErrorResponse, status int) {
 	w.Header().Add("X-Content-Type-Options", "nosniff")
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 
-	res, err := json.Marshal(types.NewErrorResponse(msg))
+	res, err := json.Marshal(body)
 	// Impossivel
 	if err != nil {
 		log.Printf("Error ao criar mensagem em json: %s", err)
@@ -77,3 +82,18 @@ func ErrorJSON(w http.ResponseWriter, msg string, status int) {
 	}
 	w.Write(res)
 }
+
+// / Responde 400 com a lista de campos inválidos. Erros que não vêm da validação
+// / viram só a mensagem.
+func ErrorValidacaoJSON(w http.ResponseWriter, err error) {
+	var ve *validacao.Erro
+	if !errors.As(err, &ve) {
+		ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res := types.NewErrorResponse("Dados inválidos")
+	res.Code = types.CodigoDadosInvalidos
+	res.Erros = ve.Campos
+	writeErrorResponse(w, res, http.StatusBadRequest)
+}
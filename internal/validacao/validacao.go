@@ -0,0 +1,116 @@
+// Validação dos corpos de requisição: acumula os problemas de cada campo
+// para devolver todos de uma vez, em vez de parar no primeiro.
+package validacao
+
+import (
+	"edna/internal/types"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Erro com a lista de campos inválidos
+type Erro struct {
+	Campos []types.ErroCampo
+}
+
+func (e *Erro) Error() string {
+	partes := make([]string, 0, len(e.Campos))
+	for _, c := range e.Campos {
+		partes = append(partes, c.Campo+": "+c.Mensagem)
+	}
+	return "Dados inválidos: " + strings.Join(partes, "; ")
+}
+
+// Acumulador de erros de validação
+type Erros struct {
+	campos []types.ErroCampo
+}
+
+func (e *Erros) Add(campo, mensagem string, args ...any) {
+	if len(args) > 0 {
+		mensagem = fmt.Sprintf(mensagem, args...)
+	}
+	e.campos = append(e.campos, types.ErroCampo{Campo: campo, Mensagem: mensagem})
+}
+
+// Texto não vazio (espaços não contam)
+func (e *Erros) Obrigatorio(campo, valor string) {
+	if strings.TrimSpace(valor) == "" {
+		e.Add(campo, "obrigatório")
+	}
+}
+
+// Limite de caracteres, igual ao tamanho da coluna no banco
+func (e *Erros) Tamanho(campo, valor string, max int) {
+	if utf8.RuneCountInString(valor) > max {
+		e.Add(campo, "máximo de %d caracteres", max)
+	}
+}
+
+// Referência a outro registro
+func (e *Erros) ID(campo string, id int64) {
+	if id <= 0 {
+		e.Add(campo, "obrigatório")
+	}
+}
+
+func (e *Erros) Positivo(campo string, valor float64) {
+	if valor <= 0 {
+		e.Add(campo, "deve ser maior que zero")
+	}
+}
+
+func (e *Erros) PositivoInt(campo string, valor int64) {
+	if valor <= 0 {
+		e.Add(campo, "deve ser maior que zero")
+	}
+}
+
+func (e *Erros) NaoNegativo(campo string, valor float64) {
+	if valor < 0 {
+		e.Add(campo, "não pode ser negativo")
+	}
+}
+
+// Valor de um enum do banco
+func (e *Erros) Enum(campo, valor string, aceitos ...string) {
+	if !slices.Contains(aceitos, valor) {
+		e.Add(campo, "deve ser um de: %s", strings.Join(aceitos, ", "))
+	}
+}
+
+// Início não pode ser depois do fim. Datas ausentes não são conferidas.
+func (e *Erros) Ordem(campoInicio string, inicio *time.Time, campoFim string, fim *time.Time) {
+	if inicio != nil && fim != nil && inicio.After(*fim) {
+		e.Add(campoFim, "não pode ser anterior a %s", campoInicio)
+	}
+}
+
+// Inclui os erros de um objeto aninhado, ex.: "itens[0]" + "quantidade".
+// Sem prefixo os campos entram como estão (structs embutidas).
+func (e *Erros) Incluir(prefixo string, err error) {
+	ve, ok := err.(*Erro)
+	if !ok {
+		if err != nil {
+			e.Add(prefixo, err.Error())
+		}
+		return
+	}
+	for _, c := range ve.Campos {
+		if prefixo != "" {
+			c.Campo = prefixo + "." + c.Campo
+		}
+		e.campos = append(e.campos, c)
+	}
+}
+
+// Nil quando não há erros
+func (e *Erros) Err() error {
+	if len(e.campos) == 0 {
+		return nil
+	}
+	return &Erro{Campos: e.campos}
+}
@@ -0,0 +1,43 @@
+package validacao
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestErros(t *testing.T) {
+	var v Erros
+	if v.Err() != nil {
+		t.Fatalf("expected nil without errors")
+	}
+
+	inicio := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	fim := inicio.AddDate(0, 0, -1)
+
+	v.Obrigatorio("nome", "  ")
+	v.Tamanho("marca", "ação", 4)
+	v.Tamanho("categoria", "bebidas", 3)
+	v.Positivo("preco", 0)
+	v.Enum("tipo", "gerente", "garcom", "caixa")
+	v.Ordem("data_inicio", &inicio, "data_fim", &fim)
+	v.Ordem("data_inicio", &inicio, "data_fim", nil)
+
+	var item Erros
+	item.PositivoInt("quantidade", -1)
+	v.Incluir("itens[0]", item.Err())
+
+	var ve *Erro
+	if !errors.As(v.Err(), &ve) {
+		t.Fatalf("expected *Erro, got %v", v.Err())
+	}
+	campos := []string{"nome", "categoria", "preco", "tipo", "data_fim", "itens[0].quantidade"}
+	if len(ve.Campos) != len(campos) {
+		t.Fatalf("expected %d errors, got %v", len(campos), ve.Campos)
+	}
+	for i, c := range campos {
+		if ve.Campos[i].Campo != c {
+			t.Fatalf("error %d: expected %q, got %q", i, c, ve.Campos[i].Campo)
+		}
+	}
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS preco_override;
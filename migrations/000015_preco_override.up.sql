@@ -0,0 +1,9 @@
+-- Registra os itens vendidos com preço diferente do catálogo (ProdutoComercial.preco_venda)
+CREATE TABLE IF NOT EXISTS preco_override (
+    id_preco_override SERIAL PRIMARY KEY,
+    id_item_venda int NOT NULL REFERENCES item_venda(id_item_venda) ON DELETE CASCADE,
+    preco_catalogo decimal(6, 2) NOT NULL,
+    valor_unitario decimal(6, 2) NOT NULL,
+    motivo text NOT NULL,
+    data_hora timestamp NOT NULL DEFAULT now()
+);
@@ -0,0 +1 @@
+ALTER TABLE aplica_oferta DROP COLUMN IF EXISTS desconto;
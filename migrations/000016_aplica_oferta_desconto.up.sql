@@ -0,0 +1,24 @@
+-- Valor do desconto concedido pela oferta ao item (calculado pelo motor de ofertas)
+ALTER TABLE aplica_oferta ADD COLUMN IF NOT EXISTS desconto decimal(8, 2) NOT NULL DEFAULT 0;
+
+-- Preenche o desconto das aplicações já existentes (dados iniciais), como o motor
+-- faria: percentual sobre o bruto do item, e o valor fixo repartido entre os itens
+-- da oferta na venda proporcionalmente ao valor de cada um.
+UPDATE aplica_oferta ao
+SET desconto = ROUND(CASE
+        WHEN o.valor_fixo IS NOT NULL THEN
+            GREATEST(b.bruto_oferta - o.valor_fixo, 0) * b.bruto_item / NULLIF(b.bruto_oferta, 0)
+        WHEN o.percentual_desconto IS NOT NULL THEN
+            b.bruto_item * o.percentual_desconto / 100
+        ELSE 0
+    END, 2)
+FROM Oferta o, (
+    SELECT a.id_aplica_oferta,
+        iv.quantidade * iv.valor_unitario AS bruto_item,
+        SUM(iv.quantidade * iv.valor_unitario) OVER (PARTITION BY a.id_venda, a.id_oferta) AS bruto_oferta
+    FROM aplica_oferta a
+    JOIN item_venda iv ON iv.id_item_venda = a.id_item_venda
+) b
+WHERE o.id_oferta = ao.id_oferta
+  AND b.id_aplica_oferta = ao.id_aplica_oferta
+  AND ao.desconto = 0;
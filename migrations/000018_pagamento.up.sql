@@ -0,0 +1,48 @@
+-- Pagamentos de uma venda. Uma venda pode ser paga em várias partes e formas.
+-- A venda é quitada (Venda.data_hora_pagamento) quando os pagamentos cobrem o total líquido.
+CREATE TABLE IF NOT EXISTS pagamento (
+    id_pagamento SERIAL PRIMARY KEY,
+    id_venda int NOT NULL REFERENCES Venda(id_venda) ON DELETE CASCADE,
+    tipo_pagamento tipo_de_pagamento NOT NULL,
+    valor decimal(8, 2) NOT NULL CHECK (valor > 0),
+    data_hora timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS pagamento_id_venda_idx ON pagamento(id_venda);
+
+-- Vendas já pagas antes desta migração ganham um pagamento com o total líquido
+INSERT INTO pagamento (id_venda, tipo_pagamento, valor, data_hora)
+SELECT vt.id_venda, v.tipo_pagamento, vt.total_liquido, vt.data_hora_pagamento
+FROM venda_totais vt
+JOIN Venda v ON v.id_venda = vt.id_venda
+WHERE vt.data_hora_pagamento IS NOT NULL
+    AND v.tipo_pagamento IS NOT NULL
+    AND v.tipo_pagamento <> 'fiado'
+    AND vt.total_liquido > 0;
+
+CREATE OR REPLACE VIEW venda_totais AS
+SELECT
+    v.id_venda,
+    v.id_cliente,
+    v.data_hora_venda,
+    v.data_hora_pagamento,
+    COALESCE(i.bruto, 0)::numeric(12, 2) AS total_bruto,
+    COALESCE(o.desconto, 0)::numeric(12, 2) AS total_desconto,
+    (COALESCE(i.bruto, 0) - COALESCE(o.desconto, 0))::numeric(12, 2) AS total_liquido,
+    COALESCE(p.pago, 0)::numeric(12, 2) AS total_pago
+FROM Venda v
+LEFT JOIN (
+    SELECT id_venda, SUM(quantidade * valor_unitario) AS bruto
+    FROM item_venda
+    GROUP BY id_venda
+) i ON i.id_venda = v.id_venda
+LEFT JOIN (
+    SELECT id_venda, SUM(desconto) AS desconto
+    FROM aplica_oferta
+    GROUP BY id_venda
+) o ON o.id_venda = v.id_venda
+LEFT JOIN (
+    SELECT id_venda, SUM(valor) AS pago
+    FROM pagamento
+    GROUP BY id_venda
+) p ON p.id_venda = v.id_venda;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS caixa_movimento;
+DROP TYPE IF EXISTS tipo_movimento_caixa;
+DROP TABLE IF EXISTS caixa_sessao;
@@ -0,0 +1,26 @@
+-- Sessões de caixa: abertura com fundo de troco, sangrias/suprimentos e fechamento com o valor contado.
+-- O bar tem um único caixa, então só uma sessão pode estar aberta por vez.
+CREATE TABLE IF NOT EXISTS caixa_sessao (
+    id_sessao SERIAL PRIMARY KEY,
+    id_funcionario int NOT NULL REFERENCES Funcionario(id_funcionario),
+    expediente tipo_de_expediente NOT NULL,
+    valor_abertura decimal(8, 2) NOT NULL CHECK (valor_abertura >= 0),
+    data_hora_abertura timestamp NOT NULL DEFAULT now(),
+    data_hora_fechamento timestamp,
+    valor_contado decimal(8, 2) CHECK (valor_contado >= 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS caixa_sessao_aberta_idx ON caixa_sessao ((true))
+    WHERE data_hora_fechamento IS NULL;
+
+DROP TYPE IF EXISTS tipo_movimento_caixa;
+CREATE TYPE tipo_movimento_caixa AS ENUM ('sangria', 'suprimento');
+
+CREATE TABLE IF NOT EXISTS caixa_movimento (
+    id_movimento SERIAL PRIMARY KEY,
+    id_sessao int NOT NULL REFERENCES caixa_sessao(id_sessao) ON DELETE CASCADE,
+    tipo tipo_movimento_caixa NOT NULL,
+    valor decimal(8, 2) NOT NULL CHECK (valor > 0),
+    motivo text,
+    data_hora timestamp NOT NULL DEFAULT now()
+);
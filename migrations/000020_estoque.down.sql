@@ -0,0 +1,3 @@
+DROP VIEW IF EXISTS estoque_movimento;
+DROP VIEW IF EXISTS estoque_produto;
+DROP VIEW IF EXISTS estoque_lote;
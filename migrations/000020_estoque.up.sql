@@ -0,0 +1,53 @@
+-- Estoque disponível por lote: inicial - estragados - vendidos.
+-- Fonte única usada pela alocação de vendas, produtos e o endpoint /estoque.
+CREATE OR REPLACE VIEW estoque_lote AS
+SELECT
+    l.id_lote,
+    l.id_produto,
+    l.id_fornecedor,
+    l.data_fornecimento,
+    l.validade,
+    COALESCE(l.quantidade_inicial, 0)::bigint AS quantidade_inicial,
+    COALESCE(l.estragados, 0)::bigint AS estragados,
+    COALESCE(v.vendidos, 0)::bigint AS vendidos,
+    (COALESCE(l.quantidade_inicial, 0) - COALESCE(l.estragados, 0) - COALESCE(v.vendidos, 0))::bigint AS disponivel,
+    (l.validade IS NOT NULL AND l.validade <= CURRENT_DATE) AS vencido
+FROM Lote l
+LEFT JOIN (
+    SELECT id_lote, SUM(quantidade) AS vendidos
+    FROM item_venda
+    GROUP BY id_lote
+) v ON v.id_lote = l.id_lote;
+
+-- Estoque por produto. Lotes vencidos não contam como disponíveis.
+CREATE OR REPLACE VIEW estoque_produto AS
+SELECT
+    p.id_produto,
+    p.nome,
+    p.categoria,
+    p.marca,
+    COALESCE(SUM(el.disponivel) FILTER (WHERE NOT el.vencido), 0)::bigint AS disponivel,
+    COALESCE(SUM(el.disponivel) FILTER (WHERE el.vencido), 0)::bigint AS vencido,
+    COUNT(el.id_lote) FILTER (WHERE el.disponivel > 0 AND NOT el.vencido)::bigint AS lotes
+FROM Produto p
+LEFT JOIN estoque_lote el ON el.id_produto = p.id_produto
+GROUP BY p.id_produto;
+
+-- Histórico de movimentos: entradas positivas, saídas negativas.
+CREATE OR REPLACE VIEW estoque_movimento AS
+SELECT l.id_lote, l.id_produto, 'entrada'::text AS tipo,
+    l.data_fornecimento::timestamp AS data_hora, l.quantidade_inicial::bigint AS quantidade, NULL::int AS id_venda
+FROM Lote l
+WHERE COALESCE(l.quantidade_inicial, 0) > 0
+UNION ALL
+SELECT iv.id_lote, l.id_produto, 'venda'::text,
+    v.data_hora_venda, -iv.quantidade::bigint, iv.id_venda
+FROM item_venda iv
+JOIN Lote l ON l.id_lote = iv.id_lote
+JOIN Venda v ON v.id_venda = iv.id_venda
+UNION ALL
+-- Estragados só têm o total do lote, registrados na data de fornecimento
+SELECT l.id_lote, l.id_produto, 'perda'::text,
+    l.data_fornecimento::timestamp, -l.estragados::bigint, NULL::int
+FROM Lote l
+WHERE COALESCE(l.estragados, 0) > 0;
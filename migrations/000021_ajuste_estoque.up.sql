@@ -0,0 +1,92 @@
+-- Ajustes de estoque de um lote: cada perda ou correção vira uma linha com motivo,
+-- funcionário e horário. Lote.estragados passa a ser derivado dessas linhas.
+DROP TYPE IF EXISTS motivo_ajuste;
+CREATE TYPE motivo_ajuste AS ENUM ('quebra', 'vencimento', 'furto', 'degustacao', 'inventario');
+
+-- quantidade = unidades retiradas do estoque. Só correções de inventário podem
+-- ser negativas (unidades encontradas).
+CREATE TABLE IF NOT EXISTS ajuste_estoque (
+    id_ajuste SERIAL PRIMARY KEY,
+    id_lote int NOT NULL REFERENCES Lote(id_lote) ON DELETE CASCADE,
+    id_funcionario int REFERENCES Funcionario(id_funcionario) ON DELETE SET NULL,
+    quantidade int NOT NULL CHECK (quantidade <> 0),
+    motivo motivo_ajuste NOT NULL,
+    observacao text,
+    data_hora timestamp NOT NULL DEFAULT now(),
+
+    CHECK (quantidade > 0 OR motivo = 'inventario')
+);
+
+CREATE INDEX IF NOT EXISTS ajuste_estoque_id_lote_idx ON ajuste_estoque(id_lote);
+
+-- Estragados já registrados viram um ajuste sem funcionário na data do fornecimento
+INSERT INTO ajuste_estoque (id_lote, quantidade, motivo, observacao, data_hora)
+SELECT id_lote, estragados, 'quebra', 'migrado de Lote.estragados', data_fornecimento::timestamp
+FROM Lote
+WHERE COALESCE(estragados, 0) > 0;
+
+DROP VIEW IF EXISTS estoque_movimento;
+DROP VIEW IF EXISTS estoque_produto;
+DROP VIEW IF EXISTS estoque_lote;
+
+ALTER TABLE Lote DROP COLUMN IF EXISTS estragados;
+
+CREATE VIEW estoque_lote AS
+SELECT
+    l.id_lote,
+    l.id_produto,
+    l.id_fornecedor,
+    l.data_fornecimento,
+    l.validade,
+    COALESCE(l.quantidade_inicial, 0)::bigint AS quantidade_inicial,
+    COALESCE(a.estragados, 0)::bigint AS estragados,
+    COALESCE(a.ajustes, 0)::bigint AS ajustes,
+    COALESCE(v.vendidos, 0)::bigint AS vendidos,
+    (COALESCE(l.quantidade_inicial, 0) - COALESCE(a.estragados, 0) - COALESCE(a.ajustes, 0) - COALESCE(v.vendidos, 0))::bigint AS disponivel,
+    (l.validade IS NOT NULL AND l.validade <= CURRENT_DATE) AS vencido
+FROM Lote l
+LEFT JOIN (
+    SELECT id_lote, SUM(quantidade) AS vendidos
+    FROM item_venda
+    GROUP BY id_lote
+) v ON v.id_lote = l.id_lote
+LEFT JOIN (
+    SELECT id_lote,
+        SUM(quantidade) FILTER (WHERE motivo <> 'inventario') AS estragados,
+        SUM(quantidade) FILTER (WHERE motivo = 'inventario') AS ajustes
+    FROM ajuste_estoque
+    GROUP BY id_lote
+) a ON a.id_lote = l.id_lote;
+
+CREATE VIEW estoque_produto AS
+SELECT
+    p.id_produto,
+    p.nome,
+    p.categoria,
+    p.marca,
+    COALESCE(SUM(el.disponivel) FILTER (WHERE NOT el.vencido), 0)::bigint AS disponivel,
+    COALESCE(SUM(el.disponivel) FILTER (WHERE el.vencido), 0)::bigint AS vencido,
+    COUNT(el.id_lote) FILTER (WHERE el.disponivel > 0 AND NOT el.vencido)::bigint AS lotes
+FROM Produto p
+LEFT JOIN estoque_lote el ON el.id_produto = p.id_produto
+GROUP BY p.id_produto;
+
+-- Perdas e correções de inventário vêm dos ajustes, com o horário real
+CREATE VIEW estoque_movimento AS
+SELECT l.id_lote, l.id_produto, 'entrada'::text AS tipo,
+    l.data_fornecimento::timestamp AS data_hora, l.quantidade_inicial::bigint AS quantidade,
+    NULL::int AS id_venda, NULL::text AS motivo
+FROM Lote l
+WHERE COALESCE(l.quantidade_inicial, 0) > 0
+UNION ALL
+SELECT iv.id_lote, l.id_produto, 'venda'::text,
+    v.data_hora_venda, -iv.quantidade::bigint, iv.id_venda, NULL::text
+FROM item_venda iv
+JOIN Lote l ON l.id_lote = iv.id_lote
+JOIN Venda v ON v.id_venda = iv.id_venda
+UNION ALL
+SELECT a.id_lote, l.id_produto,
+    CASE WHEN a.motivo = 'inventario' THEN 'ajuste' ELSE 'perda' END,
+    a.data_hora, -a.quantidade::bigint, NULL::int, a.motivo::text
+FROM ajuste_estoque a
+JOIN Lote l ON l.id_lote = a.id_lote;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS alerta_validade;
@@ -0,0 +1,11 @@
+-- Alertas de lotes perto do vencimento, registrados pela rotina diária do servidor.
+-- Um alerta por lote, atualizado a cada execução até ser visto ou o lote zerar.
+CREATE TABLE IF NOT EXISTS alerta_validade (
+    id_alerta SERIAL PRIMARY KEY,
+    id_lote int NOT NULL UNIQUE REFERENCES Lote(id_lote) ON DELETE CASCADE,
+    validade date NOT NULL,
+    quantidade bigint NOT NULL,
+    criado_em timestamp NOT NULL DEFAULT now(),
+    atualizado_em timestamp NOT NULL DEFAULT now(),
+    visto_em timestamp
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS reposicao_produto;
@@ -0,0 +1,7 @@
+-- Ponto de reposição de cada produto: abaixo do estoque mínimo o produto entra
+-- nas sugestões de compra com pelo menos a quantidade de reposição.
+CREATE TABLE IF NOT EXISTS reposicao_produto (
+    id_produto int PRIMARY KEY REFERENCES Produto(id_produto) ON DELETE CASCADE,
+    estoque_minimo int NOT NULL CHECK (estoque_minimo >= 0),
+    quantidade_reposicao int NOT NULL CHECK (quantidade_reposicao > 0)
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS recebimento_compra;
+DROP TABLE IF EXISTS item_pedido_compra;
+DROP TABLE IF EXISTS pedido_compra;
+DROP TYPE IF EXISTS status_pedido_compra;
@@ -0,0 +1,34 @@
+DROP TYPE IF EXISTS status_pedido_compra;
+CREATE TYPE status_pedido_compra AS ENUM ('rascunho', 'enviado', 'parcialmente_recebido', 'recebido', 'cancelado');
+
+CREATE TABLE IF NOT EXISTS pedido_compra (
+    id_pedido serial PRIMARY KEY,
+    id_fornecedor int NOT NULL REFERENCES Fornecedor(id_fornecedor) ON DELETE CASCADE,
+    status status_pedido_compra NOT NULL DEFAULT 'rascunho',
+    observacao text,
+    data_previsao date,
+    data_criacao timestamp NOT NULL DEFAULT now(),
+    data_envio timestamp
+);
+
+CREATE INDEX IF NOT EXISTS pedido_compra_fornecedor_idx ON pedido_compra (id_fornecedor);
+
+-- Linhas do pedido com a quantidade e o preço unitário combinados com o fornecedor
+CREATE TABLE IF NOT EXISTS item_pedido_compra (
+    id_item_pedido serial PRIMARY KEY,
+    id_pedido int NOT NULL REFERENCES pedido_compra(id_pedido) ON DELETE CASCADE,
+    id_produto int NOT NULL REFERENCES Produto(id_produto) ON DELETE CASCADE,
+    quantidade int NOT NULL CHECK (quantidade > 0),
+    preco_unitario decimal(6, 2) NOT NULL CHECK (preco_unitario > 0),
+
+    UNIQUE (id_pedido, id_produto)
+);
+
+-- Cada recebimento de uma linha do pedido gera um Lote.
+-- A quantidade recebida é a quantidade_inicial do lote.
+CREATE TABLE IF NOT EXISTS recebimento_compra (
+    id_lote int PRIMARY KEY REFERENCES Lote(id_lote) ON DELETE CASCADE,
+    id_item_pedido int NOT NULL REFERENCES item_pedido_compra(id_item_pedido) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS recebimento_compra_item_idx ON recebimento_compra (id_item_pedido);
@@ -0,0 +1,108 @@
+-- Receitas (ficha técnica): produtos comerciais feitos de produtos estruturais.
+-- Vender um produto com receita retira os ingredientes dos lotes, o produto em si
+-- não tem lotes e o item_venda fica sem id_lote.
+CREATE TABLE IF NOT EXISTS receita (
+    id_produto int NOT NULL REFERENCES ProdutoComercial(id_produto) ON DELETE CASCADE,
+    id_ingrediente int NOT NULL REFERENCES Produto(id_produto) ON DELETE RESTRICT,
+    quantidade int NOT NULL CHECK (quantidade > 0), -- unidades do ingrediente por unidade vendida
+
+    PRIMARY KEY (id_produto, id_ingrediente),
+    CHECK (id_produto <> id_ingrediente)
+);
+
+-- item_venda passa a guardar o produto, já que itens de receita não têm lote
+ALTER TABLE item_venda ADD COLUMN IF NOT EXISTS id_produto int REFERENCES Produto(id_produto) ON DELETE RESTRICT;
+
+UPDATE item_venda iv SET id_produto = l.id_produto
+FROM Lote l
+WHERE l.id_lote = iv.id_lote;
+
+ALTER TABLE item_venda ALTER COLUMN id_produto SET NOT NULL;
+
+-- Ingredientes retirados de cada lote por um item de venda com receita
+CREATE TABLE IF NOT EXISTS consumo_receita (
+    id_consumo SERIAL PRIMARY KEY,
+    id_item_venda int NOT NULL REFERENCES item_venda(id_item_venda) ON DELETE CASCADE,
+    id_lote int NOT NULL REFERENCES Lote(id_lote) ON DELETE RESTRICT,
+    quantidade int NOT NULL CHECK (quantidade > 0)
+);
+
+CREATE INDEX IF NOT EXISTS consumo_receita_id_item_venda_idx ON consumo_receita(id_item_venda);
+CREATE INDEX IF NOT EXISTS consumo_receita_id_lote_idx ON consumo_receita(id_lote);
+
+DROP VIEW IF EXISTS estoque_movimento;
+DROP VIEW IF EXISTS estoque_produto;
+DROP VIEW IF EXISTS estoque_lote;
+
+-- vendidos inclui os ingredientes consumidos pelas receitas
+CREATE VIEW estoque_lote AS
+SELECT
+    l.id_lote,
+    l.id_produto,
+    l.id_fornecedor,
+    l.data_fornecimento,
+    l.validade,
+    COALESCE(l.quantidade_inicial, 0)::bigint AS quantidade_inicial,
+    COALESCE(a.estragados, 0)::bigint AS estragados,
+    COALESCE(a.ajustes, 0)::bigint AS ajustes,
+    COALESCE(v.vendidos, 0)::bigint AS vendidos,
+    (COALESCE(l.quantidade_inicial, 0) - COALESCE(a.estragados, 0) - COALESCE(a.ajustes, 0) - COALESCE(v.vendidos, 0))::bigint AS disponivel,
+    (l.validade IS NOT NULL AND l.validade <= CURRENT_DATE) AS vencido
+FROM Lote l
+LEFT JOIN (
+    SELECT id_lote, SUM(quantidade) AS vendidos
+    FROM (
+        SELECT id_lote, quantidade FROM item_venda WHERE id_lote IS NOT NULL
+        UNION ALL
+        SELECT id_lote, quantidade FROM consumo_receita
+    ) s
+    GROUP BY id_lote
+) v ON v.id_lote = l.id_lote
+LEFT JOIN (
+    SELECT id_lote,
+        SUM(quantidade) FILTER (WHERE motivo <> 'inventario') AS estragados,
+        SUM(quantidade) FILTER (WHERE motivo = 'inventario') AS ajustes
+    FROM ajuste_estoque
+    GROUP BY id_lote
+) a ON a.id_lote = l.id_lote;
+
+CREATE VIEW estoque_produto AS
+SELECT
+    p.id_produto,
+    p.nome,
+    p.categoria,
+    p.marca,
+    COALESCE(SUM(el.disponivel) FILTER (WHERE NOT el.vencido), 0)::bigint AS disponivel,
+    COALESCE(SUM(el.disponivel) FILTER (WHERE el.vencido), 0)::bigint AS vencido,
+    COUNT(el.id_lote) FILTER (WHERE el.disponivel > 0 AND NOT el.vencido)::bigint AS lotes
+FROM Produto p
+LEFT JOIN estoque_lote el ON el.id_produto = p.id_produto
+GROUP BY p.id_produto;
+
+-- Perdas e correções de inventário vêm dos ajustes, com o horário real.
+-- Ingredientes de receitas saem como consumo na hora da venda.
+CREATE VIEW estoque_movimento AS
+SELECT l.id_lote, l.id_produto, 'entrada'::text AS tipo,
+    l.data_fornecimento::timestamp AS data_hora, l.quantidade_inicial::bigint AS quantidade,
+    NULL::int AS id_venda, NULL::text AS motivo
+FROM Lote l
+WHERE COALESCE(l.quantidade_inicial, 0) > 0
+UNION ALL
+SELECT iv.id_lote, l.id_produto, 'venda'::text,
+    v.data_hora_venda, -iv.quantidade::bigint, iv.id_venda, NULL::text
+FROM item_venda iv
+JOIN Lote l ON l.id_lote = iv.id_lote
+JOIN Venda v ON v.id_venda = iv.id_venda
+UNION ALL
+SELECT a.id_lote, l.id_produto,
+    CASE WHEN a.motivo = 'inventario' THEN 'ajuste' ELSE 'perda' END,
+    a.data_hora, -a.quantidade::bigint, NULL::int, a.motivo::text
+FROM ajuste_estoque a
+JOIN Lote l ON l.id_lote = a.id_lote
+UNION ALL
+SELECT cr.id_lote, l.id_produto, 'consumo'::text,
+    v.data_hora_venda, -cr.quantidade::bigint, iv.id_venda, NULL::text
+FROM consumo_receita cr
+JOIN Lote l ON l.id_lote = cr.id_lote
+JOIN item_venda iv ON iv.id_item_venda = cr.id_item_venda
+JOIN Venda v ON v.id_venda = iv.id_venda;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS divergencia_inventario;
+DROP TABLE IF EXISTS contagem_inventario;
+DROP TABLE IF EXISTS inventario;
+DROP TYPE IF EXISTS status_inventario;
+-- O valor 'gerente' de tipo_de_funcionario fica, o Postgres não remove valores de enum
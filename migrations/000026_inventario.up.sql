@@ -0,0 +1,47 @@
+-- Gerentes aprovam os ajustes gerados pelo inventário
+ALTER TYPE tipo_de_funcionario ADD VALUE IF NOT EXISTS 'gerente';
+
+DROP TYPE IF EXISTS status_inventario;
+CREATE TYPE status_inventario AS ENUM ('aberto', 'fechado', 'aprovado', 'cancelado');
+
+-- Sessão de contagem física do estoque. Só um inventário pode estar em andamento
+-- (aberto ou aguardando aprovação) por vez.
+CREATE TABLE IF NOT EXISTS inventario (
+    id_inventario serial PRIMARY KEY,
+    id_funcionario int NOT NULL REFERENCES Funcionario(id_funcionario),
+    status status_inventario NOT NULL DEFAULT 'aberto',
+    observacao text,
+    data_abertura timestamp NOT NULL DEFAULT now(),
+    data_fechamento timestamp,
+    id_aprovador int REFERENCES Funcionario(id_funcionario),
+    data_aprovacao timestamp
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS inventario_andamento_idx ON inventario ((true))
+    WHERE status IN ('aberto', 'fechado');
+
+-- Quantidade contada de um produto inteiro (id_lote nulo) ou de um lote específico
+CREATE TABLE IF NOT EXISTS contagem_inventario (
+    id_contagem serial PRIMARY KEY,
+    id_inventario int NOT NULL REFERENCES inventario(id_inventario) ON DELETE CASCADE,
+    id_produto int NOT NULL REFERENCES Produto(id_produto) ON DELETE CASCADE,
+    id_lote int REFERENCES Lote(id_lote) ON DELETE CASCADE,
+    quantidade int NOT NULL CHECK (quantidade >= 0),
+    id_funcionario int REFERENCES Funcionario(id_funcionario) ON DELETE SET NULL,
+    data_hora timestamp NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS contagem_inventario_produto_idx ON contagem_inventario (id_inventario, id_produto)
+    WHERE id_lote IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS contagem_inventario_lote_idx ON contagem_inventario (id_inventario, id_lote)
+    WHERE id_lote IS NOT NULL;
+
+-- Divergências por lote congeladas no fechamento, com o estoque esperado naquele momento
+CREATE TABLE IF NOT EXISTS divergencia_inventario (
+    id_inventario int NOT NULL REFERENCES inventario(id_inventario) ON DELETE CASCADE,
+    id_lote int NOT NULL REFERENCES Lote(id_lote) ON DELETE CASCADE,
+    esperado int NOT NULL,
+    contado int NOT NULL CHECK (contado >= 0),
+
+    PRIMARY KEY (id_inventario, id_lote)
+);
@@ -0,0 +1 @@
+ALTER TABLE Cliente DROP COLUMN IF EXISTS limite_credito;
@@ -0,0 +1,2 @@
+-- Limite de fiado por cliente. Nulo é sem limite.
+ALTER TABLE Cliente ADD COLUMN IF NOT EXISTS limite_credito decimal(8, 2) CHECK (limite_credito >= 0);
@@ -0,0 +1,32 @@
+CREATE OR REPLACE VIEW venda_totais AS
+SELECT
+    v.id_venda,
+    v.id_cliente,
+    v.data_hora_venda,
+    v.data_hora_pagamento,
+    COALESCE(i.bruto, 0)::numeric(12, 2) AS total_bruto,
+    COALESCE(o.desconto, 0)::numeric(12, 2) AS total_desconto,
+    (COALESCE(i.bruto, 0) - COALESCE(o.desconto, 0))::numeric(12, 2) AS total_liquido,
+    COALESCE(p.pago, 0)::numeric(12, 2) AS total_pago
+FROM Venda v
+LEFT JOIN (
+    SELECT id_venda, SUM(quantidade * valor_unitario) AS bruto
+    FROM item_venda
+    GROUP BY id_venda
+) i ON i.id_venda = v.id_venda
+LEFT JOIN (
+    SELECT id_venda, SUM(desconto) AS desconto
+    FROM aplica_oferta
+    GROUP BY id_venda
+) o ON o.id_venda = v.id_venda
+LEFT JOIN (
+    SELECT id_venda, SUM(valor) AS pago
+    FROM pagamento
+    GROUP BY id_venda
+) p ON p.id_venda = v.id_venda;
+
+DROP TABLE IF EXISTS aplica_pontos;
+DROP TABLE IF EXISTS movimento_pontos;
+DROP TYPE IF EXISTS tipo_movimento_pontos;
+DROP TABLE IF EXISTS regra_fidelidade;
+DROP TABLE IF EXISTS config_fidelidade;
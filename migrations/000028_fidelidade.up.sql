@@ -0,0 +1,90 @@
+-- Configuração do programa de fidelidade (linha única): validade dos pontos e
+-- valor de cada ponto quando resgatado como desconto.
+CREATE TABLE IF NOT EXISTS config_fidelidade (
+    id boolean PRIMARY KEY DEFAULT true CHECK (id),
+    dias_validade int NOT NULL DEFAULT 365 CHECK (dias_validade > 0),
+    valor_ponto decimal(8, 4) NOT NULL DEFAULT 0.05 CHECK (valor_ponto > 0)
+);
+
+INSERT INTO config_fidelidade DEFAULT VALUES ON CONFLICT DO NOTHING;
+
+-- Pontos por real líquido vendido. A regra do produto vale sobre a da categoria,
+-- que vale sobre a regra padrão (sem produto e sem categoria).
+CREATE TABLE IF NOT EXISTS regra_fidelidade (
+    id_regra serial PRIMARY KEY,
+    id_produto int REFERENCES Produto(id_produto) ON DELETE CASCADE,
+    categoria text,
+    pontos_por_real decimal(6, 2) NOT NULL CHECK (pontos_por_real >= 0),
+
+    CHECK (id_produto IS NULL OR categoria IS NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS regra_fidelidade_produto_idx ON regra_fidelidade (id_produto)
+    WHERE id_produto IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS regra_fidelidade_categoria_idx ON regra_fidelidade (categoria)
+    WHERE categoria IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS regra_fidelidade_padrao_idx ON regra_fidelidade ((true))
+    WHERE id_produto IS NULL AND categoria IS NULL;
+
+INSERT INTO regra_fidelidade (pontos_por_real) VALUES (1);
+
+DROP TYPE IF EXISTS tipo_movimento_pontos;
+CREATE TYPE tipo_movimento_pontos AS ENUM ('acumulo', 'resgate', 'ajuste');
+
+-- Extrato de pontos do cliente. Créditos são positivos e valem até a validade,
+-- débitos são negativos. Expirações são calculadas a partir das validades.
+CREATE TABLE IF NOT EXISTS movimento_pontos (
+    id_movimento serial PRIMARY KEY,
+    id_cliente int NOT NULL REFERENCES Cliente(id_cliente) ON DELETE CASCADE,
+    id_venda int REFERENCES Venda(id_venda) ON DELETE CASCADE,
+    tipo tipo_movimento_pontos NOT NULL,
+    pontos int NOT NULL CHECK (pontos <> 0),
+    validade date,
+    motivo text,
+    id_funcionario int REFERENCES Funcionario(id_funcionario) ON DELETE SET NULL,
+    data_hora timestamp NOT NULL DEFAULT now(),
+
+    CHECK (pontos < 0 OR validade IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS movimento_pontos_cliente_idx ON movimento_pontos (id_cliente);
+CREATE UNIQUE INDEX IF NOT EXISTS movimento_pontos_acumulo_idx ON movimento_pontos (id_venda)
+    WHERE tipo = 'acumulo';
+
+-- Pontos resgatados como desconto numa venda, ao lado de aplica_oferta
+CREATE TABLE IF NOT EXISTS aplica_pontos (
+    id_aplica_pontos serial PRIMARY KEY,
+    id_venda int NOT NULL UNIQUE REFERENCES Venda(id_venda) ON DELETE CASCADE,
+    id_movimento int NOT NULL REFERENCES movimento_pontos(id_movimento) ON DELETE CASCADE,
+    pontos int NOT NULL CHECK (pontos > 0),
+    desconto decimal(8, 2) NOT NULL CHECK (desconto > 0)
+);
+
+-- O desconto dos pontos entra no total_desconto da venda
+CREATE OR REPLACE VIEW venda_totais AS
+SELECT
+    v.id_venda,
+    v.id_cliente,
+    v.data_hora_venda,
+    v.data_hora_pagamento,
+    COALESCE(i.bruto, 0)::numeric(12, 2) AS total_bruto,
+    (COALESCE(o.desconto, 0) + COALESCE(ap.desconto, 0))::numeric(12, 2) AS total_desconto,
+    (COALESCE(i.bruto, 0) - COALESCE(o.desconto, 0) - COALESCE(ap.desconto, 0))::numeric(12, 2) AS total_liquido,
+    COALESCE(p.pago, 0)::numeric(12, 2) AS total_pago
+FROM Venda v
+LEFT JOIN (
+    SELECT id_venda, SUM(quantidade * valor_unitario) AS bruto
+    FROM item_venda
+    GROUP BY id_venda
+) i ON i.id_venda = v.id_venda
+LEFT JOIN (
+    SELECT id_venda, SUM(desconto) AS desconto
+    FROM aplica_oferta
+    GROUP BY id_venda
+) o ON o.id_venda = v.id_venda
+LEFT JOIN aplica_pontos ap ON ap.id_venda = v.id_venda
+LEFT JOIN (
+    SELECT id_venda, SUM(valor) AS pago
+    FROM pagamento
+    GROUP BY id_venda
+) p ON p.id_venda = v.id_venda;
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS solicitacao_lgpd;
+DROP TYPE IF EXISTS acao_lgpd;
+DROP TYPE IF EXISTS titular_lgpd;
+
+ALTER TABLE Venda DROP CONSTRAINT IF EXISTS venda_id_cliente_fkey;
+ALTER TABLE Venda ADD CONSTRAINT venda_id_cliente_fkey
+    FOREIGN KEY (id_cliente) REFERENCES Cliente(id_cliente) ON DELETE CASCADE;
+
+UPDATE Funcionario SET CPF = '00000000000' WHERE CPF IS NULL;
+ALTER TABLE Funcionario ALTER COLUMN CPF SET NOT NULL;
+ALTER TABLE Funcionario DROP COLUMN IF EXISTS anonimizado_em;
+ALTER TABLE Cliente DROP COLUMN IF EXISTS anonimizado_em;
@@ -0,0 +1,29 @@
+-- LGPD: anonimização de titulares mantendo o histórico de vendas e registro
+-- de cada exportação ou anonimização de dados pessoais.
+ALTER TABLE Cliente ADD COLUMN IF NOT EXISTS anonimizado_em timestamp;
+ALTER TABLE Funcionario ADD COLUMN IF NOT EXISTS anonimizado_em timestamp;
+ALTER TABLE Funcionario ALTER COLUMN CPF DROP NOT NULL;
+
+-- Apagar um cliente levava junto as vendas, pagamentos e itens. Clientes com
+-- vendas agora só podem ser anonimizados.
+ALTER TABLE Venda DROP CONSTRAINT IF EXISTS venda_id_cliente_fkey;
+ALTER TABLE Venda ADD CONSTRAINT venda_id_cliente_fkey
+    FOREIGN KEY (id_cliente) REFERENCES Cliente(id_cliente) ON DELETE RESTRICT;
+
+DROP TYPE IF EXISTS titular_lgpd;
+CREATE TYPE titular_lgpd AS ENUM ('cliente', 'funcionario');
+DROP TYPE IF EXISTS acao_lgpd;
+CREATE TYPE acao_lgpd AS ENUM ('exportacao', 'anonimizacao');
+
+-- Sem chave estrangeira para o titular: o registro fica mesmo se ele for apagado
+CREATE TABLE IF NOT EXISTS solicitacao_lgpd (
+    id_solicitacao serial PRIMARY KEY,
+    titular titular_lgpd NOT NULL,
+    id_titular int NOT NULL,
+    acao acao_lgpd NOT NULL,
+    id_funcionario int REFERENCES Funcionario(id_funcionario) ON DELETE SET NULL,
+    motivo text,
+    data_hora timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS solicitacao_lgpd_titular_idx ON solicitacao_lgpd (titular, id_titular);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS cobertura_minima;
+DROP TABLE IF EXISTS escala;
@@ -0,0 +1,32 @@
+-- Turno planejado de um funcionário. A madrugada de uma data é a continuação
+-- da noite: vai das 00:00 às 06:00 do dia seguinte.
+CREATE TABLE IF NOT EXISTS escala (
+    id_escala serial PRIMARY KEY,
+    id_funcionario int NOT NULL REFERENCES Funcionario(id_funcionario) ON DELETE CASCADE,
+    data date NOT NULL,
+    expediente tipo_de_expediente NOT NULL,
+    observacao text,
+
+    UNIQUE (id_funcionario, data, expediente)
+);
+
+CREATE INDEX IF NOT EXISTS escala_data_idx ON escala (data);
+
+-- Mínimo de funcionários de um tipo em cada turno, por dia da semana
+-- (0 = domingo, como EXTRACT(DOW))
+CREATE TABLE IF NOT EXISTS cobertura_minima (
+    dia_semana smallint NOT NULL CHECK (dia_semana BETWEEN 0 AND 6),
+    expediente tipo_de_expediente NOT NULL,
+    tipo tipo_de_funcionario NOT NULL,
+    quantidade int NOT NULL CHECK (quantidade > 0),
+
+    PRIMARY KEY (dia_semana, expediente, tipo)
+);
+
+-- Segurança nas noites de sexta e sábado
+INSERT INTO cobertura_minima (dia_semana, expediente, tipo, quantidade) VALUES
+    (5, 'noite', 'seguranca', 1),
+    (5, 'madrugada', 'seguranca', 1),
+    (6, 'noite', 'seguranca', 1),
+    (6, 'madrugada', 'seguranca', 1)
+ON CONFLICT DO NOTHING;
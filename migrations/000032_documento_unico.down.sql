@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS fornecedor_cnpj_unico;
+DROP INDEX IF EXISTS funcionario_cpf_unico;
+DROP INDEX IF EXISTS cliente_cpf_unico;
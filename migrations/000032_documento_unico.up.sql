@@ -0,0 +1,6 @@
+-- Um documento por cadastro. A checagem prévia dos serviços só dá a mensagem,
+-- quem garante sob concorrência é o índice (23505 vira 409).
+-- Documentos nulos (anonimizados ou não informados) não entram no índice.
+CREATE UNIQUE INDEX IF NOT EXISTS cliente_cpf_unico ON Cliente (CPF) WHERE CPF IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS funcionario_cpf_unico ON Funcionario (CPF) WHERE CPF IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS fornecedor_cnpj_unico ON Fornecedor (CNPJ) WHERE CNPJ IS NOT NULL;
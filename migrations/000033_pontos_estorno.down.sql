@@ -0,0 +1,16 @@
+-- O valor 'estorno' não sai do enum. Estornos e acúmulos repetidos viram
+-- ajustes, o que mantém o saldo.
+DROP INDEX IF EXISTS movimento_pontos_venda_idx;
+
+UPDATE movimento_pontos SET tipo = 'ajuste', motivo = COALESCE(motivo, 'Estorno de pagamento')
+WHERE tipo = 'estorno';
+
+UPDATE movimento_pontos m SET tipo = 'ajuste', motivo = COALESCE(m.motivo, 'Acúmulo estornado')
+WHERE m.tipo = 'acumulo'
+  AND EXISTS (
+    SELECT 1 FROM movimento_pontos n
+    WHERE n.id_venda = m.id_venda AND n.tipo = 'acumulo' AND n.id_movimento > m.id_movimento
+  );
+
+CREATE UNIQUE INDEX IF NOT EXISTS movimento_pontos_acumulo_idx ON movimento_pontos (id_venda)
+    WHERE tipo = 'acumulo';
@@ -0,0 +1,7 @@
+-- Pagamento removido de uma venda pontuada vira um débito de estorno, em vez de
+-- apagar o acúmulo. Se os pontos já foram usados o saldo fica negativo.
+-- A venda quitada de novo pontua outra vez, então o acúmulo deixa de ser único.
+ALTER TYPE tipo_movimento_pontos ADD VALUE IF NOT EXISTS 'estorno';
+
+DROP INDEX IF EXISTS movimento_pontos_acumulo_idx;
+CREATE INDEX IF NOT EXISTS movimento_pontos_venda_idx ON movimento_pontos (id_venda);